@@ -15,10 +15,12 @@
 package oauth2
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"math/rand"
@@ -94,6 +96,136 @@ func (ts *tokenSourcer) Token() (*oauth2.Token, error) {
 	return ts.token, nil
 }
 
+// RefreshFunc returns a new token to replace expired,
+// the token that Uber rejected, e.g by redeeming its refresh token.
+type RefreshFunc func(expired *oauth2.Token) (*oauth2.Token, error)
+
+// RetryingTransport authorizes requests like the transport returned by
+// TransportWithBase but if Uber rejects a request with a 401, it refreshes
+// the token once and replays the request with the new token. The new
+// token is then used for all the subsequent requests. It must be
+// created with RetryingTransportWithBase.
+type RetryingTransport struct {
+	base    http.RoundTripper
+	refresh RefreshFunc
+
+	ts *tokenSourcer
+}
+
+var _ http.RoundTripper = (*RetryingTransport)(nil)
+
+var errNilRefreshFunc = errors.New("expecting a non-nil refresh function")
+
+// RefreshError is returned by RetryingTransport.RoundTrip when Uber
+// rejected a request with a 401 and refreshing the token failed.
+type RefreshError struct {
+	// Err is the error returned by the RefreshFunc.
+	Err error
+}
+
+var _ error = (*RefreshError)(nil)
+
+func (re *RefreshError) Error() string {
+	return fmt.Sprintf("refreshing the token rejected with a 401: %v", re.Err)
+}
+
+func (re *RefreshError) Unwrap() error {
+	return re.Err
+}
+
+// RetryingTransportWithBase returns a RetryingTransport that authorizes
+// requests with token, refreshes it with refresh and sends the requests
+// with base. If base is nil, http.DefaultTransport is used.
+func RetryingTransportWithBase(token *oauth2.Token, refresh RefreshFunc, base http.RoundTripper) *RetryingTransport {
+	return &RetryingTransport{
+		base:    base,
+		refresh: refresh,
+		ts:      &tokenSourcer{token: token},
+	}
+}
+
+// Token returns the token currently used to authorize requests.
+func (rt *RetryingTransport) Token() (*oauth2.Token, error) {
+	return rt.ts.Token()
+}
+
+func (rt *RetryingTransport) baseTransport() http.RoundTripper {
+	if rt.base != nil {
+		return rt.base
+	}
+	return http.DefaultTransport
+}
+
+// RoundTrip sends req authorized with the current token. If Uber
+// rejects it with a 401, the token is refreshed and req is sent again
+// with the new token. If refreshing fails, a *RefreshError is returned.
+func (rt *RetryingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rt.refresh == nil {
+		return nil, errNilRefreshFunc
+	}
+
+	// The body is buffered since it'll be sent
+	// again if the request has to be replayed.
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	token, _ := rt.ts.Token()
+	res, err := rt.baseTransport().RoundTrip(authorizedRequest(req, body, token))
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+	res.Body.Close()
+
+	refreshed, err := rt.refreshToken(token)
+	if err != nil {
+		return nil, &RefreshError{Err: err}
+	}
+	return rt.baseTransport().RoundTrip(authorizedRequest(req, body, refreshed))
+}
+
+// refreshToken replaces expired with a new token unless a concurrent
+// request already did, in which case that token is returned.
+func (rt *RetryingTransport) refreshToken(expired *oauth2.Token) (*oauth2.Token, error) {
+	rt.ts.Lock()
+	defer rt.ts.Unlock()
+
+	if rt.ts.token != expired {
+		return rt.ts.token, nil
+	}
+	token, err := rt.refresh(expired)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil {
+		return nil, errNoOAuth2TokenDeserialized
+	}
+	rt.ts.token = token
+	return token, nil
+}
+
+// authorizedRequest returns a copy of req with body, authorized with token,
+// leaving req untouched as http.RoundTripper implementations must.
+func authorizedRequest(req *http.Request, body []byte, token *oauth2.Token) *http.Request {
+	authReq := req.Clone(req.Context())
+	if req.Body != nil {
+		authReq.Body = ioutil.NopCloser(bytes.NewReader(body))
+		authReq.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+	if token != nil {
+		token.SetAuthHeader(authReq)
+	}
+	return authReq
+}
+
 const (
 	OAuth2AuthURL  = "https://login.uber.com/oauth/v2/authorize"
 	OAuth2TokenURL = "https://login.uber.com/oauth/v2/token"
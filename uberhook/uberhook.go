@@ -15,6 +15,9 @@
 package uberhook
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io"
@@ -28,15 +31,36 @@ import (
 )
 
 type Event struct {
-	ID       string `json:"event_id"`
-	TimeUnix int64  `json:"event_time"`
-	Type     string `json:"event_type"`
+	ID       string    `json:"event_id"`
+	TimeUnix int64     `json:"event_time"`
+	Type     EventType `json:"event_type"`
 
 	Meta *Meta `json:"meta"`
 
 	URL string `json:"resource_href"`
 }
 
+type EventType string
+
+// The event types that Uber sends webhooks for. See
+// https://developer.uber.com/docs/riders/guides/webhooks
+const (
+	// EventRequestStatusChanged is sent when the status of
+	// a ride request of a user of the application changes.
+	EventRequestStatusChanged EventType = "requests.status_changed"
+
+	// EventRequestReceiptReady is sent when the
+	// receipt of a completed ride is available.
+	EventRequestReceiptReady EventType = "requests.receipt_ready"
+
+	// EventAllTripsStatusChanged is sent when the status of any trip
+	// of a user changes, even for rides not requested by the application.
+	EventAllTripsStatusChanged EventType = "all_trips.status_changed"
+
+	// EventDeliveryStatusChanged is sent when the status of a delivery changes.
+	EventDeliveryStatusChanged EventType = "deliveries.status_changed"
+)
+
 type Status string
 
 type Meta struct {
@@ -84,7 +108,7 @@ func (v *Webhook) LookupSecret(apiKey string) ([]byte, error) {
 }
 
 func (v *Webhook) Signature(hdr http.Header) (string, error) {
-	return hdr.Get("X-Uber-Signature"), nil
+	return hdr.Get(signatureHeader), nil
 }
 
 func New() (*Webhook, error) {
@@ -123,3 +147,60 @@ func FparseEvent(r io.Reader) (*Event, error) {
 	}
 	return ev, nil
 }
+
+const signatureHeader = "X-Uber-Signature"
+
+var (
+	// ErrMissingSignature is returned when a request
+	// doesn't have the X-Uber-Signature header.
+	ErrMissingSignature = errors.New("uberhook: missing X-Uber-Signature header")
+
+	// ErrInvalidSignature is returned when the X-Uber-Signature
+	// of a request doesn't match the HMAC-SHA256 of its body.
+	ErrInvalidSignature = errors.New("uberhook: invalid X-Uber-Signature")
+)
+
+// Parse verifies that the body of the webhook request r was signed by
+// Uber with secret, which is the client secret of the application,
+// and then parses the event in it. It returns ErrMissingSignature or
+// ErrInvalidSignature if the request wasn't signed with secret.
+func Parse(r *http.Request, secret string) (*Event, error) {
+	if secret == "" {
+		return nil, errBlankClientSecret
+	}
+	if r.Body != nil {
+		defer r.Body.Close()
+	}
+
+	signature := r.Header.Get(signatureHeader)
+	if signature == "" {
+		return nil, ErrMissingSignature
+	}
+	gotMAC, err := hex.DecodeString(signature)
+	if err != nil {
+		return nil, ErrInvalidSignature
+	}
+
+	var blob []byte
+	if r.Body != nil {
+		blob, err = ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(blob)
+	if !hmac.Equal(gotMAC, mac.Sum(nil)) {
+		return nil, ErrInvalidSignature
+	}
+
+	ev := new(Event)
+	if err := json.Unmarshal(blob, ev); err != nil {
+		return nil, err
+	}
+	if *ev == blankEvent {
+		return nil, errBlankEvent
+	}
+	return ev, nil
+}
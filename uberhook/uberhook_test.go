@@ -0,0 +1,125 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uberhook_test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/garfieldchenyu/uber/uberhook"
+)
+
+const (
+	testSecret = "uber-client-secret"
+
+	statusChangedEvent = `{
+  "event_id": "3a3f3da4-14ac-4056-bbf2-d0b9cdcb0777",
+  "event_time": 1427343990,
+  "event_type": "requests.status_changed",
+  "meta": {
+    "user_id": "d13dff8b-1ba5-4c3a-b2ea-c8d9a0aa7bab",
+    "resource_id": "2a2f3da4-14ac-4056-bbf2-d0b9cdcb0777",
+    "status": "accepted"
+  },
+  "resource_href": "https://api.uber.com/v1.2/requests/2a2f3da4-14ac-4056-bbf2-d0b9cdcb0777"
+}`
+)
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestParse(t *testing.T) {
+	tests := [...]struct {
+		body      string
+		signature string
+		secret    string
+
+		wantErr  error
+		wantType uberhook.EventType
+		wantHref string
+	}{
+		0: {
+			body:      statusChangedEvent,
+			signature: sign(testSecret, statusChangedEvent),
+			secret:    testSecret,
+			wantType:  uberhook.EventRequestStatusChanged,
+			wantHref:  "https://api.uber.com/v1.2/requests/2a2f3da4-14ac-4056-bbf2-d0b9cdcb0777",
+		},
+		1: {
+			// No signature.
+			body:    statusChangedEvent,
+			secret:  testSecret,
+			wantErr: uberhook.ErrMissingSignature,
+		},
+		2: {
+			// Signed with a different secret.
+			body:      statusChangedEvent,
+			signature: sign("another-secret", statusChangedEvent),
+			secret:    testSecret,
+			wantErr:   uberhook.ErrInvalidSignature,
+		},
+		3: {
+			// The body was tampered with after signing.
+			body:      strings.Replace(statusChangedEvent, "accepted", "completed", 1),
+			signature: sign(testSecret, statusChangedEvent),
+			secret:    testSecret,
+			wantErr:   uberhook.ErrInvalidSignature,
+		},
+		4: {
+			// Not a hex encoded signature.
+			body:      statusChangedEvent,
+			signature: "not-hex",
+			secret:    testSecret,
+			wantErr:   uberhook.ErrInvalidSignature,
+		},
+	}
+
+	for i, tt := range tests {
+		req, err := http.NewRequest("POST", "https://uberhook.example.com/", strings.NewReader(tt.body))
+		if err != nil {
+			t.Errorf("#%d: creating request: %v", i, err)
+			continue
+		}
+		if tt.signature != "" {
+			req.Header.Set("X-Uber-Signature", tt.signature)
+		}
+
+		event, err := uberhook.Parse(req, tt.secret)
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		if g, w := event.Type, tt.wantType; g != w {
+			t.Errorf("#%d: eventType: got=%q want=%q", i, g, w)
+		}
+		if g, w := event.URL, tt.wantHref; g != w {
+			t.Errorf("#%d: resourceHref: got=%q want=%q", i, g, w)
+		}
+	}
+}
@@ -15,7 +15,6 @@
 package uber
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -59,7 +58,7 @@ func (c *Client) activitiesByID(path string, versions ...string) ([]*Activity, e
 		return nil, err
 	}
 	values := new(activitiesWrap)
-	if err := json.Unmarshal(slurp, values); err != nil {
+	if err := c.unmarshalResponse(slurp, values); err != nil {
 		return nil, err
 	}
 	return values.Activities, nil
@@ -0,0 +1,92 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"context"
+	"sync"
+)
+
+// Bootstrap is the combined result of the calls that an
+// application typically makes on start up. Every section
+// carries its own error so that one failing section
+// does not prevent the others from being used.
+type Bootstrap struct {
+	Profile    *Profile
+	ProfileErr error
+
+	PaymentMethods    *PaymentListing
+	PaymentMethodsErr error
+
+	Home    *Place
+	HomeErr error
+
+	Work    *Place
+	WorkErr error
+
+	// Products are the products offered at the
+	// place that was passed into Client.Bootstrap.
+	Products    []*Product
+	ProductsErr error
+}
+
+// Bootstrap concurrently fetches your profile, payment methods,
+// saved places and the products available at the given place.
+// A saved place that isn't set is nil and has no error.
+// The only error it returns is that of the context being
+// done before all the sections were retrieved, otherwise
+// each section's error is set on the returned Bootstrap.
+// Canceling ctx also aborts the requests still in flight.
+func (c *Client) Bootstrap(ctx context.Context, p *Place) (*Bootstrap, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	c = c.withContext(ctx)
+	bs := new(Bootstrap)
+	var wg sync.WaitGroup
+	fns := [...]func(){
+		func() { bs.Profile, bs.ProfileErr = c.RetrieveMyProfile() },
+		func() { bs.PaymentMethods, bs.PaymentMethodsErr = c.ListPaymentMethods() },
+		func() {
+			places, err := c.ListPlaces()
+			bs.Home, bs.Work = places[PlaceHome], places[PlaceWork]
+			if placesErr, ok := err.(PlacesError); ok {
+				bs.HomeErr, bs.WorkErr = placesErr[PlaceHome], placesErr[PlaceWork]
+			}
+		},
+		func() { bs.Products, bs.ProductsErr = c.ListProducts(p) },
+	}
+	for _, fn := range fns {
+		wg.Add(1)
+		go func(fn func()) {
+			defer wg.Done()
+			fn()
+		}(fn)
+	}
+
+	doneChan := make(chan bool)
+	go func() {
+		defer close(doneChan)
+		wg.Wait()
+	}()
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-doneChan:
+		return bs, nil
+	}
+}
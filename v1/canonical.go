@@ -0,0 +1,67 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// MarshalCanonical returns the JSON encoding of v in a canonical form:
+// object keys are sorted and fields whose values are null, such as nil
+// pointers, slices and maps, are omitted at every level of nesting.
+// Two values with the same content thus always serialize identically
+// regardless of struct field order or omitempty tags. Numbers are
+// preserved exactly as json.Marshal encodes them.
+func MarshalCanonical(v interface{}) ([]byte, error) {
+	blob, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.UseNumber()
+	var generic interface{}
+	if err := dec.Decode(&generic); err != nil {
+		return nil, err
+	}
+
+	// encoding/json sorts the keys of maps when marshaling.
+	return json.Marshal(dropNulls(generic))
+}
+
+func dropNulls(v interface{}) interface{} {
+	switch vt := v.(type) {
+	case map[string]interface{}:
+		for key, value := range vt {
+			if value == nil {
+				delete(vt, key)
+			} else {
+				vt[key] = dropNulls(value)
+			}
+		}
+		return vt
+
+	case []interface{}:
+		// Null elements are retained since their positions are significant.
+		for i, value := range vt {
+			vt[i] = dropNulls(value)
+		}
+		return vt
+
+	default:
+		return v
+	}
+}
@@ -15,14 +15,24 @@
 package uber
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"golang.org/x/oauth2"
 
@@ -30,16 +40,173 @@ import (
 	"github.com/orijtech/otils"
 )
 
-const envUberTokenKey = "UBER_TOKEN_KEY"
+const (
+	envUberToken   = "UBER_TOKEN"
+	envUberSandbox = "UBER_SANDBOX"
 
-var errUnsetTokenEnvKey = fmt.Errorf("could not find %q in your environment", envUberTokenKey)
+	// envUberTokenKey is the variable that the
+	// token was read from before envUberToken.
+	envUberTokenKey = "UBER_TOKEN_KEY"
+)
+
+var errUnsetTokenEnvKey = fmt.Errorf("could not find %q or %q in your environment", envUberToken, envUberTokenKey)
+
+// ErrUnauthenticated is returned when instead of a JSON response, the server
+// redirects to or responds with an HTML page. API gateways typically do this
+// to send requests with expired or invalid credentials to a login page.
+var ErrUnauthenticated = errors.New("unauthenticated: redirected to a login page, the credentials could be expired or invalid")
 
+// Client is safe for concurrent use by multiple goroutines. However,
+// since the token and settings of a client are shared by all its users,
+// use Clone to derive a client whose token can be changed without
+// affecting the users of the original client e.g per user clients.
 type Client struct {
 	sync.RWMutex
 
+	hc        *http.Client
 	rt        http.RoundTripper
 	token     string
 	sandboxed bool
+
+	// customBaseURL if set, replaces the Uber API
+	// host and is the prefix of all endpoint paths.
+	customBaseURL string
+
+	// riderVersion and driverVersion if set, replace the
+	// API versions of the rider and driver endpoints.
+	riderVersion  string
+	driverVersion string
+
+	autoIdempotency bool
+
+	productCacheTTL time.Duration
+	productCache    *productCache
+
+	etagCaching bool
+	etagCache   *etagCache
+
+	requestTimeout time.Duration
+
+	// userAgent if set, replaces defaultUserAgent.
+	userAgent string
+
+	// locale if set, is sent as the locale query
+	// parameter of product and estimate requests.
+	locale string
+
+	// defaultQueryParams are added to every request
+	// unless the request already sets the parameter.
+	defaultQueryParams url.Values
+
+	// noFareRedirect is the inverse of SetFollowFareRedirect
+	// so that the zero value follows the redirect.
+	noFareRedirect bool
+
+	strictDecoding bool
+
+	idempotentCancel bool
+
+	// timezone if set, replaces UTC as the timezone in
+	// which timestamps are bucketed into calendar days.
+	timezone *time.Location
+
+	geocoder Geocoder
+
+	// lastRequestID is the X-Request-Id of the most recent response.
+	// It isn't a setting so clones start without one.
+	lastRequestID string
+
+	// requestIDOwner if set, is the client on whose behalf this
+	// clone makes calls, see withOptions. It records the request
+	// IDs of the clone's responses too.
+	requestIDOwner *Client
+
+	// ctx if set, is attached to every request of
+	// this clone, see withContext. Like lastRequestID,
+	// it isn't a setting so Clone doesn't copy it.
+	ctx context.Context
+
+	// defaultTransport if set, replaces http.DefaultTransport
+	// when neither a RoundTripper nor an *http.Client with
+	// a Transport was set. See SetMaxIdleConnsPerHost.
+	defaultTransport *http.Transport
+}
+
+// Clone returns a copy of the client that shares its HTTP client and
+// transport, and thus its connection pool, but whose token, sandbox mode
+// and other settings can be changed without affecting the original.
+func (c *Client) Clone() *Client {
+	c.RLock()
+	defer c.RUnlock()
+
+	// Every setting of the client must be copied here.
+	return &Client{
+		hc:        c.hc,
+		rt:        c.rt,
+		token:     c.token,
+		sandboxed: c.sandboxed,
+		geocoder:  c.geocoder,
+
+		defaultTransport: c.defaultTransport,
+
+		customBaseURL:   c.customBaseURL,
+		riderVersion:    c.riderVersion,
+		driverVersion:   c.driverVersion,
+		autoIdempotency: c.autoIdempotency,
+		productCacheTTL: c.productCacheTTL,
+		productCache:    c.productCache,
+		etagCaching:     c.etagCaching,
+		etagCache:       c.etagCache,
+		requestTimeout:  c.requestTimeout,
+		userAgent:       c.userAgent,
+		locale:          c.locale,
+		noFareRedirect:  c.noFareRedirect,
+		strictDecoding:  c.strictDecoding,
+		timezone:        c.timezone,
+
+		idempotentCancel: c.idempotentCancel,
+
+		defaultQueryParams: c.defaultQueryParams,
+	}
+}
+
+// WithToken returns a clone of the client that uses the given token.
+// It is the cheap way of serving many users from one client since the
+// clone reuses the connections of the original client.
+func (c *Client) WithToken(token string) *Client {
+	clone := c.Clone()
+	clone.SetBearerToken(token)
+	return clone
+}
+
+// RequestOptions overrides client settings for a single call of the
+// methods that accept it, e.g RequestRideWithOptions, without
+// changing the settings that other calls on the client see.
+type RequestOptions struct {
+	// Sandbox if non-nil, picks the sandboxed API
+	// endpoint or not, regardless of SetSandboxMode.
+	Sandbox *bool
+}
+
+// withOptions returns the client to make a call with opts, which
+// is c itself if there is nothing to override, otherwise a clone.
+func (c *Client) withOptions(opts *RequestOptions) *Client {
+	if opts == nil || opts.Sandbox == nil {
+		return c
+	}
+	clone := c.Clone()
+	clone.SetSandboxMode(*opts.Sandbox)
+	clone.requestIDOwner = c
+	return clone
+}
+
+// withContext returns a clone of the client whose requests are
+// all made with ctx, so that canceling ctx aborts them.
+func (c *Client) withContext(ctx context.Context) *Client {
+	clone := c.Clone()
+	clone.ctx = ctx
+	clone.requestIDOwner = c
+	return clone
 }
 
 func (c *Client) hasServerToken() bool {
@@ -67,8 +234,303 @@ func (c *Client) Sandboxed() bool {
 	return c.sandboxed
 }
 
+// SetRequestTimeout sets the maximum duration of each HTTP request,
+// including reading its response. Paginated methods apply it to each
+// page fetch, not to the whole pagination. A zero duration, the
+// default, means that requests don't time out.
+func (c *Client) SetRequestTimeout(d time.Duration) {
+	c.Lock()
+	c.requestTimeout = d
+	c.Unlock()
+}
+
+func (c *Client) getRequestTimeout() time.Duration {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.requestTimeout
+}
+
+const libraryVersion = "1.0.0"
+
+const defaultUserAgent = "uber-go-client/" + libraryVersion
+
+// SetUserAgent sets the User-Agent header sent with every request
+// so that Uber, and your logs, can identify your application.
+// A blank userAgent restores the default "uber-go-client/<version>".
+func (c *Client) SetUserAgent(userAgent string) {
+	c.Lock()
+	c.userAgent = strings.TrimSpace(userAgent)
+	c.Unlock()
+}
+
+// SetTimezone sets the timezone of the calendar days into which
+// methods such as DriverEarningsByDay group their results. A nil
+// timezone, the default, is UTC.
+func (c *Client) SetTimezone(timezone *time.Location) {
+	c.Lock()
+	c.timezone = timezone
+	c.Unlock()
+}
+
+func (c *Client) getTimezone() *time.Location {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.timezone == nil {
+		return time.UTC
+	}
+	return c.timezone
+}
+
+// LastRequestID returns the value of the X-Request-Id header of the
+// most recent response from Uber, successful or not, which identifies
+// the request when filing a ticket with Uber support. It is empty if
+// that response had no such header. Since the client can be used
+// concurrently, use a Clone per flow whose request IDs are needed.
+func (c *Client) LastRequestID() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.lastRequestID
+}
+
+func (c *Client) setLastRequestID(requestID string) {
+	c.Lock()
+	c.lastRequestID = requestID
+	owner := c.requestIDOwner
+	c.Unlock()
+
+	if owner != nil {
+		owner.setLastRequestID(requestID)
+	}
+}
+
+func (c *Client) getUserAgent() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return otils.FirstNonEmptyString(c.userAgent, defaultUserAgent)
+}
+
+var errInvalidLocale = errors.New(`expecting a locale such as "fr_FR" or "en"`)
+
+var localeRegexp = regexp.MustCompile(`^[a-zA-Z]{2,3}([_-][a-zA-Z0-9]{2,8})*$`)
+
+// SetLocale sets the locale e.g "fr_FR" in which product descriptions
+// and estimate display strings are returned. It is sent as the locale
+// query parameter of ListProducts, ProductByID, EstimatePrice and
+// EstimateTime. A blank locale restores Uber's default.
+func (c *Client) SetLocale(locale string) error {
+	locale = strings.TrimSpace(locale)
+	if locale != "" && !localeRegexp.MatchString(locale) {
+		return errInvalidLocale
+	}
+
+	c.Lock()
+	c.locale = locale
+	c.Unlock()
+
+	return nil
+}
+
+func (c *Client) getLocale() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.locale
+}
+
+// addLocale sets the locale query parameter in qv if a locale was set.
+func (c *Client) addLocale(qv url.Values) {
+	if locale := c.getLocale(); locale != "" {
+		qv.Set("locale", locale)
+	}
+}
+
+// SetDefaultQueryParams sets query parameters that are added to every
+// request, for example to send optional parameters that endpoints accept
+// but that this package doesn't expose yet. Parameters that the package
+// itself sets on a request take precedence over the defaults. For a
+// parameter on a single call, pass a QueryOption to the methods that
+// accept them. Nil params clears the defaults.
+func (c *Client) SetDefaultQueryParams(params url.Values) {
+	var copied url.Values
+	if len(params) > 0 {
+		copied = make(url.Values, len(params))
+		for key, values := range params {
+			copied[key] = append([]string(nil), values...)
+		}
+	}
+
+	c.Lock()
+	c.defaultQueryParams = copied
+	c.Unlock()
+}
+
+// addDefaultQueryParams adds the default query parameters
+// that req doesn't already set to req's URL.
+func (c *Client) addDefaultQueryParams(req *http.Request) {
+	c.RLock()
+	defaults := c.defaultQueryParams
+	c.RUnlock()
+	if len(defaults) == 0 {
+		return
+	}
+
+	qv := req.URL.Query()
+	for key, values := range defaults {
+		if _, set := qv[key]; !set {
+			qv[key] = values
+		}
+	}
+	req.URL.RawQuery = qv.Encode()
+}
+
+// QueryOption adds query parameters to a single call of the methods
+// that accept it, e.g ListProducts. Like the parameters set with
+// SetDefaultQueryParams, they don't override the parameters that
+// the package itself sets but they do override the defaults.
+type QueryOption func(url.Values)
+
+// WithQueryParam returns a QueryOption that adds value to the key
+// query parameter. It can be passed more than once for the same key.
+func WithQueryParam(key, value string) QueryOption {
+	return func(qv url.Values) {
+		qv.Add(key, value)
+	}
+}
+
+// applyQueryOptions adds the parameters of opts that fullURL
+// doesn't already set to it and returns the resulting URL.
+func applyQueryOptions(fullURL string, opts []QueryOption) (string, error) {
+	if len(opts) == 0 {
+		return fullURL, nil
+	}
+	extra := make(url.Values)
+	for _, opt := range opts {
+		if opt != nil {
+			opt(extra)
+		}
+	}
+	if len(extra) == 0 {
+		return fullURL, nil
+	}
+
+	parsedURL, err := url.Parse(fullURL)
+	if err != nil {
+		return "", err
+	}
+	qv := parsedURL.Query()
+	for key, values := range extra {
+		if _, set := qv[key]; !set {
+			qv[key] = values
+		}
+	}
+	parsedURL.RawQuery = qv.Encode()
+	return parsedURL.String(), nil
+}
+
+// SetFollowFareRedirect controls whether RequestRide, when invoked
+// with a blank FareID and a PromptOnFare callback, first fetches the
+// upfront fare from POST /requests/estimate and then requests the ride
+// with that fare. It is the only method that does so and it is enabled
+// by default. If disabled, RequestRide requires a FareID, which callers
+// can retrieve themselves with UpfrontFare or Do.
+func (c *Client) SetFollowFareRedirect(follow bool) {
+	c.Lock()
+	c.noFareRedirect = !follow
+	c.Unlock()
+}
+
+func (c *Client) followsFareRedirect() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return !c.noFareRedirect
+}
+
+// SetAutoIdempotency if set to true, makes the client generate a
+// random idempotency key for every ride or delivery request that
+// doesn't have its IdempotencyKey set.
+func (c *Client) SetAutoIdempotency(auto bool) {
+	c.Lock()
+	c.autoIdempotency = auto
+	c.Unlock()
+}
+
+// idempotencyKey returns key if it is set, otherwise a random key
+// if automatic idempotency keys were enabled or else a blank key.
+func (c *Client) idempotencyKey(key string) (string, error) {
+	c.RLock()
+	auto := c.autoIdempotency
+	c.RUnlock()
+
+	if key != "" || !auto {
+		return key, nil
+	}
+	return newUUID()
+}
+
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// newUUID returns a random version 4 UUID.
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		return "", err
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant RFC 4122
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
+
 const defaultVersion = "v1.2"
 
+var errInvalidBaseURL = errors.New("expecting an absolute base URL with a scheme and host")
+
+// SetBaseURL makes the client send requests to baseURL instead of the
+// Uber API, for example when Uber is reached through an API gateway.
+// baseURL can contain a path prefix that is prepended to all endpoint
+// paths, so with a baseURL of "https://gw.corp/uber-proxy/", the
+// products endpoint becomes "https://gw.corp/uber-proxy/v1.2/products".
+// The API version e.g "v1.2" or "v1" for endpoints that require it, is
+// still appended after the prefix. A custom base URL takes precedence
+// over sandbox mode. Setting an empty baseURL restores the default.
+func (c *Client) SetBaseURL(baseURL string) error {
+	if baseURL != "" {
+		parsedURL, err := url.Parse(baseURL)
+		if err != nil {
+			return err
+		}
+		if parsedURL.Scheme == "" || parsedURL.Host == "" {
+			return errInvalidBaseURL
+		}
+		parsedURL.RawQuery, parsedURL.Fragment = "", ""
+		baseURL = strings.TrimSuffix(parsedURL.String(), "/")
+	}
+
+	c.Lock()
+	c.customBaseURL = baseURL
+	c.Unlock()
+
+	return nil
+}
+
+// rootURL returns the URL that API versions and
+// endpoint paths are appended to. It must be
+// invoked with the client's lock held.
+func (c *Client) rootURL() string {
+	switch {
+	case c.customBaseURL != "":
+		return c.customBaseURL
+	case c.sandboxed:
+		return "https://sandbox-api.uber.com"
+	default: // Invoking the production endpoint
+		return "https://api.uber.com"
+	}
+}
+
 func (c *Client) baseURL(versions ...string) string {
 	// Setting the baseURLs in here to ensure that no-one mistakenly
 	// directly invokes baseURL or sandboxBaseURL.
@@ -77,14 +539,64 @@ func (c *Client) baseURL(versions ...string) string {
 
 	version := otils.FirstNonEmptyString(versions...)
 	if version == "" {
-		version = defaultVersion
+		version = otils.FirstNonEmptyString(c.riderVersion, defaultVersion)
+	}
+
+	return c.rootURL() + "/" + version
+}
+
+var (
+	errInvalidAPIVersion = errors.New(`expecting an API version such as "v1" or "v1.2"`)
+
+	apiVersionRegexp = regexp.MustCompile(`^v[0-9]+(\.[0-9]+)?$`)
+)
+
+func validateAPIVersion(version string) error {
+	if version != "" && !apiVersionRegexp.MatchString(version) {
+		return errInvalidAPIVersion
 	}
+	return nil
+}
 
-	if c.sandboxed {
-		return "https://sandbox-api.uber.com/" + version
-	} else { // Invoking the production endpoint
-		return "https://api.uber.com/" + version
+// SetRiderAPIVersion pins the API version of the rider endpoints
+// e.g products, estimates, places and ride requests, which is "v1.2"
+// by default, so that a newer version can be used before this
+// package defaults to it. version must be of the form "v1" or "v1.2"
+// and an empty version restores the default. ListDeliveries is the
+// exception that stays on "v1" since Uber only serves it there.
+func (c *Client) SetRiderAPIVersion(version string) error {
+	if err := validateAPIVersion(version); err != nil {
+		return err
 	}
+
+	c.Lock()
+	c.riderVersion = version
+	c.Unlock()
+
+	return nil
+}
+
+// SetDriverAPIVersion is like SetRiderAPIVersion but for the
+// driver endpoints e.g DriverProfile and ListDriverTrips,
+// whose version is "v1" by default.
+func (c *Client) SetDriverAPIVersion(version string) error {
+	if err := validateAPIVersion(version); err != nil {
+		return err
+	}
+
+	c.Lock()
+	c.driverVersion = version
+	c.Unlock()
+
+	return nil
+}
+
+// driverAPIVersion returns the API version of the driver endpoints.
+func (c *Client) driverAPIVersion() string {
+	c.RLock()
+	defer c.RUnlock()
+
+	return otils.FirstNonEmptyString(c.driverVersion, driverV1API)
 }
 
 // Some endpoints require us to hit /v1 instead of /v1.2 as in Client.baseURL.
@@ -96,11 +608,7 @@ func (c *Client) legacyV1BaseURL() string {
 	c.RLock()
 	defer c.RUnlock()
 
-	if c.sandboxed {
-		return "https://sandbox-api.uber.com/v1"
-	} else { // Invoking the production endpoint
-		return "https://api.uber.com/v1"
-	}
+	return c.rootURL() + "/v1"
 }
 
 func NewClient(tokens ...string) (*Client, error) {
@@ -128,13 +636,55 @@ func NewSandboxedClientFromEnv() (*Client, error) {
 	return c, err
 }
 
+// NewClientFromEnv creates a client with the token in the UBER_TOKEN
+// environment variable, falling back to UBER_TOKEN_KEY. The client is
+// in sandbox mode if UBER_SANDBOX is set to a true value e.g "true" or "1".
 func NewClientFromEnv() (*Client, error) {
-	retrToken := strings.TrimSpace(os.Getenv(envUberTokenKey))
+	retrToken := otils.FirstNonEmptyString(
+		strings.TrimSpace(os.Getenv(envUberToken)),
+		strings.TrimSpace(os.Getenv(envUberTokenKey)),
+	)
 	if retrToken == "" {
 		return nil, errUnsetTokenEnvKey
 	}
 
-	return &Client{token: retrToken}, nil
+	sandboxed := false
+	if value := strings.TrimSpace(os.Getenv(envUberSandbox)); value != "" {
+		var err error
+		sandboxed, err = strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s=%q: %v", envUberSandbox, value, err)
+		}
+	}
+
+	c, err := NewClient(retrToken)
+	if err != nil {
+		return nil, err
+	}
+	c.SetSandboxMode(sandboxed)
+	return c, nil
+}
+
+// NewClientWithHTTPClient creates a client that
+// makes its requests using the provided *http.Client.
+func NewClientWithHTTPClient(token string, hc *http.Client) (*Client, error) {
+	c, err := NewClient(token)
+	if err != nil {
+		return nil, err
+	}
+	c.SetHTTPClient(hc)
+	return c, nil
+}
+
+// SetHTTPClient sets the *http.Client whose timeout, redirect
+// policy, cookie jar and transport are used for all requests.
+// A RoundTripper set by SetHTTPRoundTripper takes precedence
+// over the transport of the provided *http.Client, which makes
+// it possible to combine a custom client with a custom transport.
+func (c *Client) SetHTTPClient(hc *http.Client) {
+	c.Lock()
+	c.hc = hc
+	c.Unlock()
 }
 
 func (c *Client) SetHTTPRoundTripper(rt http.RoundTripper) {
@@ -152,14 +702,68 @@ func (c *Client) SetBearerToken(token string) {
 
 func (c *Client) httpClient() *http.Client {
 	c.RLock()
-	rt := c.rt
+	rt, hc := c.rt, c.hc
 	c.RUnlock()
 
-	if rt == nil {
-		rt = http.DefaultTransport
+	client := new(http.Client)
+	if hc != nil {
+		// Shallow copy so that the caller's client is never modified.
+		*client = *hc
+	}
+	if rt != nil {
+		client.Transport = rt
+	}
+	if client.Transport == nil {
+		client.Transport = http.DefaultTransport
+		if dt := c.getDefaultTransport(); dt != nil {
+			client.Transport = dt
+		}
+	}
+
+	return client
+}
+
+// SetMaxIdleConnsPerHost sets the maximum number of idle connections
+// to Uber that are kept for reuse, which http.DefaultTransport limits
+// to 2. Raise it if you make many concurrent requests so that they
+// don't each open a new connection. It configures a copy of
+// http.DefaultTransport and so it has no effect if a RoundTripper or an
+// *http.Client with a Transport was set. A value of 0 or less restores
+// http.DefaultTransport.
+func (c *Client) SetMaxIdleConnsPerHost(n int) {
+	var transport *http.Transport
+	if dt, ok := http.DefaultTransport.(*http.Transport); ok && n > 0 {
+		transport = dt.Clone()
+		transport.MaxIdleConnsPerHost = n
+		if transport.MaxIdleConns > 0 && transport.MaxIdleConns < n {
+			transport.MaxIdleConns = n
+		}
 	}
 
-	return &http.Client{Transport: rt}
+	c.Lock()
+	c.defaultTransport = transport
+	c.Unlock()
+}
+
+// Close releases the resources held by the client by closing the idle
+// connections of its transport, unless it is http.DefaultTransport which
+// is shared by the whole program. Clones of the client share its
+// transport and so are affected too. The client has no background
+// goroutines to stop and remains usable after Close, which can be
+// called any number of times and always returns nil.
+func (c *Client) Close() error {
+	hc := c.httpClient()
+	if hc.Transport != http.DefaultTransport {
+		hc.CloseIdleConnections()
+	}
+	return nil
+}
+
+func (c *Client) getDefaultTransport() *http.Transport {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.defaultTransport
 }
 
 func (c *Client) bearerToken() string {
@@ -188,38 +792,355 @@ func (c *Client) doReq(req *http.Request) ([]byte, http.Header, error) {
 	return c.doHTTPReq(req)
 }
 
+var errInvalidPath = errors.New(`expecting a path that starts with "/" e.g "/v1.2/products"`)
+
+// Do sends a request to an endpoint that this package doesn't wrap yet.
+// It is an escape hatch and the typed methods should be preferred.
+//
+// path is appended to the API host, which depends on the sandbox mode
+// and SetBaseURL, so it must include the API version and any query
+// parameters e.g "/v1.2/products?latitude=37.77&longitude=-122.41".
+// If body is non-nil, it is sent as JSON. If out is non-nil, the JSON
+// response is decoded into it. The bearer token is sent if it is set and
+// Uber error responses are returned as *Error, as with the typed methods.
+//
+// The body of the returned response has already been read and closed
+// but on success, a copy of it can still be read from res.Body.
+func (c *Client) Do(method, path string, body interface{}, out interface{}) (*http.Response, error) {
+	if !strings.HasPrefix(path, "/") {
+		return nil, errInvalidPath
+	}
+
+	var bodyReader io.Reader
+	if body != nil {
+		blob, err := MarshalCanonical(body)
+		if err != nil {
+			return nil, err
+		}
+		bodyReader = bytes.NewReader(blob)
+	}
+
+	c.RLock()
+	fullURL := c.rootURL() + path
+	c.RUnlock()
+
+	req, err := http.NewRequest(method, fullURL, bodyReader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.hasServerToken() {
+		req.Header.Set("Authorization", c.bearerToken())
+	}
+
+	blob, res, err := c.doHTTPReqWithResponse(req)
+	if err != nil {
+		return res, err
+	}
+	res.Body = ioutil.NopCloser(bytes.NewReader(blob))
+	if out != nil && len(bytes.TrimSpace(blob)) > 0 {
+		if err := c.unmarshalResponse(blob, out); err != nil {
+			return res, err
+		}
+	}
+	return res, nil
+}
+
 func (c *Client) doHTTPReq(req *http.Request) ([]byte, http.Header, error) {
+	blob, res, err := c.doHTTPReqWithResponse(req)
+	if res == nil {
+		return blob, nil, err
+	}
+	return blob, res.Header, err
+}
+
+// doHTTPReqWithResponse is doHTTPReq but it returns the
+// whole response, whose body will have been closed.
+func (c *Client) doHTTPReqWithResponse(req *http.Request) ([]byte, *http.Response, error) {
+	if c.ctx != nil {
+		req = req.WithContext(c.ctx)
+	}
+	if timeout := c.getRequestTimeout(); timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", c.getUserAgent())
+	}
+	c.addDefaultQueryParams(req)
+	if req.Header.Get("Accept-Encoding") == "" {
+		// Setting this ourselves turns off the transport's transparent
+		// decompression, so gzip bodies are decoded by responseBody.
+		req.Header.Set("Accept-Encoding", "gzip")
+	}
+
 	res, err := c.httpClient().Do(req)
 	if err != nil {
 		return nil, nil, err
 	}
+	c.setLastRequestID(res.Header.Get("X-Request-Id"))
 	if res.Body != nil {
-		defer res.Body.Close()
+		defer drainAndClose(res.Body)
+	}
+
+	if res.StatusCode == http.StatusNotModified && req.Header.Get("If-None-Match") != "" {
+		// The caller has the cached response.
+		return nil, res, nil
+	}
+
+	if isLoginPage(res) {
+		return nil, res, ErrUnauthenticated
 	}
 
 	if !otils.StatusOK(res.StatusCode) {
 		errMsg := res.Status
 		var err error
-		if res.Body != nil {
-			slurp, _ := ioutil.ReadAll(res.Body)
+		if body, _ := responseBody(res); body != nil {
+			slurp, _ := ioutil.ReadAll(body)
+			body.Close()
 			if len(slurp) > 3 {
 				ue := new(Error)
 				plainUE := new(Error)
 				if jerr := json.Unmarshal(slurp, ue); jerr == nil && !reflect.DeepEqual(ue, plainUE) {
 					err = ue
 				} else {
-					errMsg = string(slurp)
+					errMsg = errorBodyText(slurp)
 				}
 			}
 		}
 		if err == nil {
 			err = otils.MakeCodedError(errMsg, res.StatusCode)
 		}
-		return nil, res.Header, err
+		return nil, res, err
 	}
 
-	blob, err := ioutil.ReadAll(res.Body)
-	return blob, res.Header, err
+	if hasNoContent(res.StatusCode) {
+		// Any body sent along is meaningless and is discarded.
+		return nil, res, nil
+	}
+
+	body, err := responseBody(res)
+	if err != nil {
+		return nil, res, err
+	}
+	blob, err := ioutil.ReadAll(body)
+	if cerr := body.Close(); err == nil {
+		err = cerr
+	}
+	return blob, res, err
+}
+
+// responseBody returns a reader of the decoded body of res,
+// decompressing it if the server sent it gzip encoded. The
+// reader must be closed but closing it leaves res.Body open.
+func responseBody(res *http.Response) (io.ReadCloser, error) {
+	if res.Body == nil {
+		return nil, nil
+	}
+	if !strings.EqualFold(res.Header.Get("Content-Encoding"), "gzip") {
+		return ioutil.NopCloser(res.Body), nil
+	}
+	gzr, err := gzip.NewReader(res.Body)
+	if err == io.EOF {
+		// An empty body.
+		return ioutil.NopCloser(strings.NewReader("")), nil
+	}
+	return gzr, err
+}
+
+// hasNoContent reports whether a response with the
+// status code has no body that should be decoded.
+func hasNoContent(code int) bool {
+	return code == http.StatusNoContent || code == http.StatusResetContent
+}
+
+// ErrNoContent is returned by methods that expect a response body
+// when Uber responds without one e.g with 204 No Content.
+var ErrNoContent = errors.New("expecting a response body but got no content")
+
+// SetStrictDecoding makes decoding a response fail if it has fields
+// that the library doesn't know of, which would otherwise be silently
+// dropped. It is meant for catching changes to Uber's API e.g in tests
+// against the real API; it is off by default so that new fields don't
+// break existing clients. Types with their own UnmarshalJSON, such as
+// Trip, are always decoded leniently.
+func (c *Client) SetStrictDecoding(strict bool) {
+	c.Lock()
+	c.strictDecoding = strict
+	c.Unlock()
+}
+
+func (c *Client) decodesStrictly() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.strictDecoding
+}
+
+// unmarshalResponse decodes the body of a response returned by doHTTPReq.
+func (c *Client) unmarshalResponse(blob []byte, v interface{}) error {
+	if len(bytes.TrimSpace(blob)) == 0 {
+		return ErrNoContent
+	}
+	if !c.decodesStrictly() {
+		return json.Unmarshal(blob, v)
+	}
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	dec.DisallowUnknownFields()
+	return dec.Decode(v)
+}
+
+// ErrTruncatedResponse is the error of a page whose response body was
+// cut short, for example by a dropped connection. The items that arrived
+// whole are still sent along with the page, so callers can decide whether
+// to use them or to retry the page.
+type ErrTruncatedResponse struct {
+	// ItemsParsed is the number of items decoded before the body ended.
+	ItemsParsed int
+
+	// Err is the error encountered while reading or decoding the body.
+	Err error
+}
+
+var _ error = (*ErrTruncatedResponse)(nil)
+
+func (etr *ErrTruncatedResponse) Error() string {
+	return fmt.Sprintf("truncated response after %d items: %v", etr.ItemsParsed, etr.Err)
+}
+
+// truncatedResponse checks whether err, from reading or decoding blob,
+// is due to blob being cut short. If so, the items that arrived whole
+// are handed to itemDecoders and an *ErrTruncatedResponse is returned,
+// otherwise err is returned as is.
+func truncatedResponse(blob []byte, err error, itemDecoders map[string]func(*json.Decoder) error) error {
+	if len(bytes.TrimSpace(blob)) == 0 {
+		return err
+	}
+	n, derr := decodeItems(blob, itemDecoders)
+	if derr != io.ErrUnexpectedEOF {
+		return err
+	}
+	return &ErrTruncatedResponse{ItemsParsed: n, Err: err}
+}
+
+// decodeItems decodes the JSON object in blob, one array item at a time,
+// with the decoder of itemDecoders keyed by the name of the array. Other
+// fields are skipped. It returns the number of items decoded and
+// io.ErrUnexpectedEOF if blob ended before the object did.
+func decodeItems(blob []byte, itemDecoders map[string]func(*json.Decoder) error) (n int, err error) {
+	dec := json.NewDecoder(bytes.NewReader(blob))
+	defer func() {
+		if isEndOfInput(err) {
+			// The object was opened but never closed.
+			err = io.ErrUnexpectedEOF
+		}
+	}()
+
+	if err := expectDelim(dec, '{'); err != nil {
+		return n, err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return n, err
+		}
+		decodeItem, ok := itemDecoders[fmt.Sprint(key)]
+		if !ok {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return n, err
+			}
+			continue
+		}
+
+		if err := expectDelim(dec, '['); err != nil {
+			return n, err
+		}
+		for dec.More() {
+			if err := decodeItem(dec); err != nil {
+				return n, err
+			}
+			n += 1
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return n, err
+		}
+	}
+	return n, expectDelim(dec, '}')
+}
+
+// isEndOfInput reports whether err is due to the JSON input ending
+// prematurely. Depending on where the input ended, json.Decoder.Token
+// reports it as io.EOF or as a *json.SyntaxError rather than as
+// io.ErrUnexpectedEOF like json.Decoder.Decode does.
+func isEndOfInput(err error) bool {
+	if serr, ok := err.(*json.SyntaxError); ok {
+		return serr.Error() == "unexpected end of JSON input"
+	}
+	return err == io.EOF || err == io.ErrUnexpectedEOF
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if tok != delim {
+		return fmt.Errorf("expecting %q got %v", delim, tok)
+	}
+	return nil
+}
+
+// statusCode returns the HTTP status code of
+// an error returned by doHTTPReq, otherwise 0.
+func statusCode(err error) int {
+	switch err := err.(type) {
+	case *Error:
+		for _, sce := range err.Errors {
+			if sce != nil && sce.Code != 0 {
+				return sce.Code
+			}
+		}
+	case interface{ Code() int }:
+		return err.Code()
+	}
+	return 0
+}
+
+// maxDrainBytes is the most that drainAndClose reads from a body.
+// Larger remainders are cheaper to discard with the connection.
+const maxDrainBytes = 256 << 10
+
+// drainAndClose reads any unread bytes of body before closing it
+// so that the underlying keep-alive connection can be reused.
+func drainAndClose(body io.ReadCloser) {
+	io.Copy(ioutil.Discard, io.LimitReader(body, maxDrainBytes))
+	body.Close()
+}
+
+func isRedirect(code int) bool {
+	return code >= 300 && code <= 399
+}
+
+func isHTML(hdr http.Header) bool {
+	return strings.HasPrefix(hdr.Get("Content-Type"), "text/html")
+}
+
+// isLoginPage reports whether res is a redirect or the HTML page that the
+// API sends back instead of JSON when the credentials aren't accepted.
+// HTML error pages of other statuses, such as those of a 502 sent by a
+// gateway, aren't login pages.
+func isLoginPage(res *http.Response) bool {
+	if isRedirect(res.StatusCode) {
+		return true
+	}
+	if !isHTML(res.Header) {
+		return false
+	}
+	return otils.StatusOK(res.StatusCode) || res.StatusCode == http.StatusUnauthorized
 }
 
 func NewClientFromOAuth2Token(token *oauth2.Token) (*Client, error) {
@@ -0,0 +1,119 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"errors"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const deepLinkBaseURL = "https://m.uber.com/ul/"
+
+// DeepLinkLocation is a pickup or dropoff
+// location of a ride prefilled by a deep link.
+type DeepLinkLocation struct {
+	Latitude  float64
+	Longitude float64
+
+	// Nickname is the name displayed for the location e.g "Uber HQ".
+	Nickname string
+
+	// FormattedAddress is the address displayed for the location.
+	FormattedAddress string
+}
+
+// DeepLinkParams are the details of the ride
+// that a deep link opens the Uber app with.
+type DeepLinkParams struct {
+	// ClientID is the client ID of your application. It is required.
+	ClientID string
+
+	// ProductID if set, preselects the product.
+	ProductID string
+
+	// Pickup if nil, defaults to the rider's current location.
+	Pickup *DeepLinkLocation
+
+	// Dropoff if nil, lets the rider enter the destination in the app.
+	Dropoff *DeepLinkLocation
+}
+
+var (
+	errBlankClientID           = errors.New("expecting a non-blank client ID")
+	errInvalidDeepLinkLocation = errors.New("expecting a location with a latitude in [-90, 90] and longitude in [-180, 180] that are not both 0")
+)
+
+// Validate returns an error if the deep link can't be built from params.
+func (params *DeepLinkParams) Validate() error {
+	if strings.TrimSpace(params.ClientID) == "" {
+		return errBlankClientID
+	}
+	for _, loc := range []*DeepLinkLocation{params.Pickup, params.Dropoff} {
+		if loc == nil {
+			continue
+		}
+		if loc.Latitude == 0 && loc.Longitude == 0 {
+			return errInvalidDeepLinkLocation
+		}
+		if loc.Latitude < -90 || loc.Latitude > 90 || loc.Longitude < -180 || loc.Longitude > 180 {
+			return errInvalidDeepLinkLocation
+		}
+	}
+	return nil
+}
+
+// DeepLink returns a universal link that opens the Uber app, or
+// m.uber.com if the app isn't installed, with a ride request prefilled
+// with params. It doesn't make any network requests.
+// See https://developer.uber.com/docs/riders/ride-requests/tutorials/deep-links/introduction
+func DeepLink(params DeepLinkParams) (string, error) {
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+
+	// The parameters are written in the order that Uber documents
+	// them, which url.Values.Encode would otherwise sort.
+	var pairs []string
+	add := func(key, value string) {
+		if value == "" {
+			return
+		}
+		// The app expects spaces to be escaped as %20 not "+".
+		escaped := strings.Replace(url.QueryEscape(value), "+", "%20", -1)
+		pairs = append(pairs, key+"="+escaped)
+	}
+	addLocation := func(name string, loc *DeepLinkLocation) {
+		add(name+"[latitude]", strconv.FormatFloat(loc.Latitude, 'f', -1, 64))
+		add(name+"[longitude]", strconv.FormatFloat(loc.Longitude, 'f', -1, 64))
+		add(name+"[nickname]", strings.TrimSpace(loc.Nickname))
+		add(name+"[formatted_address]", strings.TrimSpace(loc.FormattedAddress))
+	}
+
+	add("client_id", strings.TrimSpace(params.ClientID))
+	add("action", "setPickup")
+	if params.Pickup == nil {
+		add("pickup", "my_location")
+	} else {
+		addLocation("pickup", params.Pickup)
+	}
+	if params.Dropoff != nil {
+		addLocation("dropoff", params.Dropoff)
+	}
+	add("product_id", strings.TrimSpace(params.ProductID))
+
+	return deepLinkBaseURL + "?" + strings.Join(pairs, "&"), nil
+}
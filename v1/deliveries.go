@@ -44,6 +44,16 @@ type DeliveryRequest struct {
 	// The details of the delivery pickup.
 	Pickup  *Endpoint `json:"pickup"`
 	Dropoff *Endpoint `json:"dropoff"`
+
+	// PaymentMethodID is the unique identifier of the payment method
+	// that pays for the delivery, as returned by ListPaymentMethods.
+	// If not set, the account's default payment method is used.
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+
+	// IdempotencyKey if set, is sent as the Idempotency-Key header
+	// so that retrying the request doesn't create another delivery.
+	// See also Client.SetAutoIdempotency.
+	IdempotencyKey string `json:"-"`
 }
 
 type Item struct {
@@ -93,6 +103,10 @@ type Contact struct {
 	// Uber send SMS delivery notifications.
 	// This field is optional and defaults to true.
 	SendSMSNotifications bool `json:"send_sms_notifications,omitempty"`
+
+	// Location is only set for the courier of
+	// a delivery that is being delivered.
+	Location *Location `json:"location,omitempty"`
 }
 
 type Phone struct {
@@ -129,6 +143,22 @@ type Delivery struct {
 	Batch *Batch `json:"batch"`
 }
 
+var _ json.Unmarshaler = (*Delivery)(nil)
+
+func (d *Delivery) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type delivery Delivery
+	recv := struct {
+		*delivery
+		Fee flexibleFloat `json:"fee"`
+	}{delivery: (*delivery)(d)}
+	if err := json.Unmarshal(b, &recv); err != nil {
+		return err
+	}
+	d.Fee = float32(recv.Fee)
+	return nil
+}
+
 type Batch struct {
 	// Unique identifier of the batch. Deliveries
 	// in the same batch share the same identifier.
@@ -141,17 +171,22 @@ type Batch struct {
 }
 
 var (
+	errNilDeliveryRequest = errors.New("expecting a non-nil deliveryRequest")
+
 	errNilPickup  = errors.New("a non-nil pickup is required")
 	errNilDropoff = errors.New("a non-nil pickup is required")
 
 	errNilEndpointLocation = errors.New("a non-nil endpoint.location is required")
 	errNilEndpointContact  = errors.New("a non-nil endpoint.contact is required")
 
-	errInvalidItems = errors.New("expecting at least one valid item")
+	errNoItems = errors.New("expecting at least one item")
 )
 
 func (dr *DeliveryRequest) Validate() error {
-	if dr == nil || dr.Pickup == nil {
+	if dr == nil {
+		return errNilDeliveryRequest
+	}
+	if dr.Pickup == nil {
 		return errNilPickup
 	}
 	if err := dr.Pickup.Validate(); err != nil {
@@ -160,10 +195,7 @@ func (dr *DeliveryRequest) Validate() error {
 	if err := dr.Dropoff.Validate(); err != nil {
 		return err
 	}
-	if !atLeastOneValidItem(dr.Items) {
-		return errInvalidItems
-	}
-	return nil
+	return validateItems(dr.Items)
 }
 
 var (
@@ -181,13 +213,19 @@ func (i *Item) Validate() error {
 	return nil
 }
 
-func atLeastOneValidItem(items []*Item) bool {
-	for _, item := range items {
-		if err := item.Validate(); err == nil {
-			return true
+// validateItems checks that there is at least one item and that every
+// item is valid, e.g that none has a zero or negative quantity, which
+// Uber would otherwise reject with an unhelpful 400.
+func validateItems(items []*Item) error {
+	if len(items) == 0 {
+		return errNoItems
+	}
+	for i, item := range items {
+		if err := item.Validate(); err != nil {
+			return fmt.Errorf("item #%d: %v", i, err)
 		}
 	}
-	return false
+	return nil
 }
 
 func (e *Endpoint) Validate() error {
@@ -205,7 +243,11 @@ func (c *Client) RequestDelivery(req *DeliveryRequest) (*Delivery, error) {
 		return nil, err
 	}
 
-	blob, err := json.Marshal(req)
+	blob, err := MarshalCanonical(req)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyKey, err := c.idempotencyKey(req.IdempotencyKey)
 	if err != nil {
 		return nil, err
 	}
@@ -214,13 +256,16 @@ func (c *Client) RequestDelivery(req *DeliveryRequest) (*Delivery, error) {
 	if err != nil {
 		return nil, err
 	}
+	if idempotencyKey != "" {
+		httpReq.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
 
 	blob, _, err = c.doHTTPReq(httpReq)
 	if err != nil {
 		return nil, err
 	}
 	dRes := new(Delivery)
-	if err := json.Unmarshal(blob, dRes); err != nil {
+	if err := c.unmarshalResponse(blob, dRes); err != nil {
 		return nil, err
 	}
 	return dRes, nil
@@ -231,6 +276,8 @@ var errBlankDeliveryID = errors.New("expecting a non-blank deliveryID")
 // CancelDelivery cancels a delivery referenced by its ID. There are
 // potential cancellation fees associated.
 // See https://developer.uber.com/docs/deliveries/faq for more information.
+// With SetIdempotentCancel(true), a delivery that Uber reports as not
+// found or already canceled is treated as successfully canceled.
 func (c *Client) CancelDelivery(deliveryID string) error {
 	deliveryID = strings.TrimSpace(deliveryID)
 	if deliveryID == "" {
@@ -241,10 +288,110 @@ func (c *Client) CancelDelivery(deliveryID string) error {
 	if err != nil {
 		return err
 	}
-	_, _, err = c.doHTTPReq(httpReq)
+	_, res, err := c.doHTTPReqWithResponse(httpReq)
+	if err != nil && res != nil && c.cancelsIdempotently() {
+		switch {
+		case res.StatusCode == http.StatusNotFound,
+			res.StatusCode == http.StatusConflict && isAlreadyCanceled(err):
+			// The delivery is already canceled or gone,
+			// which is the state that was asked for.
+			return nil
+		}
+	}
 	return err
 }
 
+// isAlreadyCanceled reports whether err is an Uber error whose
+// code says that what was to be canceled was already canceled.
+func isAlreadyCanceled(err error) bool {
+	ue, ok := err.(*Error)
+	if !ok || ue == nil {
+		return false
+	}
+	for _, sce := range ue.Errors {
+		if sce != nil && strings.HasSuffix(sce.Message, "already_canceled") {
+			return true
+		}
+	}
+	return false
+}
+
+// SetIdempotentCancel controls whether CancelDelivery treats a 404, which
+// means that the delivery wasn't found, or a 409 whose error code says that
+// the delivery was already canceled, as success since the end state is the
+// desired one. Other conflicts, such as with a delivery that was already
+// picked up, are still returned as errors. It is off by default and those
+// responses are then returned as errors.
+func (c *Client) SetIdempotentCancel(idempotent bool) {
+	c.Lock()
+	c.idempotentCancel = idempotent
+	c.Unlock()
+}
+
+func (c *Client) cancelsIdempotently() bool {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.idempotentCancel
+}
+
+// CourierLocation is the location of the courier of a delivery.
+type CourierLocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+
+	// Bearing is the current bearing of the courier in degrees (0-359).
+	Bearing int `json:"bearing"`
+}
+
+// ErrDeliveryNotTrackable is returned when the courier of a delivery
+// can't be tracked since the delivery isn't being delivered e.g it is
+// still being matched to a courier or it was already completed.
+var ErrDeliveryNotTrackable = errors.New("delivery is not in a trackable state")
+
+func (d *Delivery) trackable() bool {
+	switch d.Status {
+	case StatusEnRouteToPickup, StatusAtPickup, StatusEnRouteToDropoff, StatusAtDropoff, StatusReturning:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeliveryCourierLocation returns the current location of the courier
+// of a delivery. It returns ErrDeliveryNotTrackable if the delivery
+// isn't being delivered or Uber didn't send the courier's location.
+func (c *Client) DeliveryCourierLocation(deliveryID string) (*CourierLocation, error) {
+	deliveryID = strings.TrimSpace(deliveryID)
+	if deliveryID == "" {
+		return nil, errBlankDeliveryID
+	}
+	theURL := fmt.Sprintf("%s/deliveries/%s", c.baseURL(), url.PathEscape(deliveryID))
+	httpReq, err := http.NewRequest("GET", theURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	blob, _, err := c.doHTTPReq(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	delivery := new(Delivery)
+	if err := c.unmarshalResponse(blob, delivery); err != nil {
+		return nil, err
+	}
+
+	if !delivery.trackable() || delivery.Courier == nil || delivery.Courier.Location == nil {
+		return nil, ErrDeliveryNotTrackable
+	}
+
+	loc := delivery.Courier.Location
+	return &CourierLocation{
+		Latitude:  loc.Latitude,
+		Longitude: loc.Longitude,
+		Bearing:   loc.Bearing,
+	}, nil
+}
+
 type DeliveryListRequest struct {
 	Status        Status `json:"status,omitempty"`
 	LimitPerPage  int64  `json:"limit"`
@@ -272,6 +419,21 @@ type recvDelivery struct {
 	Deliveries        []*Delivery `json:"deliveries"`
 }
 
+// itemDecoders decodes the deliveries of a
+// truncated page, appending them to rd.
+func (rd *recvDelivery) itemDecoders() map[string]func(*json.Decoder) error {
+	return map[string]func(*json.Decoder) error{
+		"deliveries": func(dec *json.Decoder) error {
+			delivery := new(Delivery)
+			if err := dec.Decode(delivery); err != nil {
+				return err
+			}
+			rd.Deliveries = append(rd.Deliveries, delivery)
+			return nil
+		},
+	}
+}
+
 type deliveryPager struct {
 	Offset int64  `json:"offset"`
 	Limit  int64  `json:"limit"`
@@ -352,26 +514,28 @@ func (c *Client) ListDeliveries(dReq *DeliveryListRequest) (*DeliveryThread, err
 			req, err := http.NewRequest("GET", fullURL, nil)
 			if err != nil {
 				page.Err = err
-				resChan <- page
+				sendPage(cancelChan, nil, resChan, page)
 				return
 			}
 
+			recv := new(recvDelivery)
 			slurp, _, err := c.doReq(req)
+			if err == nil {
+				err = c.unmarshalResponse(slurp, recv)
+			}
 			if err != nil {
-				page.Err = err
-				resChan <- page
+				partial := new(recvDelivery)
+				if page.Err = truncatedResponse(slurp, err, partial.itemDecoders()); page.Err != err {
+					page.Deliveries = partial.Deliveries
+				}
+				sendPage(cancelChan, nil, resChan, page)
 				return
 			}
 
-			recv := new(recvDelivery)
-			if err := json.Unmarshal(slurp, recv); err != nil {
-				page.Err = err
-				resChan <- page
+			page.Deliveries = recv.Deliveries
+			if !sendPage(cancelChan, nil, resChan, page) {
 				return
 			}
-
-			page.Deliveries = recv.Deliveries
-			resChan <- page
 			pageNumber += 1
 			pageToken := recv.NextPageQuery
 			if pageExceeded(pageNumber) || pageToken == "" || len(recv.Deliveries) == 0 {
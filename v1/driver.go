@@ -15,9 +15,15 @@
 package uber
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/orijtech/otils"
@@ -35,7 +41,7 @@ const (
 const driverV1API = "v1"
 
 func (c *Client) DriverProfile() (*Profile, error) {
-	return c.retrieveProfile("/partners/me", driverV1API)
+	return c.retrieveProfile("/partners/me", nil, c.driverAPIVersion())
 }
 
 type PaymentCategory string
@@ -45,6 +51,8 @@ const (
 	CategoryDevicePayment  PaymentCategory = "device_payment"
 	CategoryVehiclePayment PaymentCategory = "vehicle_payment"
 	CategoryPromotion      PaymentCategory = "promotion"
+	CategoryTip            PaymentCategory = "tip"
+	CategoryToll           PaymentCategory = "toll"
 	CategoryOther          PaymentCategory = "other"
 )
 
@@ -52,6 +60,16 @@ const (
 	defaultDriverPaymentsLimitPerPage = 50
 
 	defaultThrottleDuration = 150 * time.Millisecond
+
+	defaultMaxRateLimitRetries = 5
+
+	// NoRateLimitRetries as a DriverInfoQuery's MaxRateLimitRetries
+	// turns off the retrying of the pages that are rate limited.
+	NoRateLimitRetries = -1
+
+	// maxRateLimitBackoff caps the wait before retrying a rate limited
+	// page when Uber doesn't send back a Retry-After header.
+	maxRateLimitBackoff = 30 * time.Second
 )
 
 type DriverInfoResponse struct {
@@ -67,9 +85,35 @@ type driverInfoWrap struct {
 	Trips    []*Trip    `json:"trips"`
 }
 
+// itemDecoders decodes the trips and payments of
+// a truncated page, appending them to diw.
+func (diw *driverInfoWrap) itemDecoders() map[string]func(*json.Decoder) error {
+	return map[string]func(*json.Decoder) error{
+		"trips": func(dec *json.Decoder) error {
+			trip := new(Trip)
+			if err := dec.Decode(trip); err != nil {
+				return err
+			}
+			diw.Trips = append(diw.Trips, trip)
+			return nil
+		},
+		"payments": func(dec *json.Decoder) error {
+			payment := new(Payment)
+			if err := dec.Decode(payment); err != nil {
+				return err
+			}
+			diw.Payments = append(diw.Payments, payment)
+			return nil
+		},
+	}
+}
+
 func (dpq *DriverInfoQuery) toRealDriverQuery() *realDriverQuery {
 	rdpq := &realDriverQuery{
 		Offset: dpq.Offset,
+		Status: dpq.Status,
+
+		ProductID: dpq.ProductID,
 	}
 	if dpq.StartDate != nil {
 		rdpq.StartTimeUnix = dpq.StartDate.Unix()
@@ -86,10 +130,12 @@ func (dpq *DriverInfoQuery) toRealDriverQuery() *realDriverQuery {
 // easy API usage from callers e.g passing in a date without
 // having to worry about its exact Unix timestamp.
 type realDriverQuery struct {
-	Offset        int   `json:"offset,omitempty"`
-	LimitPerPage  int   `json:"limit,omitempty"`
-	StartTimeUnix int64 `json:"from_time,omitempty"`
-	EndTimeUnix   int64 `json:"to_time,omitempty"`
+	Offset        int    `json:"offset,omitempty"`
+	LimitPerPage  int    `json:"limit,omitempty"`
+	StartTimeUnix int64  `json:"from_time,omitempty"`
+	EndTimeUnix   int64  `json:"to_time,omitempty"`
+	Status        Status `json:"status,omitempty"`
+	ProductID     string `json:"product_id,omitempty"`
 }
 
 type DriverInfoQuery struct {
@@ -105,6 +151,52 @@ type DriverInfoQuery struct {
 	MaxPageNumber int `json:"max_page_number,omitempty"`
 
 	Throttle time.Duration `json:"throttle,omitempty"`
+
+	// MaxRateLimitRetries is the number of times that a page is
+	// retried when Uber rate limits the request with a 429, after
+	// waiting for as long as its Retry-After header asks, before the
+	// page is sent with the error. It defaults to 5 retries while
+	// NoRateLimitRetries sends the error of the first 429 right away.
+	MaxRateLimitRetries int `json:"max_rate_limit_retries,omitempty"`
+
+	// SortByTime if set, sorts the trips and payments of every page
+	// by time, oldest first. The sort is stable so items with the
+	// same time retain the order in which the server sent them.
+	// To sort all the results across pages, use SortTripsByTime
+	// or SortPaymentsByTime on the aggregated results.
+	SortByTime bool `json:"sort_by_time,omitempty"`
+
+	// Status if set, only retrieves the trips with that status:
+	// StatusCompleted, StatusRiderCanceled or StatusDriverCanceled.
+	// It is ignored by ListDriverPayments.
+	Status Status `json:"status,omitempty"`
+
+	// ProductID if set, only retrieves the trips made
+	// with that product e.g the product of uberX.
+	// It is ignored by ListDriverPayments.
+	ProductID string `json:"product_id,omitempty"`
+}
+
+var errInvalidDriverTripStatus = errors.New("expecting a trip status of either completed, rider_canceled or driver_canceled")
+
+func validateDriverTripStatus(status Status) error {
+	switch status {
+	case "", StatusCompleted, StatusRiderCanceled, StatusDriverCanceled:
+		return nil
+	default:
+		return errInvalidDriverTripStatus
+	}
+}
+
+var errBlankProductID = errors.New("expecting a non-blank productID")
+
+// validateProductIDFilter accepts an unset productID
+// but rejects one that is set to only whitespace.
+func validateProductIDFilter(productID string) error {
+	if productID != "" && strings.TrimSpace(productID) == "" {
+		return errBlankProductID
+	}
+	return nil
 }
 
 type DriverInfoPage struct {
@@ -114,7 +206,20 @@ type DriverInfoPage struct {
 	Err        error      `json:"error"`
 }
 
+// ErrPaginationStalled is sent as the error of the last page when
+// the offsets of the pages sent back by Uber stop advancing, which
+// would otherwise make the paging retrieve the same page forever.
+var ErrPaginationStalled = errors.New("pagination stalled: the page offset is not advancing")
+
 func (c *Client) ListDriverTrips(dpq *DriverInfoQuery) (*DriverInfoResponse, error) {
+	if dpq != nil {
+		if err := validateDriverTripStatus(dpq.Status); err != nil {
+			return nil, err
+		}
+		if err := validateProductIDFilter(dpq.ProductID); err != nil {
+			return nil, err
+		}
+	}
 	return c.listDriverInfo(dpq, "/partners/trips")
 }
 
@@ -128,6 +233,13 @@ func (c *Client) ListDriverTrips(dpq *DriverInfoQuery) (*DriverInfoResponse, err
 // array. Drivers working for fleet managers will receive payments from the fleet
 // manager and not from Uber.
 func (c *Client) ListDriverPayments(dpq *DriverInfoQuery) (*DriverInfoResponse, error) {
+	if dpq != nil && (dpq.Status != "" || dpq.ProductID != "") {
+		// Payments can't be filtered by trip status nor product.
+		paymentsQuery := *dpq
+		paymentsQuery.Status = ""
+		paymentsQuery.ProductID = ""
+		dpq = &paymentsQuery
+	}
 	return c.listDriverInfo(dpq, "/partners/payments")
 }
 
@@ -143,12 +255,19 @@ func (c *Client) listDriverInfo(dpq *DriverInfoQuery, path string) (*DriverInfoR
 		throttleDuration = defaultThrottleDuration
 	}
 
+	maxRetries := dpq.MaxRateLimitRetries
+	if maxRetries == NoRateLimitRetries {
+		maxRetries = 0
+	} else if maxRetries <= 0 {
+		maxRetries = defaultMaxRateLimitRetries
+	}
+
 	maxPageNumber := dpq.MaxPageNumber
 	pageExceeds := func(pageNumber int) bool {
 		return maxPageNumber > 0 && pageNumber >= maxPageNumber
 	}
 
-	baseURL := fmt.Sprintf("%s%s", c.baseURL(driverV1API), path)
+	baseURL := fmt.Sprintf("%s%s", c.baseURL(c.driverAPIVersion()), path)
 	rdpq := dpq.toRealDriverQuery()
 	limitPerPage := rdpq.LimitPerPage
 	if limitPerPage <= 0 {
@@ -160,7 +279,30 @@ func (c *Client) listDriverInfo(dpq *DriverInfoQuery, path string) (*DriverInfoR
 	go func() {
 		defer close(resChan)
 
+		// fetchPage retrieves fullURL, retrying it after a 429 for up
+		// to maxRetries times. It gives up if the paging is canceled.
+		fetchPage := func(fullURL string) ([]byte, error) {
+			for attempt := 0; ; attempt++ {
+				req, err := http.NewRequest("GET", fullURL, nil)
+				if err != nil {
+					return nil, err
+				}
+				req.Header.Set("Authorization", c.bearerToken())
+				blob, res, err := c.doHTTPReqWithResponse(req)
+				if err == nil || res == nil || res.StatusCode != http.StatusTooManyRequests || attempt >= maxRetries {
+					return blob, err
+				}
+
+				select {
+				case <-cancelChan:
+					return nil, err
+				case <-time.After(rateLimitBackoff(res.Header, attempt)):
+				}
+			}
+		}
+
 		pageNumber := 0
+		prevOffset := 0
 
 		for {
 			curPage := new(DriverInfoPage)
@@ -169,7 +311,7 @@ func (c *Client) listDriverInfo(dpq *DriverInfoQuery, path string) (*DriverInfoR
 			qv, err := otils.ToURLValues(rdpq)
 			if err != nil {
 				curPage.Err = err
-				resChan <- curPage
+				sendPage(cancelChan, nil, resChan, curPage)
 				return
 			}
 
@@ -178,41 +320,57 @@ func (c *Client) listDriverInfo(dpq *DriverInfoQuery, path string) (*DriverInfoR
 				fullURL += "?" + qv.Encode()
 			}
 
-			req, err := http.NewRequest("GET", fullURL, nil)
-			if err != nil {
-				curPage.Err = err
-				resChan <- curPage
-				return
+			recv := new(driverInfoWrap)
+			blob, err := fetchPage(fullURL)
+			if err == nil {
+				err = c.unmarshalResponse(blob, recv)
 			}
-			blob, _, err := c.doAuthAndHTTPReq(req)
 			if err != nil {
-				curPage.Err = err
-				resChan <- curPage
+				partial := new(driverInfoWrap)
+				if curPage.Err = truncatedResponse(blob, err, partial.itemDecoders()); curPage.Err != err {
+					curPage.Trips, curPage.Payments = partial.Trips, partial.Payments
+				}
+				sendPage(cancelChan, nil, resChan, curPage)
 				return
 			}
 
-			recv := new(driverInfoWrap)
-			if err := json.Unmarshal(blob, recv); err != nil {
-				curPage.Err = err
-				resChan <- curPage
+			// No payments nor trips sent back, so a sign that we are at the end
+			if len(recv.Payments) == 0 && len(recv.Trips) == 0 {
 				return
 			}
 
-			// No payments nor trips sent back, so a sign that we are at the end
-			if len(recv.Payments) == 0 && len(recv.Trips) == 0 {
+			// Every page after the first must be further
+			// along otherwise it was already retrieved.
+			if pageNumber > 0 && recv.Offset <= prevOffset {
+				curPage.Err = ErrPaginationStalled
+				sendPage(cancelChan, nil, resChan, curPage)
 				return
 			}
+			prevOffset = recv.Offset
+
+			if dpq.SortByTime {
+				SortTripsByTime(recv.Trips)
+				SortPaymentsByTime(recv.Payments)
+			}
 
 			curPage.Trips = recv.Trips
 			curPage.Payments = recv.Payments
 
-			resChan <- curPage
+			if !sendPage(cancelChan, nil, resChan, curPage) {
+				return
+			}
 
 			pageNumber += 1
 			if pageExceeds(pageNumber) {
 				return
 			}
 
+			if recv.Limit <= 0 {
+				// The next offset wouldn't advance.
+				sendPage(cancelChan, nil, resChan, &DriverInfoPage{PageNumber: pageNumber, Err: ErrPaginationStalled})
+				return
+			}
+
 			select {
 			case <-cancelChan:
 				return
@@ -230,3 +388,417 @@ func (c *Client) listDriverInfo(dpq *DriverInfoQuery, path string) (*DriverInfoR
 
 	return resp, nil
 }
+
+// rateLimitBackoff returns how long to wait before retrying a request
+// that was rate limited for the attempt-th time. It is the duration
+// asked for by the Retry-After header, in either seconds or as an HTTP
+// date, otherwise an exponential backoff starting at one second.
+func rateLimitBackoff(header http.Header, attempt int) time.Duration {
+	if retryAfter := strings.TrimSpace(header.Get("Retry-After")); retryAfter != "" {
+		if secs, err := strconv.Atoi(retryAfter); err == nil && secs >= 0 {
+			return time.Duration(secs) * time.Second
+		}
+		if date, err := http.ParseTime(retryAfter); err == nil {
+			if wait := time.Until(date); wait > 0 {
+				return wait
+			}
+			return 0
+		}
+	}
+
+	backoff := maxRateLimitBackoff
+	if attempt < 5 {
+		backoff = time.Second << uint(attempt)
+	}
+	return backoff
+}
+
+// SortTripsByTime stably sorts trips by their start time, oldest first.
+// A trip's start time is its StartTimeUnix if set, otherwise the
+// timestamp of its pickup or lastly the timestamp of its dropoff.
+func SortTripsByTime(trips []*Trip) {
+	sort.SliceStable(trips, func(i, j int) bool {
+		return trips[i].timestampUnix() < trips[j].timestampUnix()
+	})
+}
+
+// IsTip reports whether the payment is a tip from a rider.
+func (p *Payment) IsTip() bool {
+	return p != nil && p.Category == CategoryTip
+}
+
+// FilterPayments returns the payments of the given category
+// in their original order e.g to sum up tips separately.
+func FilterPayments(payments []*Payment, category PaymentCategory) []*Payment {
+	var filtered []*Payment
+	for _, payment := range payments {
+		if payment != nil && payment.Category == category {
+			filtered = append(filtered, payment)
+		}
+	}
+	return filtered
+}
+
+// PaymentSummary totals a driver's payments by category.
+type PaymentSummary struct {
+	// NetEarnings is the total of the fare payments.
+	NetEarnings Money `json:"net_earnings"`
+
+	Tips       Money `json:"tips"`
+	Tolls      Money `json:"tolls"`
+	Promotions Money `json:"promotions"`
+
+	// Other is the total of the payments of every other
+	// category e.g device and vehicle payments.
+	Other Money `json:"other"`
+
+	// Total is the grand total of all the payments.
+	Total Money `json:"total"`
+
+	// PaymentCount is the number of payments summarized.
+	PaymentCount int `json:"payment_count"`
+}
+
+var ErrMixedCurrencies = errors.New("cannot summarize payments in different currencies")
+
+// DriverPaymentSummary pages through all the payments matching query
+// and totals them by category. It returns ErrMixedCurrencies if the
+// payments aren't all in the same currency.
+func (c *Client) DriverPaymentSummary(query *DriverInfoQuery) (*PaymentSummary, error) {
+	dres, err := c.ListDriverPayments(query)
+	if err != nil {
+		return nil, err
+	}
+	defer dres.Cancel()
+
+	var payments []*Payment
+	for page := range dres.Pages {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		payments = append(payments, page.Payments...)
+	}
+	return summarizePayments(payments)
+}
+
+// DriverEarningsByDay pages through all the payments matching query and
+// totals them by calendar day, in the timezone set with SetTimezone. The
+// days are keyed in the "2006-01-02" format and a day's total is the net
+// payout of all its payments, that is including tips and promotions and
+// minus charges. Payments without an event time are left out. As with
+// DriverPaymentSummary, it returns ErrMixedCurrencies if the payments
+// aren't all in the same currency.
+func (c *Client) DriverEarningsByDay(query *DriverInfoQuery) (map[string]Money, error) {
+	dres, err := c.ListDriverPayments(query)
+	if err != nil {
+		return nil, err
+	}
+	defer dres.Cancel()
+
+	var payments []*Payment
+	for page := range dres.Pages {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		payments = append(payments, page.Payments...)
+	}
+	return earningsByDay(payments, c.getTimezone())
+}
+
+func earningsByDay(payments []*Payment, timezone *time.Location) (map[string]Money, error) {
+	// Checking the currencies of all the payments at once
+	// since each day could otherwise be in a different one.
+	summary, err := summarizePayments(payments)
+	if err != nil {
+		return nil, err
+	}
+
+	earnings := make(map[string]Money)
+	for _, payment := range payments {
+		if payment == nil || payment.EventTime <= 0 {
+			continue
+		}
+		day := time.Unix(int64(payment.EventTime), 0).In(timezone).Format("2006-01-02")
+		dayEarnings := earnings[day]
+		dayEarnings.Amount += float64(payment.Amount)
+		dayEarnings.CurrencyCode = summary.Total.CurrencyCode
+		earnings[day] = dayEarnings
+	}
+	return earnings, nil
+}
+
+func summarizePayments(payments []*Payment) (*PaymentSummary, error) {
+	var currency CurrencyCode
+	summary := new(PaymentSummary)
+	for _, payment := range payments {
+		if payment == nil {
+			continue
+		}
+
+		// Payments without a currency are assumed to be in that of the rest.
+		if code := CurrencyCode(strings.ToUpper(strings.TrimSpace(string(payment.CurrencyCode)))); code != "" {
+			if currency != "" && code != currency {
+				return nil, ErrMixedCurrencies
+			}
+			currency = code
+		}
+
+		amount := float64(payment.Amount)
+		switch payment.Category {
+		case CategoryFare:
+			summary.NetEarnings.Amount += amount
+		case CategoryTip:
+			summary.Tips.Amount += amount
+		case CategoryToll:
+			summary.Tolls.Amount += amount
+		case CategoryPromotion:
+			summary.Promotions.Amount += amount
+		default:
+			summary.Other.Amount += amount
+		}
+		summary.Total.Amount += amount
+		summary.PaymentCount++
+	}
+
+	for _, money := range []*Money{&summary.NetEarnings, &summary.Tips, &summary.Tolls, &summary.Promotions, &summary.Other, &summary.Total} {
+		money.CurrencyCode = currency
+	}
+	return summary, nil
+}
+
+// SortPaymentsByTime stably sorts payments by their EventTime, oldest first.
+func SortPaymentsByTime(payments []*Payment) {
+	sort.SliceStable(payments, func(i, j int) bool {
+		return payments[i].EventTime < payments[j].EventTime
+	})
+}
+
+func (t *Trip) timestampUnix() int64 {
+	switch {
+	case t == nil:
+		return 0
+	case t.StartTimeUnix != 0:
+		return t.StartTimeUnix
+	case t.Pickup != nil && t.Pickup.TimestampUnix != 0:
+		return t.Pickup.TimestampUnix
+	case t.Dropoff != nil:
+		return t.Dropoff.TimestampUnix
+	default:
+		return 0
+	}
+}
+
+// DriverActivity summarizes the trips of a driver over a period.
+// Uber doesn't expose the sessions during which a driver is online,
+// so the online time is approximated from the trips themselves.
+type DriverActivity struct {
+	Trips int `json:"trips"`
+
+	// FirstActiveTime is the time at which the driver accepted
+	// the first trip, or if unknown, picked up its rider.
+	FirstActiveTime time.Time `json:"first_active_time,omitempty"`
+
+	// LastActiveTime is the time at which the driver
+	// dropped off the rider of the last trip.
+	LastActiveTime time.Time `json:"last_active_time,omitempty"`
+
+	// OnlineTime is the time from FirstActiveTime to LastActiveTime.
+	OnlineTime time.Duration `json:"online_time,omitempty"`
+
+	// TripTime is the total time spent with
+	// riders on board, from pickup to dropoff.
+	TripTime time.Duration `json:"trip_time,omitempty"`
+
+	// Utilization is the fraction of OnlineTime spent on trips.
+	Utilization float64 `json:"utilization,omitempty"`
+}
+
+// DriverActivity returns the online time, trip time and utilization
+// of the driver over the range of the query, by aggregating all
+// the pages of trips that ListDriverTrips returns for it.
+func (c *Client) DriverActivity(dpq *DriverInfoQuery) (*DriverActivity, error) {
+	dres, err := c.ListDriverTrips(dpq)
+	if err != nil {
+		return nil, err
+	}
+	defer dres.Cancel()
+
+	var firstUnix, lastUnix int64
+	activity := new(DriverActivity)
+	for page := range dres.Pages {
+		if err := page.Err; err != nil {
+			return nil, err
+		}
+
+		for _, trip := range page.Trips {
+			if trip == nil {
+				continue
+			}
+			activity.Trips += 1
+			activity.TripTime += trip.onTripDuration()
+
+			if startUnix := trip.activeStartUnix(); startUnix > 0 && (firstUnix == 0 || startUnix < firstUnix) {
+				firstUnix = startUnix
+			}
+			if endUnix := trip.activeEndUnix(); endUnix > lastUnix {
+				lastUnix = endUnix
+			}
+		}
+	}
+
+	if firstUnix > 0 && lastUnix > firstUnix {
+		activity.FirstActiveTime = time.Unix(firstUnix, 0)
+		activity.LastActiveTime = time.Unix(lastUnix, 0)
+		activity.OnlineTime = activity.LastActiveTime.Sub(activity.FirstActiveTime)
+		activity.Utilization = float64(activity.TripTime) / float64(activity.OnlineTime)
+	}
+
+	return activity, nil
+}
+
+func (t *Trip) statusChangeUnix(status Status) int64 {
+	for _, change := range t.StatusChanges {
+		if change != nil && change.Status == status {
+			return change.TimestampUnix
+		}
+	}
+	return 0
+}
+
+// activeStartUnix returns the time at which the driver was
+// first busy with the trip, that is when they accepted it.
+func (t *Trip) activeStartUnix() int64 {
+	if acceptedUnix := t.statusChangeUnix(StatusAccepted); acceptedUnix > 0 {
+		return acceptedUnix
+	}
+	return t.timestampUnix()
+}
+
+func (t *Trip) activeEndUnix() int64 {
+	if t.Dropoff != nil && t.Dropoff.TimestampUnix > 0 {
+		return t.Dropoff.TimestampUnix
+	}
+	return t.statusChangeUnix(StatusCompleted)
+}
+
+func (t *Trip) onTripDuration() time.Duration {
+	if t.Pickup != nil && t.Dropoff != nil && t.Dropoff.TimestampUnix > t.Pickup.TimestampUnix && t.Pickup.TimestampUnix > 0 {
+		return time.Duration(t.Dropoff.TimestampUnix-t.Pickup.TimestampUnix) * time.Second
+	}
+	return time.Duration(float64(t.Duration) * float64(time.Second))
+}
+
+// DriverReceiptPage holds the receipts of a page of the driver's trips.
+type DriverReceiptPage struct {
+	PageNumber int `json:"page_number,omitempty"`
+
+	// Receipts are in the order of the trips of the page.
+	// Trips whose receipt couldn't be retrieved are left out.
+	Receipts []*Receipt `json:"receipts,omitempty"`
+
+	// ReceiptErrs maps the IDs of the trips whose receipt couldn't
+	// be retrieved to the error. Trips without an ID are keyed by
+	// their index in the page e.g "#2".
+	ReceiptErrs map[string]error `json:"-"`
+
+	// Err is set if the page of trips couldn't be retrieved
+	// in which case it is the last page that is sent.
+	Err error `json:"error"`
+}
+
+// maxConcurrentReceiptFetches is the maximum number of receipts
+// that DriverTripReceipts retrieves concurrently.
+const maxConcurrentReceiptFetches = 4
+
+// DriverTripReceipts pages over the driver's trips matching query, as
+// ListDriverTrips does, and retrieves the receipt of every trip. Failing
+// to retrieve a receipt doesn't end the paging, the error is instead
+// recorded in the page's ReceiptErrs.
+func (c *Client) DriverTripReceipts(query *DriverInfoQuery) (pagesChan <-chan *DriverReceiptPage, cancelPaging context.CancelFunc, err error) {
+	dres, err := c.ListDriverTrips(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cancelChan, cancelFn := makeCancelParadigm()
+	receiptPagesChan := make(chan *DriverReceiptPage)
+	go func() {
+		defer close(receiptPagesChan)
+		defer dres.Cancel()
+
+		for {
+			var tripsPage *DriverInfoPage
+			select {
+			case <-cancelChan:
+				return
+			case page, ok := <-dres.Pages:
+				if !ok {
+					return
+				}
+				tripsPage = page
+			}
+
+			receiptsPage := &DriverReceiptPage{PageNumber: tripsPage.PageNumber}
+			if tripsPage.Err != nil {
+				receiptsPage.Err = tripsPage.Err
+				sendPage(cancelChan, nil, receiptPagesChan, receiptsPage)
+				return
+			}
+
+			receiptsPage.Receipts, receiptsPage.ReceiptErrs = c.tripReceipts(tripsPage.Trips)
+			if !sendPage(cancelChan, nil, receiptPagesChan, receiptsPage) {
+				return
+			}
+		}
+	}()
+
+	return receiptPagesChan, cancelFn, nil
+}
+
+// tripReceipts concurrently retrieves the receipts of trips,
+// at most maxConcurrentReceiptFetches at a time.
+func (c *Client) tripReceipts(trips []*Trip) ([]*Receipt, map[string]error) {
+	receipts := make([]*Receipt, len(trips))
+	errs := make([]error, len(trips))
+	tripIDs := make([]string, len(trips))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan bool, maxConcurrentReceiptFetches)
+	for i, trip := range trips {
+		if trip != nil {
+			tripIDs[i] = otils.FirstNonEmptyString(trip.TripID, trip.RequestID)
+		}
+		if tripIDs[i] == "" {
+			tripIDs[i] = fmt.Sprintf("#%d", i)
+			errs[i] = errEmptyTripID
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			receipts[i], errs[i] = c.RequestReceipt(tripIDs[i])
+		}(i)
+	}
+	wg.Wait()
+
+	var found []*Receipt
+	var receiptErrs map[string]error
+	for i, receipt := range receipts {
+		if err := errs[i]; err != nil {
+			if receiptErrs == nil {
+				receiptErrs = make(map[string]error)
+			}
+			receiptErrs[tripIDs[i]] = err
+			continue
+		}
+		if receipt != nil {
+			found = append(found, receipt)
+		}
+	}
+	return found, receiptErrs
+}
@@ -16,7 +16,6 @@ package uber
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -71,7 +70,7 @@ func (c *Client) enrollments(path string, versions ...string) ([]*Enrollment, er
 		return nil, err
 	}
 	values := new(enrollmentsWrap)
-	if err := json.Unmarshal(slurp, values); err != nil {
+	if err := c.unmarshalResponse(slurp, values); err != nil {
 		return nil, err
 	}
 	return values.Enrollments, nil
@@ -96,7 +95,7 @@ func (c *Client) enrollmentByID(path string, versions ...string) (*Enrollment, e
 		return nil, err
 	}
 	value := new(Enrollment)
-	if err := json.Unmarshal(slurp, value); err != nil {
+	if err := c.unmarshalResponse(slurp, value); err != nil {
 		return nil, err
 	}
 	return value, nil
@@ -119,7 +118,7 @@ func (c *Client) UpdateEnrollmentByID(id string, update *EnrollmentUpdate) (*Enr
 }
 
 func (c *Client) updateEnrollmentByID(path string, update *EnrollmentUpdate, versions ...string) (*Enrollment, error) {
-	blob, err := json.Marshal(update)
+	blob, err := MarshalCanonical(update)
 	if err != nil {
 		return nil, err
 	}
@@ -135,7 +134,7 @@ func (c *Client) updateEnrollmentByID(path string, update *EnrollmentUpdate, ver
 	}
 
 	value := new(Enrollment)
-	if err := json.Unmarshal(slurp, value); err != nil {
+	if err := c.unmarshalResponse(slurp, value); err != nil {
 		return nil, err
 	}
 
@@ -368,3 +368,22 @@ func init() {
 func lookupErrorBySignature(signature string) *ActionableError {
 	return actionableErrorsIndex[signature]
 }
+
+// toActionableError returns the ActionableError matching the
+// signature of any of the errors in an Uber error response,
+// otherwise it returns err unchanged.
+func toActionableError(err error) error {
+	ue, ok := err.(*Error)
+	if !ok || ue == nil {
+		return err
+	}
+	for _, sce := range ue.Errors {
+		if sce == nil {
+			continue
+		}
+		if ae := lookupErrorBySignature(sce.Message); ae != nil {
+			return ae
+		}
+	}
+	return err
+}
@@ -0,0 +1,115 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"net/http"
+	"sync"
+)
+
+// SetETagCaching enables caching the profile and the places of the user
+// by their ETag. Once Uber sends back an ETag for either, it is sent as
+// If-None-Match on subsequent requests for it and the cached value is
+// returned if Uber responds with 304 Not Modified. Caching is disabled
+// by default and clones of the client share the cache.
+func (c *Client) SetETagCaching(enabled bool) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.etagCaching = enabled
+	if enabled && c.etagCache == nil {
+		c.etagCache = new(etagCache)
+	}
+}
+
+func (c *Client) getETagCache() *etagCache {
+	c.RLock()
+	defer c.RUnlock()
+
+	if !c.etagCaching {
+		return nil
+	}
+	return c.etagCache
+}
+
+// doETagReq is doReq for requests whose responses are cached
+// by their ETag if SetETagCaching was enabled. Only the responses
+// of GET requests are cached, other requests are sent as is.
+func (c *Client) doETagReq(req *http.Request) ([]byte, http.Header, error) {
+	cache := c.getETagCache()
+	if cache == nil || req.Method != "GET" {
+		return c.doReq(req)
+	}
+
+	if c.hasServerToken() {
+		req.Header.Set("Authorization", c.bearerToken())
+	}
+	// The token is part of the key since the
+	// responses differ from one user to another.
+	key := req.Header.Get("Authorization") + " " + req.URL.String()
+	entry, cached := cache.get(key)
+	if cached {
+		req.Header.Set("If-None-Match", entry.etag)
+	}
+
+	blob, res, err := c.doHTTPReqWithResponse(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	if cached && res.StatusCode == http.StatusNotModified {
+		return entry.blob, res.Header, nil
+	}
+
+	if etag := res.Header.Get("ETag"); etag != "" {
+		cache.put(key, etag, blob)
+	} else {
+		cache.remove(key)
+	}
+	return blob, res.Header, nil
+}
+
+type etagCacheEntry struct {
+	etag string
+	blob []byte
+}
+
+type etagCache struct {
+	mu      sync.Mutex
+	entries map[string]*etagCacheEntry
+}
+
+func (ec *etagCache) get(key string) (*etagCacheEntry, bool) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	entry, ok := ec.entries[key]
+	return entry, ok
+}
+
+func (ec *etagCache) put(key, etag string, blob []byte) {
+	ec.mu.Lock()
+	defer ec.mu.Unlock()
+
+	if ec.entries == nil {
+		ec.entries = make(map[string]*etagCacheEntry)
+	}
+	ec.entries[key] = &etagCacheEntry{etag: etag, blob: blob}
+}
+
+func (ec *etagCache) remove(key string) {
+	ec.mu.Lock()
+	delete(ec.entries, key)
+	ec.mu.Unlock()
+}
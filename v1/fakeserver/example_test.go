@@ -0,0 +1,45 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakeserver_test
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/garfieldchenyu/uber/v1"
+	"github.com/garfieldchenyu/uber/v1/fakeserver"
+)
+
+func Example() {
+	srv := fakeserver.New()
+	defer srv.Close()
+
+	client, err := uber.NewClient("any-token")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := client.SetBaseURL(srv.URL); err != nil {
+		log.Fatal(err)
+	}
+
+	products, err := client.ListProducts(&uber.Place{Latitude: 37.7759792, Longitude: -122.41823})
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("%d products, the first is %s\n", len(products), products[0].DisplayName)
+
+	// Output:
+	// 9 products, the first is POOL
+}
@@ -0,0 +1,189 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fakeserver provides a fake of the Uber API that serves
+// realistic responses, for integration testing code that uses package
+// uber without reaching Uber. Point a client at it with SetBaseURL:
+//
+//	srv := fakeserver.New()
+//	defer srv.Close()
+//
+//	client, _ := uber.NewClient("any-token")
+//	client.SetBaseURL(srv.URL)
+package fakeserver
+
+import (
+	"embed"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//go:embed fixtures/*.json
+var fixtures embed.FS
+
+// Server is a running fake of the Uber API. Requests must carry
+// an "Authorization: Bearer <token>" header, any token is accepted.
+type Server struct {
+	*httptest.Server
+
+	mu     sync.RWMutex
+	routes []*route
+}
+
+type route struct {
+	method   string
+	segments []string
+	handler  http.Handler
+}
+
+// defaultRoutes maps the endpoints served by default to their fixtures.
+// Specific paths come after the wildcard paths that they'd also match.
+var defaultRoutes = []struct {
+	method, path, fixture string
+}{
+	{"GET", "/v1.2/products", "products.json"},
+	{"GET", "/v1.2/products/*", "product.json"},
+	{"GET", "/v1.2/estimates/price", "estimates-price.json"},
+	{"GET", "/v1.2/estimates/time", "estimates-time.json"},
+	{"GET", "/v1.2/me", "me.json"},
+	{"GET", "/v1.2/payment-methods", "payment-methods.json"},
+	{"POST", "/v1.2/requests", "ride.json"},
+	{"GET", "/v1.2/requests/*", "trip.json"},
+	{"GET", "/v1.2/requests/current", "requests-current.json"},
+	{"POST", "/v1.2/requests/estimate", "requests-estimate.json"},
+}
+
+// New starts and returns a Server that serves the default responses of
+// the products, estimates, profile, payment methods and ride request
+// endpoints. Close it when done.
+func New() *Server {
+	s := new(Server)
+	for _, dr := range defaultRoutes {
+		blob, err := fixtures.ReadFile("fixtures/" + dr.fixture)
+		if err != nil {
+			// The fixtures are embedded so this is a programming error.
+			panic(err)
+		}
+		s.HandleJSON(dr.method, dr.path, http.StatusOK, string(blob))
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// Handle registers the handler for requests with method to path e.g
+// "/v1.2/products". A "*" segment in path matches any single segment
+// e.g "/v1.2/requests/*". Handlers registered later take precedence,
+// which makes it possible to override the default responses.
+func (s *Server) Handle(method, path string, handler http.Handler) {
+	r := &route{
+		method:   strings.ToUpper(method),
+		segments: strings.Split(strings.Trim(path, "/"), "/"),
+		handler:  handler,
+	}
+
+	s.mu.Lock()
+	s.routes = append(s.routes, r)
+	s.mu.Unlock()
+}
+
+// HandleJSON makes requests with method to path respond
+// with the status code and JSON body.
+func (s *Server) HandleJSON(method, path string, status int, body string) {
+	s.Handle(method, path, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+// HandleError makes requests with method to path fail with
+// an Uber error of the status code, code and title, for
+// example 409, "surge" and "Surge pricing is in effect.".
+func (s *Server) HandleError(method, path string, status int, code, title string) {
+	s.HandleJSON(method, path, status, errorBody(status, code, title))
+}
+
+// HandleRateLimit makes requests with method to path fail as
+// though the rate limit was exceeded, with a 429 response
+// whose Retry-After header is set to retryAfter.
+func (s *Server) HandleRateLimit(method, path string, retryAfter time.Duration) {
+	body := errorBody(http.StatusTooManyRequests, "rate_limited", "Rate limit exceeded.")
+	s.Handle(method, path, http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter/time.Second)))
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(body))
+	}))
+}
+
+func errorBody(status int, code, title string) string {
+	blob, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]interface{}{
+			{"status": status, "code": code, "title": title},
+		},
+	})
+	return string(blob)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	if !strings.HasPrefix(req.Header.Get("Authorization"), "Bearer ") {
+		writeError(w, http.StatusUnauthorized, "unauthorized", "Invalid OAuth 2.0 credentials provided.")
+		return
+	}
+
+	if handler := s.lookup(req.Method, req.URL.Path); handler != nil {
+		handler.ServeHTTP(w, req)
+		return
+	}
+	writeError(w, http.StatusNotFound, "not_found", "Invalid resource requested.")
+}
+
+func writeError(w http.ResponseWriter, status int, code, title string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write([]byte(errorBody(status, code, title)))
+}
+
+// lookup returns the handler of the most recently
+// registered route that matches method and path.
+func (s *Server) lookup(method, path string) http.Handler {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for i := len(s.routes) - 1; i >= 0; i-- {
+		if r := s.routes[i]; r.method == method && r.matches(segments) {
+			return r.handler
+		}
+	}
+	return nil
+}
+
+func (r *route) matches(segments []string) bool {
+	if len(segments) != len(r.segments) {
+		return false
+	}
+	for i, segment := range r.segments {
+		if segment != "*" && segment != segments[i] {
+			return false
+		}
+	}
+	return true
+}
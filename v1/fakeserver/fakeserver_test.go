@@ -0,0 +1,142 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fakeserver_test
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/garfieldchenyu/uber/v1"
+	"github.com/garfieldchenyu/uber/v1/fakeserver"
+)
+
+func newClient(t *testing.T, srv *fakeserver.Server) *uber.Client {
+	client, err := uber.NewClient("fake-token")
+	if err != nil {
+		t.Fatalf("initializing client: %v", err)
+	}
+	if err := client.SetBaseURL(srv.URL); err != nil {
+		t.Fatalf("setting the base URL: %v", err)
+	}
+	return client
+}
+
+func TestDefaultRoutes(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	if products, err := client.ListProducts(&uber.Place{Latitude: 37.77, Longitude: -122.41}); err != nil || len(products) == 0 {
+		t.Errorf("listProducts: got %d products, err=%v", len(products), err)
+	}
+	if product, err := client.ProductByID("any-product"); err != nil || product.ID == "" {
+		t.Errorf("productByID: got %#v, err=%v", product, err)
+	}
+	if prof, err := client.RetrieveMyProfile(); err != nil || prof.Email == "" {
+		t.Errorf("retrieveMyProfile: got %#v, err=%v", prof, err)
+	}
+
+	current, err := client.CurrentTrip()
+	if err != nil {
+		t.Fatalf("currentTrip: %v", err)
+	}
+	trip, err := client.TripByID("a1111c8c-c720-46c3-8534-2fcdd730040d")
+	if err != nil {
+		t.Fatalf("tripByID: %v", err)
+	}
+	if current.RequestID == "" || trip.RequestID == "" {
+		t.Errorf("expecting request IDs, got current=%q byID=%q", current.RequestID, trip.RequestID)
+	}
+}
+
+func TestHandleOverrides(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	client := newClient(t, srv)
+
+	srv.HandleJSON("GET", "/v1.2/products", http.StatusOK, `{"products":[{"product_id":"p1","display_name":"Custom"}]}`)
+	products, err := client.ListProducts(&uber.Place{Latitude: 37.77, Longitude: -122.41})
+	if err != nil {
+		t.Fatalf("listProducts: %v", err)
+	}
+	if len(products) != 1 || products[0].DisplayName != "Custom" {
+		t.Errorf("expecting the custom product, got %#v", products)
+	}
+
+	srv.HandleError("GET", "/v1.2/products/*", http.StatusNotFound, "not_found", "No such product.")
+	_, err = client.ProductByID("p1")
+	if _, ok := err.(*uber.Error); !ok || !strings.Contains(err.Error(), "not_found") {
+		t.Errorf("productByID: got %T(%v) want a not_found *uber.Error", err, err)
+	}
+}
+
+func TestHandleRateLimit(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+	srv.HandleRateLimit("GET", "/v1.2/me", 30*time.Second)
+
+	req, _ := http.NewRequest("GET", srv.URL+"/v1.2/me", nil)
+	req.Header.Set("Authorization", "Bearer fake-token")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("statusCode: got=%d want=%d", res.StatusCode, http.StatusTooManyRequests)
+	}
+	if got := res.Header.Get("Retry-After"); got != "30" {
+		t.Errorf("retry-after: got=%q want=%q", got, "30")
+	}
+
+	client := newClient(t, srv)
+	if _, err := client.RetrieveMyProfile(); err == nil || !strings.Contains(err.Error(), "rate_limited") {
+		t.Errorf("retrieveMyProfile: got err=%v want a rate_limited error", err)
+	}
+}
+
+func TestUnauthenticatedAndUnknownPaths(t *testing.T) {
+	srv := fakeserver.New()
+	defer srv.Close()
+
+	tests := [...]struct {
+		path       string
+		auth       string
+		wantStatus int
+	}{
+		0: {path: "/v1.2/me", wantStatus: http.StatusUnauthorized},
+		1: {path: "/v1.2/me", auth: "Bearer t", wantStatus: http.StatusOK},
+		2: {path: "/v1.2/unknown", auth: "Bearer t", wantStatus: http.StatusNotFound},
+		3: {path: "/v1.2/products/a/b", auth: "Bearer t", wantStatus: http.StatusNotFound},
+	}
+
+	for i, tt := range tests {
+		req, _ := http.NewRequest("GET", srv.URL+tt.path, nil)
+		if tt.auth != "" {
+			req.Header.Set("Authorization", tt.auth)
+		}
+		res, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Errorf("#%d: %v", i, err)
+			continue
+		}
+		res.Body.Close()
+		if res.StatusCode != tt.wantStatus {
+			t.Errorf("#%d: statusCode: got=%d want=%d", i, res.StatusCode, tt.wantStatus)
+		}
+	}
+}
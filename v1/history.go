@@ -18,6 +18,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/orijtech/otils"
@@ -28,8 +30,9 @@ type Trip struct {
 	// it only return "completed" for now.
 	Status Status `json:"status,omitempty"`
 
-	// Length of activity in miles.
-	DistanceMiles float64 `json:"distance,omitempty"`
+	// Length of activity in miles. Unless it was sent, it is
+	// set from Distance when the Unit isn't other than miles.
+	DistanceMiles float64 `json:"distance_miles,omitempty"`
 
 	// UnixTimestamp of activity start time.
 	StartTimeUnix int64 `json:"start_time,omitempty"`
@@ -85,6 +88,26 @@ type Trip struct {
 	//  + type of waypoint
 	// It is only returned for shared rides like UberPOOL.
 	Waypoints []*Location `json:"waypoints,omitempty"`
+
+	// RoutePolyline is the route of the trip encoded with
+	// Google's polyline algorithm, if Uber sent it back.
+	// Use DecodePolyline for the points of the route.
+	RoutePolyline string `json:"route_polyline,omitempty"`
+}
+
+var _ json.Unmarshaler = (*Trip)(nil)
+
+func (t *Trip) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type trip Trip
+	if err := json.Unmarshal(b, (*trip)(t)); err != nil {
+		return err
+	}
+
+	if unit := strings.ToLower(t.Unit); t.DistanceMiles == 0 && (unit == "" || strings.HasPrefix(unit, "mile")) {
+		t.DistanceMiles = float64(t.Distance)
+	}
+	return nil
 }
 
 type StatusChange struct {
@@ -111,6 +134,21 @@ type TripThread struct {
 	Offset int64   `json:"offset"`
 }
 
+// itemDecoders decodes the trips of a
+// truncated page, appending them to tt.
+func (tt *TripThread) itemDecoders() map[string]func(*json.Decoder) error {
+	return map[string]func(*json.Decoder) error{
+		"history": func(dec *json.Decoder) error {
+			trip := new(Trip)
+			if err := dec.Decode(trip); err != nil {
+				return err
+			}
+			tt.Trips = append(tt.Trips, trip)
+			return nil
+		},
+	}
+}
+
 type Pager struct {
 	ThrottleDuration time.Duration `json:"-"`
 	LimitPerPage     int64         `json:"limit"`
@@ -143,6 +181,19 @@ func (c *Client) ListAllMyHistory() (thChan chan *TripThreadPage, cancelFn func(
 }
 
 func (c *Client) ListHistory(threq *Pager) (thChan chan *TripThreadPage, cancelFn func(), err error) {
+	return c.listHistory(threq, "")
+}
+
+// ListHistoryByProduct pages through the rider's history like ListHistory
+// does but only retrieves the trips made with the product of productID.
+func (c *Client) ListHistoryByProduct(threq *Pager, productID string) (thChan chan *TripThreadPage, cancelFn func(), err error) {
+	if strings.TrimSpace(productID) == "" {
+		return nil, nil, errBlankProductID
+	}
+	return c.listHistory(threq, productID)
+}
+
+func (c *Client) listHistory(threq *Pager, productID string) (thChan chan *TripThreadPage, cancelFn func(), err error) {
 	treq := new(Pager)
 	if threq != nil {
 		*treq = *threq
@@ -178,34 +229,41 @@ func (c *Client) ListHistory(threq *Pager) (thChan chan *TripThreadPage, cancelF
 			qv, err := otils.ToURLValues(treq)
 			if err != nil {
 				ttp.Err = err
-				historyChan <- ttp
+				sendPage(cancelChan, nil, historyChan, ttp)
 				return
 			}
+			if productID != "" {
+				qv.Set("product_id", productID)
+			}
 
 			fullURL := fmt.Sprintf("%s/history?%s", c.baseURL(), qv.Encode())
 			req, err := http.NewRequest("GET", fullURL, nil)
 			if err != nil {
 				ttp.Err = err
-				historyChan <- ttp
+				sendPage(cancelChan, nil, historyChan, ttp)
 				return
 			}
 
 			slurp, _, err := c.doReq(req)
+			if err == nil {
+				err = c.unmarshalResponse(slurp, ttp)
+			}
 			if err != nil {
-				ttp.Err = err
-				historyChan <- ttp
+				partial := new(TripThread)
+				if ttp.Err = truncatedResponse(slurp, err, partial.itemDecoders()); ttp.Err != err {
+					ttp.Trips = partial.Trips
+				}
+				sendPage(cancelChan, nil, historyChan, ttp)
 				return
 			}
 
-			if err := json.Unmarshal(slurp, ttp); err != nil {
-				ttp.Err = err
-				historyChan <- ttp
+			if !sendPage(cancelChan, nil, historyChan, ttp) {
 				return
 			}
 
-			historyChan <- ttp
-
-			if ttp.Count <= 0 {
+			// Count is the total number of trips so paging
+			// ends once the next offset would go past it.
+			if len(ttp.Trips) == 0 || treq.StartOffset+treq.LimitPerPage >= ttp.Count {
 				// No more items to page
 				return
 			}
@@ -234,3 +292,74 @@ func (c *Client) ListHistory(threq *Pager) (thChan chan *TripThreadPage, cancelF
 
 	return historyChan, cancelFn, nil
 }
+
+// HistoricalTrip is a trip from the rider's history
+// joined with the map of the trip, if it could be retrieved.
+type HistoricalTrip struct {
+	Trip *Trip `json:"trip"`
+
+	// Map is nil if the map of the trip couldn't be
+	// retrieved, in which case MapErr is set.
+	Map    *Map  `json:"map,omitempty"`
+	MapErr error `json:"-"`
+}
+
+// maxConcurrentMapFetches is the maximum number of maps
+// that ListHistoryWithMaps retrieves concurrently.
+const maxConcurrentMapFetches = 4
+
+// ListHistoryWithMaps retrieves every page of the rider's history, as
+// ListHistory does, and then the map of every trip. Failing to retrieve
+// a map doesn't fail the listing, the error is instead recorded in the
+// trip's MapErr. An error is only returned if the history couldn't be paged.
+func (c *Client) ListHistoryWithMaps(threq *Pager) ([]*HistoricalTrip, error) {
+	thChan, cancelPaging, err := c.ListHistory(threq)
+	if err != nil {
+		return nil, err
+	}
+	defer cancelPaging()
+
+	var trips []*Trip
+	for page := range thChan {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		for _, trip := range page.Trips {
+			if trip != nil {
+				trips = append(trips, trip)
+			}
+		}
+	}
+
+	return c.historicalTrips(trips), nil
+}
+
+// historicalTrips concurrently retrieves the maps of trips,
+// at most maxConcurrentMapFetches at a time.
+func (c *Client) historicalTrips(trips []*Trip) []*HistoricalTrip {
+	hts := make([]*HistoricalTrip, len(trips))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan bool, maxConcurrentMapFetches)
+	for i, trip := range trips {
+		hts[i] = &HistoricalTrip{Trip: trip}
+		tripID := otils.FirstNonEmptyString(trip.RequestID, trip.TripID)
+		if tripID == "" {
+			hts[i].MapErr = errEmptyTripID
+			continue
+		}
+
+		wg.Add(1)
+		go func(ht *HistoricalTrip, tripID string) {
+			defer wg.Done()
+
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			ht.Map, ht.MapErr = c.RequestMap(tripID)
+		}(hts[i], tripID)
+	}
+	wg.Wait()
+
+	return hts
+}
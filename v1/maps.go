@@ -15,10 +15,10 @@
 package uber
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/skratchdot/open-golang/open"
 )
@@ -32,8 +32,13 @@ type Map struct {
 var (
 	errEmptyTripID = errors.New("expecting a non-empty tripID")
 	errNoSuchMap   = errors.New("no such map")
+
+	ErrMapNotReady = errors.New("map not ready before the timeout elapsed")
 )
 
+// mapPollInterval is how long WaitForMap waits between attempts.
+const mapPollInterval = 2 * time.Second
+
 func (c *Client) RequestMap(tripID string) (*Map, error) {
 	if tripID == "" {
 		return nil, errEmptyTripID
@@ -52,7 +57,7 @@ func (c *Client) RequestMap(tripID string) (*Map, error) {
 
 	uinfo := new(Map)
 	blankMap := *uinfo
-	if err := json.Unmarshal(slurp, uinfo); err != nil {
+	if err := c.unmarshalResponse(slurp, uinfo); err != nil {
 		return nil, err
 	}
 	if blankMap == *uinfo {
@@ -61,6 +66,32 @@ func (c *Client) RequestMap(tripID string) (*Map, error) {
 	return uinfo, nil
 }
 
+// WaitForMap polls RequestMap until the map for the trip has a URL,
+// which only happens once a driver has been assigned. It returns
+// ErrMapNotReady if the map isn't available before the timeout elapses.
+// Errors other than the map not being ready are returned immediately.
+func (c *Client) WaitForMap(tripID string, timeout time.Duration) (*Map, error) {
+	deadline := time.Now().Add(timeout)
+	for {
+		uinfo, err := c.RequestMap(tripID)
+		switch {
+		case err == nil && uinfo.URL != "":
+			return uinfo, nil
+		case err != nil && err != errNoSuchMap:
+			return nil, err
+		}
+
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return nil, ErrMapNotReady
+		}
+		if remaining > mapPollInterval {
+			remaining = mapPollInterval
+		}
+		<-time.After(remaining)
+	}
+}
+
 // OpenMapForTrip is a convenience method that opens the map
 // for a trip or returns an error if it encounters an error.
 func (c *Client) OpenMapForTrip(tripID string) error {
@@ -0,0 +1,99 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Money is an amount in a currency. Use it
+// instead of the raw amounts of estimates, fares
+// and receipts to avoid mixing up currencies.
+type Money struct {
+	Amount       float64      `json:"amount"`
+	CurrencyCode CurrencyCode `json:"currency_code,omitempty"`
+}
+
+// Currencies whose amounts have no minor units.
+var zeroDecimalCurrencies = map[CurrencyCode]bool{
+	"CLP": true,
+	"ISK": true,
+	"JPY": true,
+	"KRW": true,
+	"PYG": true,
+	"UGX": true,
+	"VND": true,
+}
+
+// Format returns the amount with the number of decimal places
+// customary for the currency, followed by the currency code
+// e.g "12.78 USD" or "1200 JPY".
+func (m Money) Format() string {
+	decimals := 2
+	if zeroDecimalCurrencies[CurrencyCode(strings.ToUpper(string(m.CurrencyCode)))] {
+		decimals = 0
+	}
+	amount := strconv.FormatFloat(m.Amount, 'f', decimals, 64)
+	if m.CurrencyCode == "" {
+		return amount
+	}
+	return amount + " " + string(m.CurrencyCode)
+}
+
+func (m Money) String() string { return m.Format() }
+
+// parseAmount parses a formatted amount such as
+// "$12.78", "€12,78", "¥1,200" or "1,200.50" into a number.
+func parseAmount(s string) (float64, error) {
+	cleaned := strings.Map(func(r rune) rune {
+		if unicode.IsDigit(r) || r == '.' || r == ',' || r == '-' {
+			return r
+		}
+		return -1
+	}, s)
+
+	if strings.Contains(cleaned, ".") || groupsThousands(cleaned) {
+		// The comma can only be a thousands separator.
+		cleaned = strings.Replace(cleaned, ",", "", -1)
+	} else {
+		// A lone comma is the decimal separator.
+		cleaned = strings.Replace(cleaned, ",", ".", 1)
+	}
+
+	amount, err := strconv.ParseFloat(cleaned, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %v", s, err)
+	}
+	return amount, nil
+}
+
+// groupsThousands reports whether every comma of s
+// is followed by exactly three digits as in "1,200"
+// or "1,250,000" but unlike "12,78".
+func groupsThousands(s string) bool {
+	groups := strings.Split(s, ",")
+	if len(groups) < 2 {
+		return false
+	}
+	for _, group := range groups[1:] {
+		if len(group) != 3 {
+			return false
+		}
+	}
+	return true
+}
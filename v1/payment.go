@@ -34,6 +34,11 @@ type Payment struct {
 	Description   string        `json:"description,omitempty"`
 	PaymentMethod PaymentMethod `json:"type,omitempty"`
 
+	// RawType is the payment type as sent by Uber. It tells apart
+	// the types unknown to this package, which are all decoded
+	// into PaymentMethod as PaymentUnknown.
+	RawType string `json:"-"`
+
 	// DriverID is the unique identifier of the
 	// driver who received or made the payment.
 	DriverID string `json:"driver_id,omitempty"`
@@ -100,6 +105,14 @@ const (
 	PaymentCash
 	PaymentUcharge
 	PaymentZaakpay
+
+	// Last 2 digits of the account e.g "***53".
+	PaymentBaiduWallet
+
+	// The name of the business profile e.g "Late Night Ride".
+	PaymentBusinessAccount
+
+	PaymentUberCash
 )
 
 var paymentMethodToString = map[PaymentMethod]string{
@@ -108,6 +121,8 @@ var paymentMethodToString = map[PaymentMethod]string{
 	PaymentApplePay:          "apple_pay",
 	PaymentAmericanExpress:   "american_express",
 	PaymentAndroidPay:        "android_pay",
+	PaymentBaiduWallet:       "baidu_wallet",
+	PaymentBusinessAccount:   "business_account",
 	PaymentUberFamilyAccount: "family_account",
 	PaymentCash:              "cash",
 	PaymentDiscover:          "discover",
@@ -117,6 +132,7 @@ var paymentMethodToString = map[PaymentMethod]string{
 	PaymentMastercard:        "mastercard",
 	PaymentPaypal:            "paypal",
 	PaymentPaytm:             "paytm",
+	PaymentUberCash:          "uber_cash",
 	PaymentUcharge:           "ucharge",
 	PaymentUnionPay:          "unionpay",
 	PaymentUnknown:           "unknown",
@@ -145,6 +161,43 @@ func (pm PaymentMethod) String() string {
 	return pm.PaymentMethodToString()
 }
 
+var paymentMethodDisplayNames = map[PaymentMethod]string{
+	PaymentAirtel:            "Airtel Money",
+	PaymentAlipay:            "Alipay",
+	PaymentApplePay:          "Apple Pay",
+	PaymentAmericanExpress:   "American Express",
+	PaymentAndroidPay:        "Android Pay",
+	PaymentBaiduWallet:       "Baidu Wallet",
+	PaymentBusinessAccount:   "Business",
+	PaymentUberFamilyAccount: "Family",
+	PaymentCash:              "Cash",
+	PaymentDiscover:          "Discover",
+	PaymentJCB:               "JCB",
+	PaymentLianLian:          "LianLian Pay",
+	PaymentMaestro:           "Maestro",
+	PaymentMastercard:        "Mastercard",
+	PaymentPaypal:            "PayPal",
+	PaymentPaytm:             "Paytm",
+	PaymentUberCash:          "Uber Cash",
+	PaymentUcharge:           "Ucharge",
+	PaymentUnionPay:          "UnionPay",
+	PaymentVisa:              "Visa",
+	PaymentZaakpay:           "Zaakpay",
+}
+
+// DisplayName returns a user friendly label of the payment method
+// e.g "American Express" for PaymentAmericanExpress. Payment methods
+// without a label are displayed as their type e.g "unknown". Use
+// Payment.DisplayName to display unknown types as their raw type.
+func (pm *PaymentMethod) DisplayName() string {
+	if pm != nil {
+		if name, ok := paymentMethodDisplayNames[*pm]; ok {
+			return name
+		}
+	}
+	return pm.PaymentMethodToString()
+}
+
 func StringToPaymentMethod(str string) PaymentMethod {
 	pm, ok := stringToPaymentMethod[str]
 	if !ok {
@@ -165,6 +218,39 @@ func (pm *PaymentMethod) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+var _ json.Unmarshaler = (*Payment)(nil)
+
+func (p *Payment) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type payment Payment
+	recv := struct {
+		*payment
+		RawType *string `json:"type,omitempty"`
+	}{payment: (*payment)(p)}
+	if err := json.Unmarshal(b, &recv); err != nil {
+		return err
+	}
+	if recv.RawType != nil {
+		p.RawType = *recv.RawType
+		p.PaymentMethod = StringToPaymentMethod(p.RawType)
+	}
+	return nil
+}
+
+// DisplayName returns a user friendly label of the payment's
+// method, as PaymentMethod.DisplayName does, except that payment
+// types unknown to this package are displayed as their raw type
+// e.g "crypto_wallet".
+func (p *Payment) DisplayName() string {
+	if p == nil {
+		return new(PaymentMethod).DisplayName()
+	}
+	if p.PaymentMethod == PaymentUnknown && p.RawType != "" {
+		return p.RawType
+	}
+	return p.PaymentMethod.DisplayName()
+}
+
 type PaymentListing struct {
 	Methods []*Payment `json:"payment_methods,omitempty"`
 
@@ -173,6 +259,20 @@ type PaymentListing struct {
 	LastUsedID string `json:"last_used,omitempty"`
 }
 
+// HasMethod reports whether methodID is the
+// unique identifier of one of the payment methods.
+func (pl *PaymentListing) HasMethod(methodID string) bool {
+	if pl == nil || methodID == "" {
+		return false
+	}
+	for _, method := range pl.Methods {
+		if method != nil && method.MethodID == methodID {
+			return true
+		}
+	}
+	return false
+}
+
 func (c *Client) ListPaymentMethods() (*PaymentListing, error) {
 	fullURL := fmt.Sprintf("%s/payment-methods", c.baseURL())
 	req, err := http.NewRequest("GET", fullURL, nil)
@@ -188,7 +288,7 @@ func (c *Client) ListPaymentMethods() (*PaymentListing, error) {
 	}
 
 	listing := new(PaymentListing)
-	if err := json.Unmarshal(slurp, listing); err != nil {
+	if err := c.unmarshalResponse(slurp, listing); err != nil {
 		return nil, err
 	}
 	return listing, nil
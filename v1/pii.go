@@ -0,0 +1,215 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"encoding/json"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maskName masks all but the first letter of name e.g "J***".
+func maskName(name string) string {
+	if name == "" {
+		return ""
+	}
+	r, _ := utf8.DecodeRuneInString(name)
+	return string(r) + "***"
+}
+
+// maskEmail masks all but the first letter of the local
+// part of email and its domain e.g "j***@example.com".
+func maskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return maskName(email)
+	}
+	return maskName(email[:at]) + email[at:]
+}
+
+// maskPhone masks all but the last 4 digits of number,
+// keeping its formatting e.g "(***)***-1212".
+func maskPhone(number string) string {
+	digits := 0
+	for _, r := range number {
+		if r >= '0' && r <= '9' {
+			digits++
+		}
+	}
+	toMask := digits - 4
+	if toMask < 0 {
+		toMask = digits
+	}
+	masked := []rune(number)
+	for i, r := range masked {
+		if toMask == 0 {
+			break
+		}
+		if r >= '0' && r <= '9' {
+			masked[i] = '*'
+			toMask--
+		}
+	}
+	return string(masked)
+}
+
+var (
+	emailRegexp = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+
+	// phoneRegexp matches North American numbers formatted e.g as
+	// "(415) 555-1212", "415-555-1212" or "415.555.1212", and numbers
+	// in international format i.e starting with "+". Bare runs of
+	// digits aren't matched since they are more likely to be IDs or
+	// timestamps than phone numbers.
+	phoneRegexp = regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?(?:\(\d{3}\)\s?\d{3}[\s.-]|\b\d{3}-\d{3}-|\b\d{3}\.\d{3}\.|\b\d{3} \d{3} )\d{4}\b|\+\d[\d\s().-]{6,}\d`)
+)
+
+// maskPII masks the emails and phone numbers found in text.
+func maskPII(text string) string {
+	text = emailRegexp.ReplaceAllStringFunc(text, maskEmail)
+	return phoneRegexp.ReplaceAllStringFunc(text, maskPhone)
+}
+
+// maxErrorBodyLen is the most of a non-JSON error
+// response body that is echoed in an error message.
+const maxErrorBodyLen = 256
+
+// errorBodyText returns the body of an error response for use in an error
+// message, truncated and with any emails and phone numbers in it masked
+// since some servers echo the request, and its PII, in their errors.
+func errorBodyText(body []byte) string {
+	text := string(body)
+	if len(text) > maxErrorBodyLen {
+		text = strings.ToValidUTF8(text[:maxErrorBodyLen], "") + "..."
+	}
+	return maskPII(text)
+}
+
+// String describes the profile as JSON with its names and email
+// masked, so that printing or logging it doesn't leak them.
+// Use Unmasked for the full description.
+func (p *Profile) String() string {
+	if p == nil {
+		return ""
+	}
+	masked := *p
+	masked.FirstName = maskName(p.FirstName)
+	masked.LastName = maskName(p.LastName)
+	masked.Email = maskEmail(p.Email)
+	return (*profile)(&masked).describe()
+}
+
+// Unmasked describes the profile as JSON, names and email included.
+func (p *Profile) Unmasked() string {
+	if p == nil {
+		return ""
+	}
+	return (*profile)(p).describe()
+}
+
+// profile is Profile without the String method that masks it.
+type profile Profile
+
+func (p *profile) describe() string {
+	blob, _ := json.Marshal(p)
+	return string(blob)
+}
+
+// String describes the contact as JSON with its names, email and
+// phone number masked, so that printing or logging it doesn't
+// leak them. Use Unmasked for the full description.
+func (c *Contact) String() string {
+	if c == nil {
+		return ""
+	}
+	masked := *c
+	masked.FirstName = maskName(c.FirstName)
+	masked.LastName = maskName(c.LastName)
+	masked.Email = maskEmail(c.Email)
+	if c.Phone != nil {
+		phone := *c.Phone
+		phone.Number = maskPhone(phone.Number)
+		masked.Phone = &phone
+	}
+	return (*contact)(&masked).describe()
+}
+
+// Unmasked describes the contact as JSON, all its details included.
+func (c *Contact) Unmasked() string {
+	if c == nil {
+		return ""
+	}
+	return (*contact)(c).describe()
+}
+
+// contact is Contact without the String method that masks it.
+type contact Contact
+
+func (c *contact) describe() string {
+	blob, _ := json.Marshal(c)
+	return string(blob)
+}
+
+// String describes the receipt as JSON with any emails and phone numbers
+// in the names of its charges masked, since those can name the riders
+// that a fare was split with. Use Unmasked for the full description.
+func (r *Receipt) String() string {
+	if r == nil {
+		return ""
+	}
+	masked := *r
+	masked.Charges = maskCharges(r.Charges)
+	masked.ChargeAdjustments = maskCharges(r.ChargeAdjustments)
+	if r.SurgeCharge != nil {
+		masked.SurgeCharge = maskCharges([]*Charge{r.SurgeCharge})[0]
+	}
+	return (*receipt)(&masked).describe()
+}
+
+// Unmasked describes the receipt as JSON, all its charges included.
+func (r *Receipt) Unmasked() string {
+	if r == nil {
+		return ""
+	}
+	return (*receipt)(r).describe()
+}
+
+// maskCharges returns copies of charges with
+// the PII in their names and types masked.
+func maskCharges(charges []*Charge) []*Charge {
+	if charges == nil {
+		return nil
+	}
+	masked := make([]*Charge, len(charges))
+	for i, charge := range charges {
+		if charge == nil {
+			continue
+		}
+		copied := *charge
+		copied.Name = maskPII(charge.Name)
+		copied.Type = maskPII(charge.Type)
+		masked[i] = &copied
+	}
+	return masked
+}
+
+// receipt is Receipt without the String method that masks it.
+type receipt Receipt
+
+func (r *receipt) describe() string {
+	blob, _ := json.Marshal(r)
+	return string(blob)
+}
@@ -16,10 +16,12 @@ package uber
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
+	"sync"
 )
 
 type PlaceName string
@@ -29,23 +31,147 @@ const (
 	PlaceWork PlaceName = "work"
 )
 
+// Geocoder resolves an address into its coordinates.
+type Geocoder interface {
+	Geocode(address string) (lat, lon float64, err error)
+}
+
+// SetGeocoder sets the Geocoder used to resolve the coordinates
+// of saved places for which Uber only returns an address.
+func (c *Client) SetGeocoder(g Geocoder) {
+	c.Lock()
+	c.geocoder = g
+	c.Unlock()
+}
+
+func (c *Client) getGeocoder() Geocoder {
+	c.RLock()
+	defer c.RUnlock()
+
+	return c.geocoder
+}
+
+// HasCoordinates reports whether the place has its
+// latitude and longitude set.
+func (p *Place) HasCoordinates() bool {
+	return p != nil && (p.Latitude != 0 || p.Longitude != 0)
+}
+
+// Place retrieves one of your saved places. Uber usually only
+// returns the address of the place, so if a Geocoder was set
+// with SetGeocoder, it is used to fill in the coordinates of
+// any place that lacks them. Geocoding is best effort: if it
+// fails, the place is returned exactly as Uber sent it.
 func (c *Client) Place(placeName PlaceName) (*Place, error) {
 	fullURL := fmt.Sprintf("%s/places/%s", c.baseURL(), placeName)
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	return c.doPlaceReq(req)
+	place, err := c.doPlaceReq(req)
+	if err != nil {
+		return nil, err
+	}
+	c.resolveCoordinates(place)
+	return place, nil
+}
+
+// ErrPlaceWithoutCoordinates is returned by ResolvePlace when neither Uber
+// nor the Geocoder set with SetGeocoder provided the place's coordinates.
+var ErrPlaceWithoutCoordinates = errors.New("the coordinates of the place are unknown")
+
+// ResolvePlace retrieves one of your saved places, as Place does, and
+// returns its coordinates so that callers can uniformly work with
+// coordinates. Since Uber usually only returns the address of a place,
+// set a Geocoder with SetGeocoder otherwise ErrPlaceWithoutCoordinates
+// is likely to be returned.
+func (c *Client) ResolvePlace(name PlaceName) (lat, lng float64, err error) {
+	if err := validatePlaceName(name); err != nil {
+		return 0, 0, err
+	}
+	place, err := c.Place(name)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !place.HasCoordinates() {
+		return 0, 0, ErrPlaceWithoutCoordinates
+	}
+	return place.Latitude, place.Longitude, nil
+}
+
+// PlacesError maps the names of the places that
+// couldn't be retrieved by ListPlaces to their errors.
+type PlacesError map[PlaceName]error
+
+func (pe PlacesError) Error() string {
+	var errsList []string
+	for name, err := range pe {
+		errsList = append(errsList, fmt.Sprintf("%s: %v", name, err))
+	}
+	sort.Strings(errsList)
+	return strings.Join(errsList, "; ")
+}
+
+// ListPlaces concurrently retrieves all your saved places i.e home and work.
+// A place that isn't set is left out of the returned map. If any of the
+// places couldn't be retrieved, the ones that were are returned together
+// with a PlacesError holding the errors of the others.
+func (c *Client) ListPlaces() (map[PlaceName]*Place, error) {
+	placeNames := [...]PlaceName{PlaceHome, PlaceWork}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	places := make(map[PlaceName]*Place)
+	placesErr := make(PlacesError)
+	for _, placeName := range placeNames {
+		wg.Add(1)
+		go func(placeName PlaceName) {
+			defer wg.Done()
+
+			place, err := c.Place(placeName)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			switch {
+			case err == nil:
+				places[placeName] = place
+			case statusCode(err) != http.StatusNotFound:
+				placesErr[placeName] = err
+			}
+		}(placeName)
+	}
+	wg.Wait()
+
+	if len(placesErr) > 0 {
+		return places, placesErr
+	}
+	return places, nil
+}
+
+func (c *Client) resolveCoordinates(place *Place) {
+	if place.HasCoordinates() || place.Address == "" {
+		return
+	}
+	geocoder := c.getGeocoder()
+	if geocoder == nil {
+		return
+	}
+	lat, lon, err := geocoder.Geocode(place.Address)
+	if err != nil {
+		return
+	}
+	place.Latitude, place.Longitude = lat, lon
 }
 
 func (c *Client) doPlaceReq(req *http.Request) (*Place, error) {
-	slurp, _, err := c.doReq(req)
+	slurp, _, err := c.doETagReq(req)
 	if err != nil {
 		return nil, err
 	}
 
 	place := new(Place)
-	if err := json.Unmarshal(slurp, place); err != nil {
+	if err := c.unmarshalResponse(slurp, place); err != nil {
 		return nil, err
 	}
 	return place, nil
@@ -57,16 +183,24 @@ type PlaceParams struct {
 }
 
 var (
+	errNilPlaceParams   = errors.New("expecting non-nil placeParams")
 	errEmptyAddress     = errors.New("expecting a non-empty address")
 	errInvalidPlaceName = fmt.Errorf("invalid placeName; can only be either %q or %q", PlaceHome, PlaceWork)
 )
 
 func (pp *PlaceParams) Validate() error {
-	if pp == nil || pp.Address == "" {
+	if pp == nil {
+		return errNilPlaceParams
+	}
+	if pp.Address == "" {
 		return errEmptyAddress
 	}
 
-	switch pp.Place {
+	return validatePlaceName(pp.Place)
+}
+
+func validatePlaceName(name PlaceName) error {
+	switch name {
 	case PlaceHome, PlaceWork:
 		return nil
 	default:
@@ -80,7 +214,7 @@ func (c *Client) UpdatePlace(pp *PlaceParams) (*Place, error) {
 		return nil, err
 	}
 
-	blob, err := json.Marshal(&Place{Address: pp.Address})
+	blob, err := MarshalCanonical(&Place{Address: pp.Address})
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,73 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import "errors"
+
+// LatLng is a point on a route.
+type LatLng struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+var errMalformedPolyline = errors.New("malformed encoded polyline")
+
+// DecodePolyline decodes the trip's RoutePolyline into the points of
+// its route, in order. An empty polyline decodes to no points.
+func (t *Trip) DecodePolyline() ([]LatLng, error) {
+	if t == nil {
+		return []LatLng{}, nil
+	}
+	return decodePolyline(t.RoutePolyline)
+}
+
+// decodePolyline decodes a polyline encoded with Google's
+// algorithm, in which coordinates have 5 decimal places. See
+// https://developers.google.com/maps/documentation/utilities/polylinealgorithm
+func decodePolyline(encoded string) ([]LatLng, error) {
+	points := []LatLng{}
+	var lat, lng int64
+	for i := 0; i < len(encoded); {
+		var deltas [2]int64
+		for j := range deltas {
+			var result int64
+			shift := uint(0)
+			for {
+				if i >= len(encoded) || shift > 30 {
+					return nil, errMalformedPolyline
+				}
+				b := int64(encoded[i]) - 63
+				i++
+				if b < 0 || b > 63 {
+					return nil, errMalformedPolyline
+				}
+				result |= (b & 0x1f) << shift
+				shift += 5
+				if b < 0x20 {
+					break
+				}
+			}
+			if result&1 != 0 {
+				deltas[j] = ^(result >> 1)
+			} else {
+				deltas[j] = result >> 1
+			}
+		}
+		lat += deltas[0]
+		lng += deltas[1]
+		points = append(points, LatLng{Latitude: float64(lat) / 1e5, Longitude: float64(lng) / 1e5})
+	}
+	return points, nil
+}
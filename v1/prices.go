@@ -16,22 +16,33 @@ package uber
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/orijtech/otils"
 )
 
+// EstimateRequest describes the trip to estimate. Uber's estimate
+// endpoints have no currency parameter: prices are always in the local
+// currency of the start location, as given by the CurrencyCode of the
+// estimates sent back, and can be converted by the caller if needed.
 type EstimateRequest struct {
 	StartLatitude  float64 `json:"start_latitude"`
 	StartLongitude float64 `json:"start_longitude"`
 	EndLongitude   float64 `json:"end_longitude"`
 	EndLatitude    float64 `json:"end_latitude"`
 
-	SeatCount int `json:"seat_count"`
+	// SeatCount is the number of seats required for
+	// shared products such as uberPOOL, whose price
+	// depends on it. It is only sent when set and
+	// must be either 1 or 2.
+	SeatCount int `json:"seat_count,omitempty"`
 
 	// ProductID is the UniqueID of the product
 	// being requested. If unspecified, it will
@@ -42,6 +53,13 @@ type EstimateRequest struct {
 	StartPlace PlaceName `json:"start_place_id"`
 	EndPlace   PlaceName `json:"end_place_id"`
 
+	// PaymentMethodID is the unique identifier of the payment
+	// method to be used, since upfront fares can vary by payment
+	// method. It is only sent when set and if unset, the user's
+	// last used payment method is assumed. PaymentListing.HasMethod
+	// can be used to check it against the user's payment methods.
+	PaymentMethodID string `json:"payment_method_id,omitempty"`
+
 	Pager
 }
 
@@ -57,6 +75,9 @@ type PriceEstimate struct {
 	// Expected activity duration in seconds.
 	DurationSeconds otils.NullableFloat64 `json:"duration"`
 
+	// Expected activity distance in miles.
+	Distance otils.NullableFloat64 `json:"distance"`
+
 	// Minimum price for product.
 	MinimumPrice otils.NullableFloat64 `json:"minimum"`
 
@@ -86,6 +107,28 @@ type PriceEstimate struct {
 	LimitPerPage int64 `json:"limit"`
 }
 
+// LowMoney returns the lower bound of the estimated price.
+func (pe *PriceEstimate) LowMoney() Money {
+	if pe == nil {
+		return Money{}
+	}
+	return Money{Amount: float64(pe.LowEstimate), CurrencyCode: CurrencyCode(pe.CurrencyCode)}
+}
+
+// HighMoney returns the upper bound of the estimated price.
+func (pe *PriceEstimate) HighMoney() Money {
+	if pe == nil {
+		return Money{}
+	}
+	return Money{Amount: float64(pe.HighEstimate), CurrencyCode: CurrencyCode(pe.CurrencyCode)}
+}
+
+// IsSurging returns true if surge pricing is active
+// for the estimate i.e its SurgeMultiplier is greater than 1.
+func (pe *PriceEstimate) IsSurging() bool {
+	return pe != nil && pe.SurgeMultiplier > 1
+}
+
 var errNilEstimateRequest = errors.New("expecting a non-nil estimateRequest")
 
 type PriceEstimatesPage struct {
@@ -97,10 +140,37 @@ type PriceEstimatesPage struct {
 	PageNumber uint64
 }
 
+// itemDecoders decodes the estimates of a
+// truncated page, appending them to pep.
+func (pep *PriceEstimatesPage) itemDecoders() map[string]func(*json.Decoder) error {
+	return map[string]func(*json.Decoder) error{
+		"prices": func(dec *json.Decoder) error {
+			estimate := new(PriceEstimate)
+			if err := dec.Decode(estimate); err != nil {
+				return err
+			}
+			pep.Estimates = append(pep.Estimates, estimate)
+			return nil
+		},
+	}
+}
+
+// EstimatePrice pages through the price estimates for ereq. Invoking
+// cancelPaging stops the paging: the pages channel is then promptly
+// closed and a page being fetched is discarded instead of being sent.
 func (c *Client) EstimatePrice(ereq *EstimateRequest) (pagesChan chan *PriceEstimatesPage, cancelPaging func(), err error) {
+	return c.EstimatePriceWithContext(context.Background(), ereq)
+}
+
+// EstimatePriceWithContext is like EstimatePrice but the paging also stops once ctx
+// is done, which aborts the request of a page being fetched.
+func (c *Client) EstimatePriceWithContext(ctx context.Context, ereq *EstimateRequest) (pagesChan chan *PriceEstimatesPage, cancelPaging func(), err error) {
 	if ereq == nil {
 		return nil, nil, errNilEstimateRequest
 	}
+	if err := validateSeatCount(ereq.SeatCount); err != nil {
+		return nil, nil, err
+	}
 
 	pager := new(Pager)
 	if ereq != nil {
@@ -136,33 +206,36 @@ func (c *Client) EstimatePrice(ereq *EstimateRequest) (pagesChan chan *PriceEsti
 			qv, err := otils.ToURLValues(ereq)
 			if err != nil {
 				ep.Err = err
-				estimatesPageChan <- ep
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, ep)
 				return
 			}
+			c.addLocale(qv)
 
 			fullURL := fmt.Sprintf("%s/estimates/price?%s", c.baseURL(), qv.Encode())
 			req, err := http.NewRequest("GET", fullURL, nil)
 			if err != nil {
 				ep.Err = err
-				estimatesPageChan <- ep
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, ep)
 				return
 			}
 
-			slurp, _, err := c.doReq(req)
+			slurp, _, err := c.doReq(req.WithContext(ctx))
+			if err == nil {
+				err = c.unmarshalResponse(slurp, ep)
+			}
 			if err != nil {
-				ep.Err = err
-				estimatesPageChan <- ep
+				partial := new(PriceEstimatesPage)
+				if ep.Err = truncatedResponse(slurp, err, partial.itemDecoders()); ep.Err != err {
+					ep.Estimates = partial.Estimates
+				}
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, ep)
 				return
 			}
 
-			if err := json.Unmarshal(slurp, ep); err != nil {
-				ep.Err = err
-				estimatesPageChan <- ep
+			if !sendPage(cancelChan, ctx.Done(), estimatesPageChan, ep) {
 				return
 			}
 
-			estimatesPageChan <- ep
-
 			if ep.Count <= 0 {
 				// No more items to page
 				return
@@ -174,6 +247,9 @@ func (c *Client) EstimatePrice(ereq *EstimateRequest) (pagesChan chan *PriceEsti
 				canPage = false
 				return
 
+			case <-ctx.Done():
+				return
+
 			case <-time.After(throttleDuration):
 				// Do nothing here, the throttle time expired.
 			}
@@ -193,6 +269,243 @@ func (c *Client) EstimatePrice(ereq *EstimateRequest) (pagesChan chan *PriceEsti
 	return estimatesPageChan, cancelFn, nil
 }
 
+// PriceEstimateStream delivers the price estimates of every page of
+// EstimatePrice one at a time. The Estimates channel is closed once
+// the paging ends, after which Err reports why it ended early if so.
+type PriceEstimateStream struct {
+	Estimates <-chan *PriceEstimate
+
+	// Cancel stops the stream, after which
+	// the Estimates channel is promptly closed.
+	Cancel func()
+
+	mu  sync.Mutex
+	err error
+}
+
+// Err returns the error that ended the stream, if any. It
+// should be invoked after the Estimates channel is closed.
+func (pes *PriceEstimateStream) Err() error {
+	pes.mu.Lock()
+	defer pes.mu.Unlock()
+
+	return pes.err
+}
+
+func (pes *PriceEstimateStream) setErr(err error) {
+	pes.mu.Lock()
+	pes.err = err
+	pes.mu.Unlock()
+}
+
+// EstimatePriceStream is like EstimatePrice but the estimates of the
+// pages are sent individually, for callers that only iterate over them.
+func (c *Client) EstimatePriceStream(ereq *EstimateRequest) (*PriceEstimateStream, error) {
+	pagesChan, cancelPaging, err := c.EstimatePrice(ereq)
+	if err != nil {
+		return nil, err
+	}
+
+	cancelChan, cancelFn := makeCancelParadigm()
+	estimatesChan := make(chan *PriceEstimate)
+	stream := &PriceEstimateStream{
+		Estimates: estimatesChan,
+		Cancel: func() {
+			cancelFn()
+			cancelPaging()
+		},
+	}
+
+	go func() {
+		defer close(estimatesChan)
+		defer cancelPaging()
+
+		for page := range pagesChan {
+			if page.Err != nil {
+				stream.setErr(page.Err)
+				return
+			}
+			for _, estimate := range page.Estimates {
+				select {
+				case <-cancelChan:
+					return
+				case estimatesChan <- estimate:
+				}
+			}
+		}
+	}()
+
+	return stream, nil
+}
+
+// allPriceEstimates retrieves the price estimates
+// from every page of EstimatePrice.
+func (c *Client) allPriceEstimates(ereq *EstimateRequest) ([]*PriceEstimate, error) {
+	pagesChan, cancelPaging, err := c.EstimatePrice(ereq)
+	if err != nil {
+		return nil, err
+	}
+	defer cancelPaging()
+
+	var estimates []*PriceEstimate
+	for page := range pagesChan {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		estimates = append(estimates, page.Estimates...)
+	}
+	return estimates, nil
+}
+
+// BatchEstimateResult is the outcome of one of the requests of EstimatePrices.
+type BatchEstimateResult struct {
+	Request   *EstimateRequest `json:"request,omitempty"`
+	Estimates []*PriceEstimate `json:"estimates,omitempty"`
+
+	// Err is set if the estimates of the request
+	// couldn't be retrieved, without affecting
+	// the results of the other requests.
+	Err error `json:"-"`
+}
+
+// maxConcurrentPriceEstimates is the maximum number of
+// requests whose prices EstimatePrices estimates concurrently.
+const maxConcurrentPriceEstimates = 4
+
+var errNoEstimateRequests = errors.New("expecting at least one estimate request")
+
+// EstimatePrices concurrently estimates the prices of every request e.g to
+// compare several destinations, at most maxConcurrentPriceEstimates at a
+// time. The results are in the order of reqs, each with its own error.
+// An error is only returned if no requests were passed in.
+func (c *Client) EstimatePrices(reqs []*EstimateRequest) ([]*BatchEstimateResult, error) {
+	if len(reqs) == 0 {
+		return nil, errNoEstimateRequests
+	}
+
+	results := make([]*BatchEstimateResult, len(reqs))
+
+	var wg sync.WaitGroup
+	semaphore := make(chan bool, maxConcurrentPriceEstimates)
+	for i, ereq := range reqs {
+		results[i] = &BatchEstimateResult{Request: ereq}
+		if ereq == nil {
+			results[i].Err = errNilEstimateRequest
+			continue
+		}
+
+		wg.Add(1)
+		go func(result *BatchEstimateResult) {
+			defer wg.Done()
+
+			semaphore <- true
+			defer func() { <-semaphore }()
+
+			result.Estimates, result.Err = c.allPriceEstimates(result.Request)
+		}(results[i])
+	}
+	wg.Wait()
+
+	return results, nil
+}
+
+// RoundTripEstimate is the combined price estimate of a trip there and back.
+type RoundTripEstimate struct {
+	// Estimates are of the products available both ways, in
+	// the order of the price estimates for the trip there.
+	Estimates []*RoundTripProductEstimate `json:"estimates"`
+
+	// Excluded maps the IDs of the products left out of Estimates
+	// to why they were e.g "not available for the trip back".
+	Excluded map[string]string `json:"excluded,omitempty"`
+}
+
+// RoundTripProductEstimate is the price estimate of a product for a round trip.
+type RoundTripProductEstimate struct {
+	ProductID string `json:"product_id"`
+	Name      string `json:"display_name"`
+
+	// Low and High are the sums of the lower and upper
+	// bounds of the estimates for the trips there and back.
+	Low  Money `json:"low"`
+	High Money `json:"high"`
+
+	There *PriceEstimate `json:"there"`
+	Back  *PriceEstimate `json:"back"`
+}
+
+// EstimateRoundTrip concurrently estimates the prices of the trips there
+// and back and sums them up for every product available both ways.
+func (c *Client) EstimateRoundTrip(there, back *EstimateRequest) (*RoundTripEstimate, error) {
+	if there == nil || back == nil {
+		return nil, errNilEstimateRequest
+	}
+
+	var thereEstimates, backEstimates []*PriceEstimate
+	var thereErr, backErr error
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		thereEstimates, thereErr = c.allPriceEstimates(there)
+	}()
+	go func() {
+		defer wg.Done()
+		backEstimates, backErr = c.allPriceEstimates(back)
+	}()
+	wg.Wait()
+
+	if thereErr != nil {
+		return nil, thereErr
+	}
+	if backErr != nil {
+		return nil, backErr
+	}
+
+	backByID := make(map[string]*PriceEstimate)
+	for _, estimate := range backEstimates {
+		if estimate != nil {
+			backByID[estimate.ProductID] = estimate
+		}
+	}
+
+	rte := &RoundTripEstimate{Excluded: make(map[string]string)}
+	seen := make(map[string]bool)
+	for _, thereEst := range thereEstimates {
+		if thereEst == nil {
+			continue
+		}
+		seen[thereEst.ProductID] = true
+		backEst, ok := backByID[thereEst.ProductID]
+		switch {
+		case !ok:
+			rte.Excluded[thereEst.ProductID] = "not available for the trip back"
+			continue
+		case thereEst.CurrencyCode != backEst.CurrencyCode:
+			rte.Excluded[thereEst.ProductID] = "estimated in different currencies"
+			continue
+		}
+
+		low, high := thereEst.LowMoney(), thereEst.HighMoney()
+		low.Amount += float64(backEst.LowEstimate)
+		high.Amount += float64(backEst.HighEstimate)
+		rte.Estimates = append(rte.Estimates, &RoundTripProductEstimate{
+			ProductID: thereEst.ProductID,
+			Name:      thereEst.Name,
+			Low:       low,
+			High:      high,
+			There:     thereEst,
+			Back:      backEst,
+		})
+	}
+	for _, backEst := range backEstimates {
+		if backEst != nil && !seen[backEst.ProductID] {
+			rte.Excluded[backEst.ProductID] = "not available for the trip there"
+		}
+	}
+	return rte, nil
+}
+
 type FareEstimate struct {
 	SurgeConfirmationURL string `json:"surge_confirmation_href,omitempty"`
 	SurgeConfirmationID  string `json:"surge_confirmation_id"`
@@ -223,11 +536,114 @@ type FareBreakdown struct {
 }
 
 type Fare struct {
-	Value         otils.NullableFloat64 `json:"value,omitempty"`
-	ExpiresAt     int64                 `json:"expires_at,omitempty"`
-	CurrencyCode  otils.NullableString  `json:"currency_code"`
-	DisplayAmount otils.NullableString  `json:"display"`
-	ID            otils.NullableString  `json:"fare_id"`
+	Value otils.NullableFloat64 `json:"value,omitempty"`
+
+	// ExpiresAt is the Unix timestamp at which the fare expires.
+	// Uber sends it either in seconds or as an RFC 3339 time.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+
+	CurrencyCode  otils.NullableString `json:"currency_code"`
+	DisplayAmount otils.NullableString `json:"display"`
+	ID            otils.NullableString `json:"fare_id"`
+
+	// Breakdown itemizes the components that make up the fare.
+	Breakdown []*FareComponent `json:"breakdown,omitempty"`
+}
+
+var _ json.Unmarshaler = (*Fare)(nil)
+
+func (f *Fare) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type fare Fare
+	aux := struct {
+		*fare
+		ExpiresAt json.RawMessage `json:"expires_at,omitempty"`
+	}{fare: (*fare)(f)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	expiresAt, err := parseTimestamp(aux.ExpiresAt)
+	if err != nil {
+		return fmt.Errorf("invalid expires_at: %v", err)
+	}
+	f.ExpiresAt = expiresAt
+	return nil
+}
+
+// parseTimestamp parses a timestamp in Unix seconds, possibly
+// quoted, or an RFC 3339 time into Unix seconds. A blank or
+// null timestamp is parsed as 0.
+func parseTimestamp(raw json.RawMessage) (int64, error) {
+	str := string(bytes.TrimSpace(raw))
+	if str == "" || str == "null" {
+		return 0, nil
+	}
+	if unquoted, err := strconv.Unquote(str); err == nil {
+		str = unquoted
+		if str == "" {
+			return 0, nil
+		}
+	}
+	if secs, err := strconv.ParseInt(str, 10, 64); err == nil {
+		return secs, nil
+	}
+	t, err := time.Parse(time.RFC3339, str)
+	if err != nil {
+		return 0, err
+	}
+	return t.Unix(), nil
+}
+
+// TimeUntilExpiry returns how long the fare remains valid for, which is
+// negative if it has already expired. It is 0 if the fare has no expiry.
+func (upf *UpfrontFare) TimeUntilExpiry() time.Duration {
+	if upf == nil || upf.Fare == nil || upf.Fare.ExpiresAt == 0 {
+		return 0
+	}
+	return time.Until(time.Unix(upf.Fare.ExpiresAt, 0))
+}
+
+// Expired reports whether the fare has expired, in which
+// case a new upfront fare must be retrieved to request a ride.
+func (upf *UpfrontFare) Expired() bool {
+	if upf == nil || upf.Fare == nil || upf.Fare.ExpiresAt == 0 {
+		return false
+	}
+	return upf.TimeUntilExpiry() <= 0
+}
+
+// The types of the components of a fare.
+const (
+	FareComponentBaseFare  = "base_fare"
+	FareComponentPromotion = "promotion"
+	FareComponentUnknown   = "unknown"
+)
+
+// FareComponent is an item of the breakdown of an upfront fare.
+type FareComponent struct {
+	// Type is the type of the component
+	// e.g FareComponentBaseFare.
+	Type string `json:"type,omitempty"`
+
+	// Name is the display name of the component.
+	Name string `json:"name,omitempty"`
+
+	// Value is the amount of the component in the currency of
+	// the fare. Promotions have negative values.
+	Value otils.NullableFloat64 `json:"value,omitempty"`
+
+	// Notice is an optional explanation of the
+	// component e.g that surge pricing is in effect.
+	Notice string `json:"notice,omitempty"`
+}
+
+// Money returns the value of the fare.
+func (f *Fare) Money() Money {
+	if f == nil {
+		return Money{}
+	}
+	return Money{Amount: float64(f.Value), CurrencyCode: CurrencyCode(f.CurrencyCode)}
 }
 
 type UpfrontFare struct {
@@ -239,12 +655,94 @@ type UpfrontFare struct {
 	PickupEstimateMinutes otils.NullableFloat64 `json:"pickup_estimate,omitempty"`
 
 	Estimate *FareEstimate `json:"estimate,omitempty"`
+
+	// SurgeConfirmation is set when surge pricing is in effect
+	// and the rider must confirm the surge before requesting.
+	SurgeConfirmation *SurgeConfirmation `json:"surge_confirmation,omitempty"`
+
+	// TrackingURL is the mobile web page at which the
+	// rider can view the fare and then request the ride.
+	TrackingURL string `json:"href,omitempty"`
+
+	// Distance is the estimated distance of the trip in units
+	// of DistanceUnit. It is set from the fare's trip and is
+	// zero if Uber didn't provide a distance estimate.
+	Distance     float64 `json:"-"`
+	DistanceUnit string  `json:"-"`
+
+	// Duration is the estimated duration of the trip. It is set from
+	// the fare's trip and is zero if Uber didn't provide an estimate.
+	Duration time.Duration `json:"-"`
+}
+
+// SurgeConfirmation describes the surge that a rider must confirm.
+type SurgeConfirmation struct {
+	// URL is the page at which the rider confirms the surge.
+	URL string `json:"href,omitempty"`
+
+	// ID is the surge confirmation ID to set as the
+	// SurgeConfirmationID of a RideRequest after confirmation.
+	ID string `json:"surge_confirmation_id,omitempty"`
+
+	Multiplier    otils.NullableFloat64 `json:"multiplier,omitempty"`
+	ExpiresAtUnix int64                 `json:"expires_at,omitempty"`
+}
+
+var _ json.Unmarshaler = (*UpfrontFare)(nil)
+
+func (upf *UpfrontFare) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type upfrontFare UpfrontFare
+	if err := json.Unmarshal(b, (*upfrontFare)(upf)); err != nil {
+		return err
+	}
+
+	// Older responses only carry the surge
+	// confirmation details in the estimate.
+	if est := upf.Estimate; upf.SurgeConfirmation == nil && est != nil && (est.SurgeConfirmationURL != "" || est.SurgeConfirmationID != "") {
+		upf.SurgeConfirmation = &SurgeConfirmation{
+			URL:        est.SurgeConfirmationURL,
+			ID:         est.SurgeConfirmationID,
+			Multiplier: est.SurgeMultiplier,
+		}
+	}
+
+	if trip := upf.Trip; trip != nil {
+		upf.Distance = float64(trip.DistanceEstimate)
+		upf.DistanceUnit = trip.Unit
+		upf.Duration = time.Duration(float64(trip.DurationEstimate) * float64(time.Second))
+	}
+	return nil
+}
+
+// Component returns the first component of the fare's breakdown of
+// the given type e.g FareComponentBaseFare, and whether it was found.
+func (upf *UpfrontFare) Component(componentType string) (*FareComponent, bool) {
+	if upf == nil || upf.Fare == nil {
+		return nil, false
+	}
+	for _, component := range upf.Fare.Breakdown {
+		if component != nil && component.Type == componentType {
+			return component, true
+		}
+	}
+	return nil, false
 }
 
 func (upf *UpfrontFare) SurgeInEffect() bool {
 	return upf != nil && upf.Estimate != nil && upf.Estimate.SurgeConfirmationURL != ""
 }
 
+// NeedsSurgeConfirmation returns true if the rider must confirm
+// the surge at SurgeConfirmation.URL before requesting the ride.
+func (upf *UpfrontFare) NeedsSurgeConfirmation() bool {
+	if upf == nil || upf.SurgeConfirmation == nil {
+		return false
+	}
+	sc := upf.SurgeConfirmation
+	return sc.URL != "" || sc.ID != ""
+}
+
 func (upf *UpfrontFare) NoCarsAvailable() bool {
 	return upf == nil || upf.PickupEstimateMinutes <= 0
 }
@@ -253,6 +751,15 @@ var errInvalidSeatCount = errors.New("invalid seatcount, default and maximum val
 
 const defaultSeatCount = 2
 
+// validateSeatCount checks that seatCount is either
+// unset or within the range [1, 2] allowed for uberPOOL.
+func validateSeatCount(seatCount int) error {
+	if seatCount < 0 || seatCount > defaultSeatCount {
+		return errInvalidSeatCount
+	}
+	return nil
+}
+
 func (esReq *EstimateRequest) validateForUpfrontFare() error {
 	if esReq == nil {
 		return errNilEstimateRequest
@@ -260,8 +767,8 @@ func (esReq *EstimateRequest) validateForUpfrontFare() error {
 
 	// The number of seats required for uberPool.
 	// Default and maximum value is 2.
-	if esReq.SeatCount < 0 || esReq.SeatCount > 2 {
-		return errInvalidSeatCount
+	if err := validateSeatCount(esReq.SeatCount); err != nil {
+		return err
 	}
 
 	if esReq.SeatCount == 0 {
@@ -283,12 +790,18 @@ func (esReq *EstimateRequest) validateForUpfrontFare() error {
 
 var errNilFare = errors.New("failed to unmarshal the response fare")
 
+// UpfrontFareWithOptions is like UpfrontFare but with
+// the client settings overridden as per opts.
+func (c *Client) UpfrontFareWithOptions(esReq *EstimateRequest, opts *RequestOptions) (*UpfrontFare, error) {
+	return c.withOptions(opts).UpfrontFare(esReq)
+}
+
 func (c *Client) UpfrontFare(esReq *EstimateRequest) (*UpfrontFare, error) {
 	if err := esReq.validateForUpfrontFare(); err != nil {
 		return nil, err
 	}
 
-	blob, err := json.Marshal(esReq)
+	blob, err := MarshalCanonical(esReq)
 	if err != nil {
 		return nil, err
 	}
@@ -306,7 +819,7 @@ func (c *Client) UpfrontFare(esReq *EstimateRequest) (*UpfrontFare, error) {
 
 	upfrontFare := new(UpfrontFare)
 	var blankUFare UpfrontFare
-	if err := json.Unmarshal(slurp, upfrontFare); err != nil {
+	if err := c.unmarshalResponse(slurp, upfrontFare); err != nil {
 		return nil, err
 	}
 	if *upfrontFare == blankUFare {
@@ -314,3 +827,49 @@ func (c *Client) UpfrontFare(esReq *EstimateRequest) (*UpfrontFare, error) {
 	}
 	return upfrontFare, nil
 }
+
+// FareWithProduct retrieves the upfront fare for a request together
+// with the details of the product that the fare is for. If the request's
+// ProductID is set, both are fetched concurrently otherwise the product
+// is looked up using the ProductID of the fare's trip.
+// A failed product lookup doesn't discard the fare, instead
+// the fare is returned along with the product lookup's error.
+func (c *Client) FareWithProduct(esReq *EstimateRequest) (*UpfrontFare, *Product, error) {
+	if esReq == nil {
+		return nil, nil, errNilEstimateRequest
+	}
+
+	if esReq.ProductID == "" {
+		upfrontFare, err := c.UpfrontFare(esReq)
+		if err != nil {
+			return nil, nil, err
+		}
+		var productID string
+		if upfrontFare.Trip != nil {
+			productID = upfrontFare.Trip.ProductID
+		}
+		product, err := c.ProductByID(productID)
+		return upfrontFare, product, err
+	}
+
+	var wg sync.WaitGroup
+	var upfrontFare *UpfrontFare
+	var product *Product
+	var fareErr, productErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		upfrontFare, fareErr = c.UpfrontFare(esReq)
+	}()
+	go func() {
+		defer wg.Done()
+		product, productErr = c.ProductByID(esReq.ProductID)
+	}()
+	wg.Wait()
+
+	if fareErr != nil {
+		return nil, nil, fareErr
+	}
+	return upfrontFare, product, productErr
+}
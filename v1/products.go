@@ -15,12 +15,15 @@
 package uber
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"reflect"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/orijtech/otils"
 )
@@ -53,6 +56,9 @@ type Product struct {
 
 	DisplayName string `json:"display_name"`
 
+	// ProductGroup is the group of the product e.g ProductUberX.
+	ProductGroup ProductGroup `json:"product_group,omitempty"`
+
 	Description string `json:"description"`
 }
 
@@ -77,12 +83,41 @@ const (
 // In some markets, the list of products returned from this endpoint
 // may vary by the time of day due to time restrictions on
 // when that product may be utilized.
-func (c *Client) ListProducts(place *Place) ([]*Product, error) {
+//
+// Uber doesn't expose the cities or regions it operates in, so to check
+// whether a product or feature is offered in a region, list the products
+// at a location in that region and look for the product or ProductGroup.
+//
+// If caching was enabled with SetProductCacheTTL, the products are
+// cached per location, rounded to about 100m, and per API endpoint.
+// Calls passing any QueryOption bypass the cache.
+func (c *Client) ListProducts(place *Place, opts ...QueryOption) ([]*Product, error) {
+	if place == nil {
+		return nil, errNilPlace
+	}
 	qv, err := otils.ToURLValues(place)
 	if err != nil {
 		return nil, err
 	}
-	fullURL := fmt.Sprintf("%s/products?%s", c.baseURL(), qv.Encode())
+	c.addLocale(qv)
+	baseURL := c.baseURL()
+
+	cache, ttl := c.getProductCache()
+	if len(opts) > 0 {
+		cache = nil
+	}
+	var cacheKey string
+	if cache != nil {
+		cacheKey = productCacheKey(baseURL, c.getLocale(), place)
+		if products, ok := cache.get(cacheKey); ok {
+			return products, nil
+		}
+	}
+
+	fullURL, err := applyQueryOptions(fmt.Sprintf("%s/products?%s", baseURL, qv.Encode()), opts)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
 		return nil, err
@@ -92,12 +127,169 @@ func (c *Client) ListProducts(place *Place) ([]*Product, error) {
 		return nil, err
 	}
 	pWrap := new(productsWrap)
-	if err := json.Unmarshal(slurp, pWrap); err != nil {
+	if err := c.unmarshalResponse(slurp, pWrap); err != nil {
 		return nil, err
 	}
+
+	if cache != nil {
+		cache.put(cacheKey, pWrap.Products, ttl)
+	}
 	return pWrap.Products, nil
 }
 
+// ProductPricing joins a product with its price estimate for a route.
+type ProductPricing struct {
+	ProductID string `json:"product_id"`
+
+	// Product is nil if the product couldn't be
+	// listed but there was a price estimate for it.
+	Product *Product `json:"product,omitempty"`
+
+	// Estimate carries the price range and surge multiplier. It is
+	// nil if the product has no price estimate for the route.
+	Estimate *PriceEstimate `json:"estimate,omitempty"`
+}
+
+// ProductsWithPricing lists the products available at the start of
+// ereq's route along with their price estimates for the route. Both are
+// fetched concurrently and an error is only returned if both fail, so
+// if one fails the results only carry either the products or estimates.
+func (c *Client) ProductsWithPricing(ereq *EstimateRequest) ([]*ProductPricing, error) {
+	if ereq == nil {
+		return nil, errNilEstimateRequest
+	}
+
+	var products []*Product
+	var estimates []*PriceEstimate
+	var productsErr, estimatesErr error
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		place := &Place{Latitude: ereq.StartLatitude, Longitude: ereq.StartLongitude}
+		products, productsErr = c.ListProducts(place)
+	}()
+	go func() {
+		defer wg.Done()
+		estimates, estimatesErr = c.allPriceEstimates(ereq)
+	}()
+	wg.Wait()
+
+	if productsErr != nil && estimatesErr != nil {
+		return nil, productsErr
+	}
+
+	var pricings []*ProductPricing
+	byID := make(map[string]*ProductPricing)
+	for _, product := range products {
+		if product == nil {
+			continue
+		}
+		pricing := &ProductPricing{ProductID: product.ID, Product: product}
+		byID[product.ID] = pricing
+		pricings = append(pricings, pricing)
+	}
+	for _, estimate := range estimates {
+		if estimate == nil {
+			continue
+		}
+		pricing, ok := byID[estimate.ProductID]
+		if !ok {
+			pricing = &ProductPricing{ProductID: estimate.ProductID}
+			byID[estimate.ProductID] = pricing
+			pricings = append(pricings, pricing)
+		}
+		pricing.Estimate = estimate
+	}
+	return pricings, nil
+}
+
+// SetProductCacheTTL enables caching the results of ListProducts for
+// the given duration. A TTL of 0, the default, disables caching.
+// Clones of the client share its cache.
+func (c *Client) SetProductCacheTTL(ttl time.Duration) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.productCacheTTL = ttl
+	if ttl > 0 && c.productCache == nil {
+		c.productCache = new(productCache)
+	}
+}
+
+// ClearProductCache removes all the products cached by ListProducts.
+func (c *Client) ClearProductCache() {
+	c.RLock()
+	cache := c.productCache
+	c.RUnlock()
+
+	if cache != nil {
+		cache.clear()
+	}
+}
+
+func (c *Client) getProductCache() (*productCache, time.Duration) {
+	c.RLock()
+	defer c.RUnlock()
+
+	if c.productCacheTTL <= 0 {
+		return nil, 0
+	}
+	return c.productCache, c.productCacheTTL
+}
+
+// productCacheKey rounds the coordinates to 3 decimal places,
+// about 100m, so that nearby locations share cached products.
+func productCacheKey(baseURL, locale string, place *Place) string {
+	return fmt.Sprintf("%s|%s|%.3f,%.3f", baseURL, locale, place.Latitude, place.Longitude)
+}
+
+type productCacheEntry struct {
+	products  []*Product
+	expiresAt time.Time
+}
+
+type productCache struct {
+	mu      sync.Mutex
+	entries map[string]*productCacheEntry
+}
+
+func (pc *productCache) get(key string) ([]*Product, bool) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	entry, ok := pc.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(entry.expiresAt) {
+		delete(pc.entries, key)
+		return nil, false
+	}
+	// Copy the slice so that callers can't modify the cached one.
+	return append([]*Product(nil), entry.products...), true
+}
+
+func (pc *productCache) put(key string, products []*Product, ttl time.Duration) {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if pc.entries == nil {
+		pc.entries = make(map[string]*productCacheEntry)
+	}
+	pc.entries[key] = &productCacheEntry{
+		products:  append([]*Product(nil), products...),
+		expiresAt: time.Now().Add(ttl),
+	}
+}
+
+func (pc *productCache) clear() {
+	pc.mu.Lock()
+	pc.entries = nil
+	pc.mu.Unlock()
+}
+
 var (
 	errEmptyProductID = errors.New("expecting a non-empty productID")
 	errBlankProduct   = errors.New("received a blank product back from the server")
@@ -105,12 +297,21 @@ var (
 	blankProductPtr = new(Product)
 )
 
-func (c *Client) ProductByID(productID string) (*Product, error) {
+func (c *Client) ProductByID(productID string, opts ...QueryOption) (*Product, error) {
 	productID = strings.TrimSpace(productID)
 	if productID == "" {
 		return nil, errEmptyProductID
 	}
 	fullURL := fmt.Sprintf("%s/products/%s", c.baseURL(), productID)
+	qv := make(url.Values)
+	c.addLocale(qv)
+	if len(qv) > 0 {
+		fullURL += "?" + qv.Encode()
+	}
+	fullURL, err := applyQueryOptions(fullURL, opts)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
 		return nil, err
@@ -120,7 +321,7 @@ func (c *Client) ProductByID(productID string) (*Product, error) {
 		return nil, err
 	}
 	product := new(Product)
-	if err := json.Unmarshal(slurp, product); err != nil {
+	if err := c.unmarshalResponse(slurp, product); err != nil {
 		return nil, err
 	}
 	if reflect.DeepEqual(product, blankProductPtr) {
@@ -129,6 +330,136 @@ func (c *Client) ProductByID(productID string) (*Product, error) {
 	return product, nil
 }
 
+// ProductAvailability joins a product with its time estimate.
+type ProductAvailability struct {
+	Product      *Product      `json:"product,omitempty"`
+	TimeEstimate *TimeEstimate `json:"time_estimate,omitempty"`
+
+	// Err is set if the time estimate of the
+	// product could not be retrieved.
+	Err error `json:"-"`
+}
+
+var (
+	errNilPlace       = errors.New("expecting a non-nil place")
+	errNoTimeEstimate = errors.New("no time estimate for the product")
+)
+
+// ProductsWithETA retrieves the products offered at a place together
+// with their ETAs. Instead of a time estimate per product, it makes
+// exactly two concurrent requests: one for the products and the
+// other for the time estimates of all the products at the place.
+// It only fails if the products can't be retrieved, otherwise
+// every product whose ETA couldn't be retrieved has its Err set.
+func (c *Client) ProductsWithETA(place *Place) ([]*ProductAvailability, error) {
+	if place == nil {
+		return nil, errNilPlace
+	}
+
+	var wg sync.WaitGroup
+	var products []*Product
+	var estimates []*TimeEstimate
+	var productsErr, estimatesErr error
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		products, productsErr = c.ListProducts(place)
+	}()
+	go func() {
+		defer wg.Done()
+		estimates, estimatesErr = c.allTimeEstimates(&EstimateRequest{
+			StartLatitude:  place.Latitude,
+			StartLongitude: place.Longitude,
+		})
+	}()
+	wg.Wait()
+
+	if productsErr != nil {
+		return nil, productsErr
+	}
+
+	estimatesByProductID := make(map[string]*TimeEstimate)
+	for _, estimate := range estimates {
+		if estimate == nil {
+			continue
+		}
+		estimatesByProductID[estimate.ProductID] = estimate
+	}
+
+	availabilities := make([]*ProductAvailability, 0, len(products))
+	for _, product := range products {
+		if product == nil {
+			continue
+		}
+		pa := &ProductAvailability{Product: product}
+		switch estimate, ok := estimatesByProductID[product.ID]; {
+		case estimatesErr != nil:
+			pa.Err = estimatesErr
+		case !ok:
+			pa.Err = errNoTimeEstimate
+		default:
+			pa.TimeEstimate = estimate
+		}
+		availabilities = append(availabilities, pa)
+	}
+	return availabilities, nil
+}
+
+var (
+	ErrNoProductFitsParty = errors.New("no product can accommodate the party")
+
+	errInvalidPartySize = errors.New("expecting a party size of at least 1")
+)
+
+// SmallestProductForParty returns the cheapest of the products offered at
+// place whose capacity can accommodate partySize people. Products are ranked
+// by their minimum price, those without price details ranking after those
+// with them, in the order that Uber lists them. It returns
+// ErrNoProductFitsParty if none of the products is large enough.
+func (c *Client) SmallestProductForParty(place *Place, partySize int) (*Product, error) {
+	if place == nil {
+		return nil, errNilPlace
+	}
+	if partySize < 1 {
+		return nil, errInvalidPartySize
+	}
+
+	products, err := c.ListProducts(place)
+	if err != nil {
+		return nil, err
+	}
+
+	var fitting []*Product
+	for _, product := range products {
+		if product != nil && product.Capacity >= partySize {
+			fitting = append(fitting, product)
+		}
+	}
+	if len(fitting) == 0 {
+		return nil, ErrNoProductFitsParty
+	}
+
+	sort.SliceStable(fitting, func(i, j int) bool {
+		iPrice, iKnown := fitting[i].minimumPrice()
+		jPrice, jKnown := fitting[j].minimumPrice()
+		if iKnown != jKnown {
+			return iKnown
+		}
+		return iPrice < jPrice
+	})
+	return fitting[0], nil
+}
+
+// minimumPrice returns the minimum price of the
+// product and whether the product's price is known.
+func (p *Product) minimumPrice() (float64, bool) {
+	if p.PriceDetails == nil || p.PriceDetails.Minimum <= 0 {
+		return 0, false
+	}
+	return float64(p.PriceDetails.Minimum), true
+}
+
 type productsWrap struct {
 	Products []*Product `json:"products"`
 }
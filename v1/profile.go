@@ -16,7 +16,6 @@ package uber
 
 import (
 	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
@@ -54,27 +53,50 @@ type Profile struct {
 	DriverID otils.NullableString `json:"driver_id,omitempty"`
 	RiderID  otils.NullableString `json:"rider_id,omitempty"`
 
+	// Vehicle is the vehicle of a driver and is only
+	// set on the profiles returned by DriverProfile.
+	Vehicle *Vehicle `json:"vehicle,omitempty"`
+
 	// Me if set, signifies that this Profile
 	// is of current authenticated user.
 	Me bool `json:"me,omitempty"`
 }
 
-func (c *Client) RetrieveMyProfile() (*Profile, error) {
-	return c.retrieveProfile("/me")
+func (c *Client) RetrieveMyProfile(opts ...QueryOption) (*Profile, error) {
+	return c.retrieveProfile("/me", opts)
+}
+
+// ErrUnauthorized is returned by VerifyToken when
+// Uber rejects the credentials of the client.
+var ErrUnauthorized = errors.New("unauthorized: the token is invalid or expired")
+
+// VerifyToken checks that the credentials of the client are still valid
+// by retrieving the profile of the user, returning nil if they are. It
+// returns ErrUnauthorized if Uber rejects the credentials, otherwise
+// the error encountered e.g if the request couldn't be sent.
+func (c *Client) VerifyToken() error {
+	_, err := c.RetrieveMyProfile()
+	if statusCode(err) == http.StatusUnauthorized {
+		return ErrUnauthorized
+	}
+	return err
 }
 
-func (c *Client) retrieveProfile(path string, versions ...string) (*Profile, error) {
-	fullURL := fmt.Sprintf("%s%s", c.baseURL(versions...), path)
+func (c *Client) retrieveProfile(path string, opts []QueryOption, versions ...string) (*Profile, error) {
+	fullURL, err := applyQueryOptions(fmt.Sprintf("%s%s", c.baseURL(versions...), path), opts)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequest("GET", fullURL, nil)
 	if err != nil {
 		return nil, err
 	}
-	slurp, _, err := c.doReq(req)
+	slurp, _, err := c.doETagReq(req)
 	if err != nil {
 		return nil, err
 	}
 	prof := new(Profile)
-	if err := json.Unmarshal(slurp, prof); err != nil {
+	if err := c.unmarshalResponse(slurp, prof); err != nil {
 		return nil, err
 	}
 	return prof, nil
@@ -91,6 +113,8 @@ type PromoCodeRequest struct {
 	CodeToApply string `json:"applied_promotion_codes"`
 }
 
+// ApplyPromoCode applies a promo code to the user's account. Uber's
+// API only allows applying promo codes, not listing nor removing them.
 func (c *Client) ApplyPromoCode(promoCode string) (*PromoCode, error) {
 	if promoCode == "" {
 		return nil, errNilPromoCode
@@ -100,7 +124,7 @@ func (c *Client) ApplyPromoCode(promoCode string) (*PromoCode, error) {
 		CodeToApply: promoCode,
 	}
 
-	blob, err := json.Marshal(pcReq)
+	blob, err := MarshalCanonical(pcReq)
 	if err != nil {
 		return nil, err
 	}
@@ -116,7 +140,7 @@ func (c *Client) ApplyPromoCode(promoCode string) (*PromoCode, error) {
 	}
 
 	appliedPromoCode := new(PromoCode)
-	if err := json.Unmarshal(slurp, appliedPromoCode); err != nil {
+	if err := c.unmarshalResponse(slurp, appliedPromoCode); err != nil {
 		return nil, err
 	}
 
@@ -15,10 +15,10 @@
 package uber
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/orijtech/otils"
 )
@@ -46,13 +46,31 @@ type Receipt struct {
 
 	// Duration is the ISO 8601 HH:MM:SS
 	// format of the time duration of the trip.
-	Duration otils.NullableString `json:"currency_code"`
+	Duration otils.NullableString `json:"duration"`
 
 	// Distance of the trip charged.
 	Distance otils.NullableString `json:"distance"`
 
 	// UnitOfDistance is the localized unit of distance.
 	UnitOfDistance otils.NullableString `json:"distance_label"`
+
+	// Charges are the line items of the fare e.g base fare,
+	// distance and time, before any surge and adjustments.
+	Charges []*Charge `json:"charges,omitempty"`
+
+	// SurgeCharge is only set if surge pricing was applied.
+	SurgeCharge *Charge `json:"surge_charge,omitempty"`
+
+	// ChargeAdjustments are the adjustments to the
+	// fare e.g promotions, credits and split fares.
+	ChargeAdjustments []*Charge `json:"charge_adjustments,omitempty"`
+}
+
+// Charge is a line item of a receipt.
+type Charge struct {
+	Name   string                `json:"name"`
+	Amount otils.NullableFloat64 `json:"amount"`
+	Type   string                `json:"type,omitempty"`
 }
 
 var errEmptyReceiptID = errors.New("expecting a non-empty receiptID")
@@ -74,9 +92,63 @@ func (c *Client) RequestReceipt(receiptID string) (*Receipt, error) {
 	}
 
 	receipt := new(Receipt)
-	if err := json.Unmarshal(slurp, receipt); err != nil {
+	if err := c.unmarshalResponse(slurp, receipt); err != nil {
 		return nil, err
 	}
 
 	return receipt, nil
 }
+
+// TotalMoney returns the total amount charged to the user in the
+// currency of the receipt. The amount is zero if it couldn't be parsed.
+func (r *Receipt) TotalMoney() Money {
+	if r == nil {
+		return Money{}
+	}
+	total := otils.FirstNonEmptyString(string(r.TotalCharged), string(r.TotalFare))
+	amount, _ := parseAmount(total)
+	return Money{Amount: amount, CurrencyCode: CurrencyCode(r.CurrencyCode)}
+}
+
+// SubtotalMoney returns the subtotal of the receipt in its
+// currency. The amount is zero if it couldn't be parsed.
+func (r *Receipt) SubtotalMoney() Money {
+	if r == nil {
+		return Money{}
+	}
+	amount, _ := parseAmount(string(r.Subtotal))
+	return Money{Amount: amount, CurrencyCode: CurrencyCode(r.CurrencyCode)}
+}
+
+// Charge returns the charge whose name or type matches
+// name case-insensitively e.g "Base Fare" or "base_fare".
+// The surge charge and the adjustments are looked up too.
+func (r *Receipt) Charge(name string) (*Charge, bool) {
+	if r == nil {
+		return nil, false
+	}
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, false
+	}
+
+	charges := append([]*Charge(nil), r.Charges...)
+	if r.SurgeCharge != nil {
+		charges = append(charges, r.SurgeCharge)
+	}
+	charges = append(charges, r.ChargeAdjustments...)
+	for _, charge := range charges {
+		if charge == nil {
+			continue
+		}
+		if strings.EqualFold(charge.Name, name) || strings.EqualFold(charge.Type, name) {
+			return charge, true
+		}
+	}
+	return nil, false
+}
+
+// SurgeApplied reports whether the fare of the trip was surged.
+func (r *Receipt) SurgeApplied() bool {
+	return r != nil && r.SurgeCharge != nil && r.SurgeCharge.Amount > 0
+}
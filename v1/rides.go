@@ -22,17 +22,26 @@ import (
 	"net/http"
 	"reflect"
 	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/orijtech/otils"
 )
 
 type RideRequest struct {
 	// FareID is the ID of the upfront fare. If FareID is blank
 	// and you would like an inspection of current estimates,
-	// set PromptOnFare to review the upfront fare.
+	// set PromptOnFare to review the upfront fare. If FareID is
+	// set, the ride is requested directly without first fetching
+	// an upfront fare, even if PromptOnFare is set.
 	FareID string `json:"fare_id,omitempty"`
 
 	// PromptOnFare is an optional callback function that is
 	// used when FareID is blank. It is invoked to inspect and
-	// accept the upfront fare estimate or any surges in effect.
+	// accept the upfront fare estimate or any surges in effect
+	// e.g by checking UpfrontFare.NeedsSurgeConfirmation.
+	// It is not used if Client.SetFollowFareRedirect(false)
+	// was invoked.
 	PromptOnFare func(*UpfrontFare) error `json:"-"`
 
 	// StartPlace can be used in place of (StartLatitude, StartLongitude)
@@ -46,6 +55,10 @@ type RideRequest struct {
 	EndLatitude    float64 `json:"end_latitude,omitempty"`
 	EndLongitude   float64 `json:"end_longitude,omitempty"`
 
+	// Waypoints are the optional intermediate stops between
+	// the start and the end, for products that allow them.
+	Waypoints []*Waypoint `json:"waypoints,omitempty"`
+
 	// Optional fields
 	// Product is the ID of the product being requested. If none is provided,
 	// it will default to the cheapest product for the given location.
@@ -79,12 +92,25 @@ type RideRequest struct {
 	// * Uber For Business: https://www.uber.com/business
 	// * Business Profiles: https://www.uber.com/business/profiles
 	ExpenseMemo string `json:"expense_memo,omitempty"`
+
+	// NoteForDriver is an optional note shown to the driver
+	// e.g to describe where exactly to pick up the rider.
+	// It is limited to MaxNoteForDriverLength characters.
+	NoteForDriver string `json:"note_for_driver,omitempty"`
+
+	// IdempotencyKey if set, is sent as the Idempotency-Key header
+	// so that retrying the request doesn't book another ride.
+	// See also Client.SetAutoIdempotency.
+	IdempotencyKey string `json:"-"`
 }
 
 func (c *Client) preprocessBeforeValidate(rr *RideRequest) (*RideRequest, error) {
 	if rr == nil || strings.TrimSpace(rr.FareID) != "" || rr.PromptOnFare == nil {
 		return rr, nil
 	}
+	if !c.followsFareRedirect() {
+		return rr, nil
+	}
 
 	// Otherwise it is time to get the estimate of the fare
 	upfrontFare, err := c.UpfrontFare(&EstimateRequest{
@@ -95,6 +121,8 @@ func (c *Client) preprocessBeforeValidate(rr *RideRequest) (*RideRequest, error)
 		EndLatitude:    rr.EndLatitude,
 		EndLongitude:   rr.EndLongitude,
 		SeatCount:      rr.SeatCount,
+
+		PaymentMethodID: rr.PaymentMethodID,
 	})
 
 	if err != nil {
@@ -122,25 +150,83 @@ func (rr *RideRequest) Validate() error {
 		return ErrInvalidFareID
 	}
 
-	// Either:
+	// Either but not both of:
 	// 1. Start:
 	//    * StartPlace
 	//    * (StartLatitude, StartLongitude)
 	// 2. End:
 	//    * EndPlace
 	//    * (EndLatitude, EndLongitude)
-	if blankPlaceOrCoords(rr.StartPlace, rr.StartLatitude, rr.StartLongitude) {
-		return ErrInvalidStartPlaceOrCoords
+	err := validatePlaceOrCoords(rr.StartPlace, rr.StartLatitude, rr.StartLongitude,
+		ErrAmbiguousStartPlaceAndCoords, ErrInvalidStartPlaceOrCoords, ErrInvalidStartPlaceOrCoords)
+	if err != nil {
+		return err
+	}
+
+	err = validatePlaceOrCoords(rr.EndPlace, rr.EndLatitude, rr.EndLongitude,
+		ErrAmbiguousEndPlaceAndCoords, ErrInvalidEndPlaceOrCoords, ErrInvalidEndPlaceOrCoords)
+	if err != nil {
+		return err
+	}
+
+	for _, wp := range rr.Waypoints {
+		if err := wp.Validate(); err != nil {
+			return err
+		}
 	}
 
-	if blankPlaceOrCoords(rr.EndPlace, rr.EndLatitude, rr.EndLongitude) {
-		return ErrInvalidEndPlaceOrCoords
+	if utf8.RuneCountInString(rr.NoteForDriver) > MaxNoteForDriverLength {
+		return ErrNoteForDriverTooLong
 	}
 
 	return nil
 }
 
+// MaxNoteForDriverLength is the maximum number
+// of characters of a RideRequest's NoteForDriver.
+const MaxNoteForDriverLength = 256
+
+var ErrNoteForDriverTooLong = fmt.Errorf("the note for the driver is limited to %d characters", MaxNoteForDriverLength)
+
+// Waypoint is an intermediate stop of a ride.
+type Waypoint struct {
+	// Place can be used in place of (Latitude, Longitude)
+	Place PlaceName `json:"place_id,omitempty"`
+
+	Latitude  float64 `json:"latitude,omitempty"`
+	Longitude float64 `json:"longitude,omitempty"`
+}
+
+var ErrInvalidWaypoint = errors.New("expecting a waypoint with either a place or (lat, lon)")
+
+func (wp *Waypoint) Validate() error {
+	if wp == nil {
+		return ErrInvalidWaypoint
+	}
+	if strings.TrimSpace(string(wp.Place)) != "" {
+		if blankPlaceOrCoords(wp.Place, 0, 0) {
+			return ErrInvalidWaypoint
+		}
+		return nil
+	}
+	if wp.Latitude == 0 && wp.Longitude == 0 {
+		return ErrInvalidWaypoint
+	}
+	return nil
+}
+
+// RequestRideWithOptions is like RequestRide but with
+// the client settings overridden as per opts.
+func (c *Client) RequestRideWithOptions(rreq *RideRequest, opts *RequestOptions) (*Ride, error) {
+	return c.withOptions(opts).RequestRide(rreq)
+}
+
+var errNilRideRequest = errors.New("expecting a non-nil rideRequest")
+
 func (c *Client) RequestRide(rreq *RideRequest) (*Ride, error) {
+	if rreq == nil {
+		return nil, errNilRideRequest
+	}
 	rr, err := c.preprocessBeforeValidate(rreq)
 	if err != nil {
 		return nil, err
@@ -150,7 +236,11 @@ func (c *Client) RequestRide(rreq *RideRequest) (*Ride, error) {
 		return nil, err
 	}
 
-	blob, err := json.Marshal(rr)
+	blob, err := MarshalCanonical(rr)
+	if err != nil {
+		return nil, err
+	}
+	idempotencyKey, err := c.idempotencyKey(rr.IdempotencyKey)
 	if err != nil {
 		return nil, err
 	}
@@ -160,12 +250,15 @@ func (c *Client) RequestRide(rreq *RideRequest) (*Ride, error) {
 		return nil, err
 	}
 	req.Header.Set("Content-Type", "application/json")
+	if idempotencyKey != "" {
+		req.Header.Set(idempotencyKeyHeader, idempotencyKey)
+	}
 	blob, _, err = c.doHTTPReq(req)
 	if err != nil {
 		return nil, err
 	}
 	ride := new(Ride)
-	if err := json.Unmarshal(blob, ride); err != nil {
+	if err := c.unmarshalResponse(blob, ride); err != nil {
 		return nil, err
 	}
 	return ride, nil
@@ -174,8 +267,83 @@ func (c *Client) RequestRide(rreq *RideRequest) (*Ride, error) {
 var (
 	ErrInvalidStartPlaceOrCoords = errors.New("invalid startPlace or (startLat, startLon)")
 	ErrInvalidEndPlaceOrCoords   = errors.New("invalid endPlace or (endLat, endLon)")
+
+	ErrAmbiguousStartPlaceAndCoords = errors.New("expecting either startPlace or (startLat, startLon) but not both")
+	ErrAmbiguousEndPlaceAndCoords   = errors.New("expecting either endPlace or (endLat, endLon) but not both")
 )
 
+// validatePlaceOrCoords checks that exactly one of a known place or
+// non-zero coordinates is set for a location. It returns errAmbiguous
+// if both are set, errInvalid for an unknown place and errBlank if
+// neither is set.
+func validatePlaceOrCoords(place PlaceName, lat, lon float64, errAmbiguous, errInvalid, errBlank error) error {
+	hasPlace := strings.TrimSpace(string(place)) != ""
+	hasCoords := lat != 0 || lon != 0
+	switch {
+	case hasPlace && hasCoords:
+		return errAmbiguous
+	case hasPlace:
+		if blankPlaceOrCoords(place, 0, 0) {
+			return errInvalid
+		}
+		return nil
+	case hasCoords:
+		return nil
+	default:
+		return errBlank
+	}
+}
+
+// DestinationUpdate is the new destination of an ongoing ride.
+type DestinationUpdate struct {
+	// EndPlace can be used in place of (EndLatitude, EndLongitude)
+	EndPlace PlaceName `json:"end_place_id,omitempty"`
+
+	EndLatitude  float64 `json:"end_latitude,omitempty"`
+	EndLongitude float64 `json:"end_longitude,omitempty"`
+}
+
+var (
+	errEmptyRequestID       = errors.New("expecting a non-empty requestID")
+	errNilDestinationUpdate = errors.New("expecting a non-nil destination update")
+	errBlankDestination     = errors.New("expecting either endPlace or (endLat, endLon)")
+)
+
+func (du *DestinationUpdate) Validate() error {
+	if du == nil {
+		return errNilDestinationUpdate
+	}
+
+	return validatePlaceOrCoords(du.EndPlace, du.EndLatitude, du.EndLongitude,
+		ErrAmbiguousEndPlaceAndCoords, ErrInvalidEndPlaceOrCoords, errBlankDestination)
+}
+
+// UpdateRideDestination updates the destination of an ongoing ride.
+// Uber's documented errors are returned as their ActionableError e.g
+// ErrDestinationOutsideServiceArea if the product can't go there.
+func (c *Client) UpdateRideDestination(requestID string, update *DestinationUpdate) error {
+	requestID = strings.TrimSpace(requestID)
+	if requestID == "" {
+		return errEmptyRequestID
+	}
+	if err := update.Validate(); err != nil {
+		return err
+	}
+
+	blob, err := MarshalCanonical(update)
+	if err != nil {
+		return err
+	}
+	fullURL := fmt.Sprintf("%s/requests/%s", c.baseURL(), requestID)
+	req, err := http.NewRequest("PATCH", fullURL, bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, _, err = c.doAuthAndHTTPReq(req)
+	return toActionableError(err)
+}
+
 func blankPlaceOrCoords(place PlaceName, lat, lon float64) bool {
 	if strings.TrimSpace(string(place)) != "" {
 		switch place {
@@ -212,6 +380,80 @@ type Ride struct {
 	// The surge pricing multiplier used to calculate the increased price of a request.
 	// A surge multiplier of 1.0 means surge pricing is not in effect.
 	SurgeMultiplier float32 `json:"surge_multiplier,omitempty"`
+
+	// TrackingHref is the mobile web page at which the ride can be
+	// tracked live. It is named so as not to clash with TrackingURL.
+	TrackingHref string `json:"href,omitempty"`
+}
+
+var _ json.Unmarshaler = (*Ride)(nil)
+
+func (r *Ride) UnmarshalJSON(b []byte) error {
+	// Using a type alias to avoid infinite recursion.
+	type ride Ride
+	recv := struct {
+		*ride
+		SurgeMultiplier flexibleFloat `json:"surge_multiplier,omitempty"`
+	}{ride: (*ride)(r)}
+	if err := json.Unmarshal(b, &recv); err != nil {
+		return err
+	}
+	r.SurgeMultiplier = float32(recv.SurgeMultiplier)
+	return nil
+}
+
+// flexibleFloat is a number that Uber sends either
+// as a JSON number e.g 1.5 or as a string e.g "1.5".
+type flexibleFloat float64
+
+var _ json.Unmarshaler = (*flexibleFloat)(nil)
+
+func (ff *flexibleFloat) UnmarshalJSON(b []byte) error {
+	var nf otils.NullableFloat64
+	if err := json.Unmarshal(b, &nf); err != nil {
+		return err
+	}
+	*ff = flexibleFloat(nf)
+	return nil
+}
+
+// TrackingURL returns the mobile web page at which the ride can be
+// tracked live, e.g to share with the rider, or "" if Uber sent none.
+func (r *Ride) TrackingURL() string {
+	if r == nil {
+		return ""
+	}
+	return r.TrackingHref
+}
+
+// PickupETA returns how long until the driver arrives at the pickup
+// location, from the pickup's ETA or otherwise the ride's, or 0 if
+// Uber sent neither e.g once the rider has been picked up.
+func (r *Ride) PickupETA() time.Duration {
+	switch {
+	case r == nil:
+		return 0
+	case r.Pickup != nil && r.Pickup.ETAMinutes > 0:
+		return minutesToDuration(float64(r.Pickup.ETAMinutes))
+	default:
+		return minutesToDuration(float64(r.ETAMinutes))
+	}
+}
+
+// DropoffETA returns how long until the ride arrives at its
+// destination, or 0 if Uber didn't send the destination's ETA.
+func (r *Ride) DropoffETA() time.Duration {
+	if r == nil || r.Destination == nil {
+		return 0
+	}
+	return minutesToDuration(float64(r.Destination.ETAMinutes))
+}
+
+func minutesToDuration(minutes float64) time.Duration {
+	if minutes <= 0 {
+		return 0
+	}
+	return time.Duration(minutes * float64(time.Minute))
 }
 
 func (r *Ride) SurgeInEffect() bool {
@@ -221,18 +463,49 @@ func (r *Ride) SurgeInEffect() bool {
 type Vehicle struct {
 	Model string `json:"model"`
 	Make  string `json:"make"`
+	Color string `json:"color,omitempty"`
 
 	LicensePlate string `json:"license_plate"`
 	PictureURL   string `json:"picture_url"`
 }
 
+// String describes the vehicle for display to a rider
+// looking for it e.g "Black Toyota Prius (7ABC123)".
+func (v *Vehicle) String() string {
+	if v == nil {
+		return ""
+	}
+	var parts []string
+	for _, part := range []string{v.Color, v.Make, v.Model} {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	desc := strings.Join(parts, " ")
+	plate := strings.TrimSpace(v.LicensePlate)
+	switch {
+	case plate == "":
+		return desc
+	case desc == "":
+		return plate
+	default:
+		return desc + " (" + plate + ")"
+	}
+}
+
+// Driver is the driver assigned to a ride. Uber's API only exposes
+// a driver through their ride, so use TripByID or CurrentTrip to
+// retrieve the ride's Driver and Vehicle.
 type Driver struct {
 	PhoneNumber string `json:"phone_number"`
 	SMSNumber   string `json:"sms_number"`
 
 	PictureURL string `json:"picture_url"`
 	Name       string `json:"name"`
-	Rating     int    `json:"rating"`
+
+	// Rating is the driver's rating out of 5 stars. Uber's API doesn't
+	// let riders rate their drivers, that's only possible in the Uber app.
+	Rating int `json:"rating"`
 }
 
 type State string
@@ -274,21 +547,37 @@ var errBlankTrip = errors.New("expecting a non-blank trip")
 // It is a privileged method that requires FULL ACCESS when
 // used for all Uber riders. See more information about scopes
 // here https://developer.uber.com/docs/riders/guides/scopes.
-func (c *Client) CurrentTrip() (*Trip, error) {
+func (c *Client) CurrentTrip(opts ...QueryOption) (*Trip, error) {
 	tripURL := fmt.Sprintf("%s/requests/current", c.baseURL())
-	return c.fetchTripByURL(tripURL)
+	return c.fetchTripByURL(tripURL, opts)
 }
 
 // TripByID returns the details of a trip whose ID is known.
 // It is a privileged method that requires FULL ACCESS when
 // used for all Uber riders. See more information about scopes
 // here https://developer.uber.com/docs/riders/guides/scopes.
-func (c *Client) TripByID(id string) (*Trip, error) {
+func (c *Client) TripByID(id string, opts ...QueryOption) (*Trip, error) {
 	tripURL := fmt.Sprintf("%s/requests/%s", c.baseURL(), id)
-	return c.fetchTripByURL(tripURL)
+	return c.fetchTripByURL(tripURL, opts)
+}
+
+// CurrentTripWithOptions is like CurrentTrip but with
+// the client settings overridden as per opts.
+func (c *Client) CurrentTripWithOptions(opts *RequestOptions) (*Trip, error) {
+	return c.withOptions(opts).CurrentTrip()
+}
+
+// TripByIDWithOptions is like TripByID but with
+// the client settings overridden as per opts.
+func (c *Client) TripByIDWithOptions(id string, opts *RequestOptions) (*Trip, error) {
+	return c.withOptions(opts).TripByID(id)
 }
 
-func (c *Client) fetchTripByURL(tripURL string) (*Trip, error) {
+func (c *Client) fetchTripByURL(tripURL string, opts []QueryOption) (*Trip, error) {
+	tripURL, err := applyQueryOptions(tripURL, opts)
+	if err != nil {
+		return nil, err
+	}
 	req, err := http.NewRequest("GET", tripURL, nil)
 	if err != nil {
 		return nil, err
@@ -299,7 +588,7 @@ func (c *Client) fetchTripByURL(tripURL string) (*Trip, error) {
 	}
 
 	tr := new(Trip)
-	if err := json.Unmarshal(blob, tr); err != nil {
+	if err := c.unmarshalResponse(blob, tr); err != nil {
 		return nil, err
 	}
 	if reflect.DeepEqual(tr, blankTrip) {
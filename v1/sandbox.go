@@ -0,0 +1,64 @@
+// Copyright 2017 orijtech. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package uber
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ErrNotSandboxed is returned by the methods that can
+// only be invoked on a client in sandbox mode.
+var ErrNotSandboxed = errors.New("only available in sandbox mode, see SetSandboxMode")
+
+var errInvalidSurgeMultiplier = errors.New("expecting a surge multiplier of at least 1.0")
+
+type sandboxProductUpdate struct {
+	SurgeMultiplier float64 `json:"surge_multiplier"`
+}
+
+// SandboxSetSurge sets the surge multiplier of a product in the sandbox
+// so that ride requests for it go through surge confirmation, which is
+// handy to test surge confirmation flows. A multiplier of 1.0 turns surge
+// off. It returns ErrNotSandboxed if the client isn't in sandbox mode.
+// See https://developer.uber.com/docs/riders/guides/sandbox
+func (c *Client) SandboxSetSurge(productID string, multiplier float64) error {
+	if !c.Sandboxed() {
+		return ErrNotSandboxed
+	}
+	productID = strings.TrimSpace(productID)
+	if productID == "" {
+		return errEmptyProductID
+	}
+	if multiplier < 1.0 {
+		return errInvalidSurgeMultiplier
+	}
+
+	blob, err := MarshalCanonical(&sandboxProductUpdate{SurgeMultiplier: multiplier})
+	if err != nil {
+		return err
+	}
+	fullURL := fmt.Sprintf("%s/sandbox/products/%s", c.baseURL(), productID)
+	req, err := http.NewRequest("PUT", fullURL, bytes.NewReader(blob))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	_, _, err = c.doAuthAndHTTPReq(req)
+	return err
+}
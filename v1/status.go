@@ -51,3 +51,44 @@ const (
 	// The receipt for the trip is ready.
 	StatusReceiptReady Status = "ready"
 )
+
+// The statuses of a delivery during which its courier can be tracked.
+const (
+	// The courier is on the way to the pickup location.
+	StatusEnRouteToPickup Status = "en_route_to_pickup"
+
+	// The courier has arrived at the pickup location.
+	StatusAtPickup Status = "at_pickup"
+
+	// The courier is on the way to the dropoff location.
+	StatusEnRouteToDropoff Status = "en_route_to_dropoff"
+
+	// The courier has arrived at the dropoff location.
+	StatusAtDropoff Status = "at_dropoff"
+
+	// The courier is returning the items to the pickup location.
+	StatusReturning Status = "returning"
+)
+
+// IsTerminal reports whether a trip in this status has
+// ended and will not transition to any other status.
+func (s Status) IsTerminal() bool {
+	switch s {
+	case StatusCompleted, StatusDriverCanceled, StatusRiderCanceled, StatusNoDriversAvailable:
+		return true
+	default:
+		return false
+	}
+}
+
+// IsActive reports whether a trip in this status is underway,
+// that is it is being matched to a driver or has one assigned.
+func (s Status) IsActive() bool {
+	switch s {
+	case StatusProcessing, StatusAccepted, StatusArriving, StatusInProgress,
+		StatusEnRouteToPickup, StatusAtPickup, StatusEnRouteToDropoff, StatusAtDropoff, StatusReturning:
+		return true
+	default:
+		return false
+	}
+}
@@ -15,6 +15,7 @@
 package uber
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -54,11 +55,35 @@ type TimeEstimatesPage struct {
 	PageNumber uint64
 }
 
+// itemDecoders decodes the estimates of a
+// truncated page, appending them to tep.
+func (tep *TimeEstimatesPage) itemDecoders() map[string]func(*json.Decoder) error {
+	return map[string]func(*json.Decoder) error{
+		"times": func(dec *json.Decoder) error {
+			estimate := new(TimeEstimate)
+			if err := dec.Decode(estimate); err != nil {
+				return err
+			}
+			tep.Estimates = append(tep.Estimates, estimate)
+			return nil
+		},
+	}
+}
+
 var timeExcludedValues = map[string]bool{
 	"seat_count": true,
 }
 
+// EstimateTime returns the ETAs of products near the start location of treq.
+// If treq.ProductID is set, only the ETA of that product is returned,
+// otherwise the ETAs of all the products available there are returned.
 func (c *Client) EstimateTime(treq *EstimateRequest) (pagesChan chan *TimeEstimatesPage, cancelPaging func(), err error) {
+	return c.EstimateTimeWithContext(context.Background(), treq)
+}
+
+// EstimateTimeWithContext is like EstimateTime but the paging also stops once ctx
+// is done, which aborts the request of a page being fetched.
+func (c *Client) EstimateTimeWithContext(ctx context.Context, treq *EstimateRequest) (pagesChan chan *TimeEstimatesPage, cancelPaging func(), err error) {
 	if treq == nil {
 		return nil, nil, errNilTimeEstimateRequest
 	}
@@ -97,32 +122,41 @@ func (c *Client) EstimateTime(treq *EstimateRequest) (pagesChan chan *TimeEstima
 			qv, err := otils.ToURLValues(treq)
 			if err != nil {
 				tp.Err = err
-				estimatesPageChan <- tp
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, tp)
 				return
 			}
+			c.addLocale(qv)
 
 			fullURL := fmt.Sprintf("%s/estimates/time?%s", c.baseURL(), qv.Encode())
 			req, err := http.NewRequest("GET", fullURL, nil)
 			if err != nil {
 				tp.Err = err
-				estimatesPageChan <- tp
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, tp)
 				return
 			}
 
-			slurp, _, err := c.doReq(req)
+			slurp, _, err := c.doReq(req.WithContext(ctx))
+			if err == nil {
+				err = c.unmarshalResponse(slurp, tp)
+			}
 			if err != nil {
-				tp.Err = err
-				estimatesPageChan <- tp
-				return
+				partial := new(TimeEstimatesPage)
+				if tp.Err = truncatedResponse(slurp, err, partial.itemDecoders()); tp.Err != err {
+					tp.Estimates = partial.Estimates
+				}
 			}
 
-			if err := json.Unmarshal(slurp, tp); err != nil {
-				tp.Err = err
-				estimatesPageChan <- tp
+			if treq.ProductID != "" {
+				tp.Estimates = filterTimeEstimatesByProductID(tp.Estimates, treq.ProductID)
+			}
+			if tp.Err != nil {
+				sendPage(cancelChan, ctx.Done(), estimatesPageChan, tp)
 				return
 			}
 
-			estimatesPageChan <- tp
+			if !sendPage(cancelChan, ctx.Done(), estimatesPageChan, tp) {
+				return
+			}
 
 			if tp.Count <= 0 {
 				// No more items to page
@@ -135,6 +169,9 @@ func (c *Client) EstimateTime(treq *EstimateRequest) (pagesChan chan *TimeEstima
 				canPage = false
 				return
 
+			case <-ctx.Done():
+				return
+
 			case <-time.After(throttleDuration):
 				// Do nothing here, the throttle time expired.
 			}
@@ -153,3 +190,56 @@ func (c *Client) EstimateTime(treq *EstimateRequest) (pagesChan chan *TimeEstima
 
 	return estimatesPageChan, cancelFn, nil
 }
+
+// filterTimeEstimatesByProductID returns only the estimates for productID.
+// Uber only sends back the estimate of the requested product but
+// filtering guards against servers that send back all the products.
+func filterTimeEstimatesByProductID(estimates []*TimeEstimate, productID string) []*TimeEstimate {
+	var filtered []*TimeEstimate
+	for _, estimate := range estimates {
+		if estimate != nil && estimate.ProductID == productID {
+			filtered = append(filtered, estimate)
+		}
+	}
+	return filtered
+}
+
+// allTimeEstimates retrieves the time estimates
+// from every page of EstimateTime.
+func (c *Client) allTimeEstimates(treq *EstimateRequest) ([]*TimeEstimate, error) {
+	pagesChan, cancelPaging, err := c.EstimateTime(treq)
+	if err != nil {
+		return nil, err
+	}
+	defer cancelPaging()
+
+	var estimates []*TimeEstimate
+	for page := range pagesChan {
+		if page.Err != nil {
+			return nil, page.Err
+		}
+		estimates = append(estimates, page.Estimates...)
+	}
+	return estimates, nil
+}
+
+// DriversAvailable reports whether any drivers are available near
+// place, that is whether Uber returns at least one ETA for it.
+// It only retrieves the first page of time estimates, making it
+// cheap enough as a pre-check before offering to request a ride.
+func (c *Client) DriversAvailable(place *Place) (bool, error) {
+	if place == nil {
+		return false, errNilPlace
+	}
+
+	estimates, err := c.allTimeEstimates(&EstimateRequest{
+		StartLatitude:  place.Latitude,
+		StartLongitude: place.Longitude,
+
+		Pager: Pager{MaxPages: 1},
+	})
+	if err != nil {
+		return false, err
+	}
+	return len(estimates) > 0, nil
+}
@@ -32,6 +32,28 @@ func makeCancelParadigm() (<-chan bool, func()) {
 	return cancelChan, cancelFn
 }
 
+// sendPage sends page on pagesChan unless the paging was canceled,
+// either through cancelChan or done, in which case the page is
+// discarded and false is returned. done may be nil.
+func sendPage[P any](cancelChan <-chan bool, done <-chan struct{}, pagesChan chan<- P, page P) bool {
+	select {
+	case <-cancelChan:
+		return false
+	case <-done:
+		return false
+	default:
+	}
+
+	select {
+	case <-cancelChan:
+		return false
+	case <-done:
+		return false
+	case pagesChan <- page:
+		return true
+	}
+}
+
 type Error struct {
 	Meta   interface{}         `json:"meta"`
 	Errors []*statusCodedError `json:"errors"`
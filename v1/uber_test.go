@@ -16,22 +16,34 @@ package uber_test
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
 	"reflect"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"testing/iotest"
 	"time"
 
 	"golang.org/x/oauth2"
 
 	uberOAuth2 "github.com/garfieldchenyu/uber/oauth2"
 	"github.com/garfieldchenyu/uber/v1"
+	"github.com/orijtech/otils"
 )
 
 var blankTrip = new(uber.Trip)
@@ -114,6 +126,60 @@ func TestTripByID(t *testing.T) {
 	}
 }
 
+func TestDecodePolyline(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetHTTPRoundTripper(&tRoundTripper{route: tripByIDRoute})
+	trip, err := client.TripByID("a1111c8c-c720-46c3-8534-2fcdd730040d")
+	if err != nil {
+		t.Fatalf("retrieving the trip: %v", err)
+	}
+
+	// The example of Google's polyline algorithm.
+	googleExample := []uber.LatLng{
+		{Latitude: 38.5, Longitude: -120.2},
+		{Latitude: 40.7, Longitude: -120.95},
+		{Latitude: 43.252, Longitude: -126.453},
+	}
+
+	tests := [...]struct {
+		trip    *uber.Trip
+		want    []uber.LatLng
+		wantErr bool
+	}{
+		0: {trip: trip, want: googleExample},
+		1: {trip: &uber.Trip{RoutePolyline: "_p~iF~ps|U_ulLnnqC_mqNvxq`@"}, want: googleExample},
+		2: {trip: &uber.Trip{}, want: []uber.LatLng{}},
+		3: {trip: nil, want: []uber.LatLng{}},
+		4: {trip: &uber.Trip{RoutePolyline: "??"}, want: []uber.LatLng{{Latitude: 0, Longitude: 0}}},
+
+		// Truncated in the middle of the first point.
+		5: {trip: &uber.Trip{RoutePolyline: "_p~iF"}, wantErr: true},
+		6: {trip: &uber.Trip{RoutePolyline: "_p~iF~ps|"}, wantErr: true},
+		// Characters outside of the encoding's range.
+		7: {trip: &uber.Trip{RoutePolyline: "_p~iF ps|U"}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		points, err := tt.trip.DecodePolyline()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error, got points: %v", i, points)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(points, tt.want) {
+			t.Errorf("#%d: points:\ngot:  %v\nwant: %v", i, points, tt.want)
+		}
+	}
+}
+
 func TestListPaymentMethods(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
@@ -128,7 +194,7 @@ func TestListPaymentMethods(t *testing.T) {
 		wantErr bool
 	}{
 		0: {
-			want: paymentListingFromFile("./testdata/list-payments-1.json"),
+			want: paymentListingFromFile("./fakeserver/fixtures/payment-methods.json"),
 		},
 	}
 
@@ -151,6 +217,67 @@ func TestListPaymentMethods(t *testing.T) {
 		if !bytes.Equal(gotBytes, wantBytes) {
 			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBytes, wantBytes)
 		}
+
+		if !pml.HasMethod("f43847de-8113-4587-c307-51c2d13a823c") {
+			t.Errorf("#%d: expected the visa payment method to be listed", i)
+		}
+		if pml.HasMethod("unknown-payment-method") {
+			t.Errorf("#%d: unexpectedly found an unknown payment method", i)
+		}
+	}
+}
+
+func TestPaymentMethodDisplayName(t *testing.T) {
+	listing := paymentListingFromFile("./fakeserver/fixtures/payment-methods.json")
+	if listing == nil {
+		t.Fatal("failed to read the payment listing")
+	}
+
+	var got []string
+	for _, method := range listing.Methods {
+		got = append(got, method.PaymentMethod.DisplayName())
+	}
+	want := []string{"Baidu Wallet", "Alipay", "Visa", "American Express", "Business"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("display names:\ngot:  %q\nwant: %q", got, want)
+	}
+
+	unknown := uber.StringToPaymentMethod("crypto_wallet")
+	others := [...]struct {
+		pm   *uber.PaymentMethod
+		want string
+	}{
+		0: {pm: &unknown, want: "unknown"},
+		1: {pm: nil, want: "unknown"},
+	}
+	for i, tt := range others {
+		if got := tt.pm.DisplayName(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+
+	// Payments display unknown types as their raw type.
+	var decoded []*uber.Payment
+	blob := `[{"type": "crypto_wallet"}, {"type": "visa"}, {"type": "gift_card"}, {"type": "unknown"}, {}]`
+	if err := json.Unmarshal([]byte(blob), &decoded); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	got = got[:0]
+	for _, payment := range decoded {
+		got = append(got, payment.DisplayName())
+	}
+	want = []string{"crypto_wallet", "Visa", "gift_card", "unknown", "unknown"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unknown types:\ngot:  %q\nwant: %q", got, want)
+	}
+	if decoded[0].PaymentMethod != uber.PaymentUnknown || decoded[0].RawType != "crypto_wallet" {
+		t.Errorf("unknown type: got method=%d rawType=%q", decoded[0].PaymentMethod, decoded[0].RawType)
+	}
+	if decoded[1].PaymentMethod != uber.PaymentVisa || decoded[1].RawType != "visa" {
+		t.Errorf("known type: got method=%d rawType=%q", decoded[1].PaymentMethod, decoded[1].RawType)
+	}
+	if got := (*uber.Payment)(nil).DisplayName(); got != "unknown" {
+		t.Errorf("nil payment: got=%q want=%q", got, "unknown")
 	}
 }
 
@@ -198,572 +325,6008 @@ func TestListProducts(t *testing.T) {
 	}
 }
 
-var blankProductPtr = new(uber.Product)
+func TestSmallestProductForParty(t *testing.T) {
+	place := &uber.Place{Latitude: 37.7752315, Longitude: -122.418075}
+	pricedProducts := `{"products":[
+		{"product_id":"xl","capacity":6,"price_details":{"minimum":9.5}},
+		{"product_id":"x","capacity":4,"price_details":{"minimum":7}},
+		{"product_id":"unpriced","capacity":4},
+		{"product_id":"black","capacity":4,"price_details":{"minimum":15}}
+	]}`
 
-func TestProductByID(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
+	tests := [...]struct {
+		place     *uber.Place
+		partySize int
+		products  string
+
+		wantID  string
+		wantErr error
+	}{
+		0: {place: place, partySize: 1, wantID: "26546650-e557-4a7b-86e7-6a3942445247"},
+		1: {place: place, partySize: 2, wantID: "26546650-e557-4a7b-86e7-6a3942445247"},
+		2: {place: place, partySize: 3, wantID: "a1111c8c-c720-46c3-8534-2fcdd730040d"},
+		3: {place: place, partySize: 4, wantID: "a1111c8c-c720-46c3-8534-2fcdd730040d"},
+		4: {place: place, partySize: 5, wantID: "821415d8-3bd5-4e27-9604-194e4359a449"},
+		5: {place: place, partySize: 6, wantID: "821415d8-3bd5-4e27-9604-194e4359a449"},
+		6: {place: place, partySize: 7, wantErr: uber.ErrNoProductFitsParty},
+		7: {place: place, partySize: 0, wantErr: errAny},
+		8: {place: nil, partySize: 2, wantErr: errAny},
+
+		// Priced products are ranked by their minimum price.
+		9:  {place: place, partySize: 4, products: pricedProducts, wantID: "x"},
+		10: {place: place, partySize: 5, products: pricedProducts, wantID: "xl"},
 	}
 
-	backend := &tRoundTripper{route: productByID}
-	client.SetHTTPRoundTripper(backend)
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		var rt http.RoundTripper = &tRoundTripper{route: listProducts}
+		if tt.products != "" {
+			rt = &bodyTrackingRoundTripper{code: http.StatusOK, contentType: "application/json", body: tt.products}
+		}
+		client.SetHTTPRoundTripper(rt)
+
+		product, err := client.SmallestProductForParty(tt.place, tt.partySize)
+		if tt.wantErr != nil {
+			if err == nil || (tt.wantErr != errAny && err != tt.wantErr) {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if product.ID != tt.wantID {
+			t.Errorf("#%d: productID: got=%q want=%q", i, product.ID, tt.wantID)
+		}
+	}
+}
 
+func TestStrictDecoding(t *testing.T) {
 	tests := [...]struct {
 		productID string
+		strict    bool
 		wantErr   bool
 	}{
-		0: {
-			productID: "", wantErr: true,
-		},
-		1: {
-			productID: "     ", wantErr: true,
-		},
-		2: {
-			productID: "a1111c8c-c720-46c3-8534-2fcdd730040d",
-		},
+		0: {productID: "a1111c8c-c720-46c3-8534-2fcdd730040d", strict: false},
+		1: {productID: "a1111c8c-c720-46c3-8534-2fcdd730040d", strict: true},
+		2: {productID: "extra-field", strict: false},
+		// The fixture has a field that Product doesn't know of.
+		3: {productID: "extra-field", strict: true, wantErr: true},
 	}
 
 	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(&tRoundTripper{route: productByID})
+		client.SetStrictDecoding(tt.strict)
+
 		product, err := client.ProductByID(tt.productID)
 		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d: expected a non-nil error", i)
+			if err == nil || !strings.Contains(err.Error(), "parking_fee_enabled") {
+				t.Errorf("#%d: expected an unknown field error, got %v", i, err)
 			}
 			continue
 		}
-
 		if err != nil {
-			t.Errorf("#%d: got err: %v want nil error", i, err)
+			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
-
-		if product == nil || reflect.DeepEqual(product, blankProductPtr) {
-			t.Errorf("#%d: expecting a non-blank product", i)
+		if product.ID != tt.productID {
+			t.Errorf("#%d: productID: got=%q want=%q", i, product.ID, tt.productID)
 		}
 	}
 }
 
-func TestCancelDelivery(t *testing.T) {
+func TestListProductsCache(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
 
-	backend := &tRoundTripper{route: cancelDeliveryRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
-	client.SetHTTPRoundTripper(transport)
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: listProducts}}
+	client.SetHTTPRoundTripper(recorder)
+
+	place := &uber.Place{Latitude: 37.77491, Longitude: -122.41941}
+	// Within the same 3 decimal places so it must share the cache entry.
+	nearby := &uber.Place{Latitude: 37.77488, Longitude: -122.41937}
+	elsewhere := &uber.Place{Latitude: 40.7128, Longitude: -74.006}
 
 	tests := [...]struct {
-		reqID   string
-		wantErr bool
+		ttl          time.Duration
+		clear        bool
+		sleep        time.Duration
+		places       []*uber.Place
+		wantRequests int
 	}{
-		0: {"", true},
-		1: {"     ", true},
-		2: {reqID: deliveryID1},
+		0: {
+			// By default caching is disabled.
+			places:       []*uber.Place{place, place},
+			wantRequests: 2,
+		},
+		1: {
+			ttl:          time.Hour,
+			places:       []*uber.Place{place, nearby, place},
+			wantRequests: 1,
+		},
+		2: {
+			ttl:          time.Hour,
+			places:       []*uber.Place{place, elsewhere, elsewhere},
+			wantRequests: 2,
+		},
+		3: {
+			// Clearing the cache must trigger a refetch.
+			ttl:          time.Hour,
+			clear:        true,
+			places:       []*uber.Place{place, place},
+			wantRequests: 2,
+		},
+		4: {
+			// Expired entries must be refetched.
+			ttl:          10 * time.Millisecond,
+			sleep:        30 * time.Millisecond,
+			places:       []*uber.Place{place, place},
+			wantRequests: 2,
+		},
 	}
 
 	for i, tt := range tests {
-		err := client.CancelDelivery(tt.reqID)
-		gotErr := err != nil
-		if gotErr != tt.wantErr {
-			t.Errorf("#%d: gotErr=(%v) wantErr=(%v) err=(%v)", i, gotErr, tt.wantErr, err)
+		client.SetProductCacheTTL(tt.ttl)
+		client.ClearProductCache()
+		recorder.Lock()
+		recorder.requests = nil
+		recorder.Unlock()
+
+		for j, place := range tt.places {
+			if j > 0 {
+				if tt.clear {
+					client.ClearProductCache()
+				}
+				time.Sleep(tt.sleep)
+			}
+			products, err := client.ListProducts(place)
+			if err != nil {
+				t.Errorf("#%d: listProducts #%d: %v", i, j, err)
+				continue
+			}
+			if len(products) == 0 {
+				t.Errorf("#%d: listProducts #%d: expecting at least one product", i, j)
+			}
+		}
+
+		recorder.Lock()
+		gotRequests := len(recorder.requests)
+		recorder.Unlock()
+		if gotRequests != tt.wantRequests {
+			t.Errorf("#%d: gotRequests=%d wantRequests=%d", i, gotRequests, tt.wantRequests)
 		}
 	}
 }
 
-func TestRequestDelivery(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
+// pathSuffixRoundTripper dispatches each request to the round tripper
+// keyed by the suffix of its path, failing the request if there is none.
+type pathSuffixRoundTripper map[string]http.RoundTripper
+
+func (prt pathSuffixRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for suffix, rt := range prt {
+		if strings.HasSuffix(req.URL.Path, suffix) {
+			return rt.RoundTrip(req)
+		}
 	}
+	return makeResp("unroutable", http.StatusInternalServerError), nil
+}
 
-	backend := &tRoundTripper{route: deliveryRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
-	client.SetHTTPRoundTripper(transport)
+func TestProductsWithPricing(t *testing.T) {
+	ereq := &uber.EstimateRequest{
+		StartLatitude:  37.7752315,
+		EndLatitude:    37.7752415,
+		StartLongitude: -122.418075,
+		EndLongitude:   -122.518075,
+	}
+	productsRT := &tRoundTripper{route: listProducts}
+	estimatesRT := &tRoundTripper{route: estimatePriceRoute}
 
 	tests := [...]struct {
-		req     *uber.DeliveryRequest
-		want    *uber.Delivery
-		wantErr bool
+		rt                      http.RoundTripper
+		wantErr                 bool
+		wantCount               int
+		wantProducts            int
+		wantEstimates           int
+		wantSurgeMultiplierByID map[string]float64
 	}{
 		0: {
-			req:     &uber.DeliveryRequest{},
-			wantErr: true,
+			rt:            pathSuffixRoundTripper{"/products": productsRT, "/estimates/price": estimatesRT},
+			wantCount:     9,
+			wantProducts:  9,
+			wantEstimates: 4,
+			wantSurgeMultiplierByID: map[string]float64{
+				"821415d8-3bd5-4e27-9604-194e4359a449": 1.6,
+				"57c0ff4e-1493-4ef9-a4df-6b961525cf92": 1.2,
+			},
 		},
 		1: {
-			req:     nil,
-			wantErr: true,
+			// Failed estimates leave only the products.
+			rt:           pathSuffixRoundTripper{"/products": productsRT},
+			wantCount:    9,
+			wantProducts: 9,
 		},
 		2: {
-			req:     &uber.DeliveryRequest{},
-			wantErr: true,
+			// Failed products leave only the estimates.
+			rt:            pathSuffixRoundTripper{"/estimates/price": estimatesRT},
+			wantCount:     4,
+			wantEstimates: 4,
 		},
 		3: {
-			req: &uber.DeliveryRequest{
-				Pickup: &uber.Endpoint{
-					Contact: &uber.Contact{
-						CompanyName:          "orijtech",
-						Email:                "deliveries@orijtech.com",
-						SendSMSNotifications: true,
-					},
-					Location: &uber.Location{
-						PrimaryAddress: "Empire State Building",
-						State:          "NY",
-						Country:        "US",
-					},
-					SpecialInstructions: "Please ask guest services for \"I Man\"",
-				},
-				Dropoff: &uber.Endpoint{
-					Contact: &uber.Contact{
-						FirstName:   "delivery",
-						LastName:    "bot",
-						CompanyName: "Uber",
-
-						SendEmailNotifications: true,
-					},
-					Location: &uber.Location{
-						PrimaryAddress:   "530 W 113th Street",
-						SecondaryAddress: "Floor 2",
-						Country:          "US",
-						PostalCode:       "10025",
-						State:            "NY",
-					},
-				},
-				Items: []*uber.Item{
-					{
-						Title:    "phone chargers",
-						Quantity: 10,
-					},
-					{
-						Title:    "Blue prints",
-						Fragile:  true,
-						Quantity: 1,
-					},
-				},
-			},
-			want: deliveryResponseFromFile(deliveryResponsePath(deliveryResponseID1)),
+			rt:      pathSuffixRoundTripper{},
+			wantErr: true,
 		},
 	}
 
 	for i, tt := range tests {
-		dres, err := client.RequestDelivery(tt.req)
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(tt.rt)
+
+		pricings, err := client.ProductsWithPricing(ereq)
 		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d: want non-nil error", i)
+				t.Errorf("#%d: expecting a non-nil error", i)
 			}
 			continue
 		}
-
 		if err != nil {
-			t.Errorf("#%d: unexpected err: %v", i, err)
+			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
+		if len(pricings) != tt.wantCount {
+			t.Errorf("#%d: got %d pricings want %d", i, len(pricings), tt.wantCount)
+		}
 
-		if dres == nil {
-			t.Errorf("#%d: expecting non-nil delivery response", i)
-			continue
+		gotProducts, gotEstimates := 0, 0
+		for j, pricing := range pricings {
+			if pricing.Product != nil {
+				gotProducts++
+				if pricing.Product.ID != pricing.ProductID {
+					t.Errorf("#%d: pricing #%d: product ID %q != %q", i, j, pricing.Product.ID, pricing.ProductID)
+				}
+			}
+			if est := pricing.Estimate; est != nil {
+				gotEstimates++
+				if est.ProductID != pricing.ProductID {
+					t.Errorf("#%d: pricing #%d: estimate product ID %q != %q", i, j, est.ProductID, pricing.ProductID)
+				}
+				if w, ok := tt.wantSurgeMultiplierByID[pricing.ProductID]; ok && float64(est.SurgeMultiplier) != w {
+					t.Errorf("#%d: pricing #%d: surge multiplier got=%v want=%v", i, j, est.SurgeMultiplier, w)
+				}
+			}
 		}
-		gotBytes := jsonSerialize(dres)
-		wantBytes := jsonSerialize(tt.want)
-		if !bytes.Equal(gotBytes, wantBytes) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBytes, wantBytes)
+		if gotProducts != tt.wantProducts {
+			t.Errorf("#%d: got %d products want %d", i, gotProducts, tt.wantProducts)
+		}
+		if gotEstimates != tt.wantEstimates {
+			t.Errorf("#%d: got %d estimates want %d", i, gotEstimates, tt.wantEstimates)
 		}
 	}
+
+	client, _ := uber.NewClient(testToken1)
+	if _, err := client.ProductsWithPricing(nil); err == nil {
+		t.Errorf("expecting an error for a nil estimate request")
+	}
 }
 
-func TestListDriverPayments(t *testing.T) {
+func TestDo(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
 
-	backend := &tRoundTripper{route: listDriverPaymentsRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
-	client.SetHTTPRoundTripper(transport)
-
 	tests := [...]struct {
-		req           *uber.DriverInfoQuery
-		wantPageCount int
-		wantItemCount int
-		wantErr       bool
+		route      string
+		method     string
+		path       string
+		body       interface{}
+		wantErr    bool
+		wantStatus int
+		wantPath   string
 	}{
 		0: {
-			req:           nil, // No page limit, pagination all of them
-			wantPageCount: 4,
-			wantItemCount: 10,
+			// The path must be absolute.
+			route: listProducts, method: "GET", path: "v1.2/products", wantErr: true,
 		},
 		1: {
-			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
-			wantPageCount: 1,
-			wantItemCount: 2,
+			route: listProducts, method: "GET", path: "/v1.2/products?latitude=37.7752315&longitude=-122.418075",
+			wantStatus: http.StatusOK, wantPath: "GET /v1.2/products",
 		},
 		2: {
-			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
-			wantPageCount: 3,
-			wantItemCount: 6,
+			route: updateRideDestinationRoute, method: "PATCH", path: "/v1.2/requests/" + ride1,
+			body:       map[string]interface{}{"end_place_id": "home"},
+			wantStatus: http.StatusNoContent, wantPath: "PATCH /v1.2/requests/" + ride1,
+		},
+		3: {
+			// Uber's error responses must be parsed.
+			route: updateRideDestinationRoute, method: "PATCH", path: "/v1.2/requests/" + outOfAreaRideID,
+			body:    map[string]interface{}{"end_place_id": "home"},
+			wantErr: true, wantStatus: http.StatusUnprocessableEntity,
+			wantPath: "PATCH /v1.2/requests/" + outOfAreaRideID,
 		},
 	}
 
 	for i, tt := range tests {
-		if tt.req != nil {
-			tt.req.Throttle = uber.NoThrottle
-		}
-		dres, err := client.ListDriverPayments(tt.req)
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: tt.route}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		out := make(map[string]interface{})
+		res, err := client.Do(tt.method, tt.path, tt.body, &out)
 		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d: want non-nil error", i)
+				t.Errorf("#%d: expecting a non-nil error", i)
 			}
-			continue
-		}
-
-		if err != nil {
+		} else if err != nil {
 			t.Errorf("#%d: unexpected err: %v", i, err)
 			continue
 		}
 
-		if dres == nil {
-			t.Errorf("#%d: expecting non-nil delivery response", i)
-			continue
+		if tt.wantStatus != 0 && (res == nil || res.StatusCode != tt.wantStatus) {
+			t.Errorf("#%d: got res=%v want status=%d", i, res, tt.wantStatus)
+		}
+		if tt.wantPath != "" && (len(recorder.requests) != 1 || recorder.requests[0] != tt.wantPath) {
+			t.Errorf("#%d: requests: got %q want %q", i, recorder.requests, tt.wantPath)
+		}
+		if tt.route == listProducts && err == nil {
+			if _, ok := out["products"]; !ok {
+				t.Errorf("#%d: expecting the response to be decoded, got %v", i, out)
+			}
 		}
+		if tt.wantErr && tt.wantStatus != 0 {
+			if _, ok := err.(*uber.Error); !ok {
+				t.Errorf("#%d: got err=%T want *uber.Error", i, err)
+			}
+		}
+	}
+}
 
-		itemCount := 0
-		pageCount := 0
+func TestDriversAvailable(t *testing.T) {
+	place := &uber.Place{Latitude: 37.7752315, Longitude: -122.418075}
+
+	tests := [...]struct {
+		place   *uber.Place
+		rt      http.RoundTripper
+		want    bool
+		wantErr bool
+	}{
+		0: {place: place, rt: &tRoundTripper{route: estimateTimeRoute}, want: true},
+		1: {
+			place: place,
+			rt:    &bodyTrackingRoundTripper{code: http.StatusOK, contentType: "application/json", body: `{"times":[]}`},
+			want:  false,
+		},
+		2: {place: nil, rt: &tRoundTripper{route: estimateTimeRoute}, wantErr: true},
+		3: {
+			place:   place,
+			rt:      &bodyTrackingRoundTripper{code: http.StatusInternalServerError, contentType: "application/json", body: `{}`},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(tt.rt)
+
+		available, err := client.DriversAvailable(tt.place)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if available != tt.want {
+			t.Errorf("#%d: available: got=%v want=%v", i, available, tt.want)
+		}
+	}
+}
+
+func TestProductsWithETA(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: productsWithETARoute}
+	client.SetHTTPRoundTripper(backend)
+
+	if _, err := client.ProductsWithETA(nil); err == nil {
+		t.Errorf("nil place: expected a non-nil error")
+	}
+
+	availabilities, err := client.ProductsWithETA(&uber.Place{Latitude: 37.7752315, Longitude: -122.418075})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	if got, want := len(availabilities), 9; got != want {
+		t.Fatalf("availabilities: got=%d want=%d", got, want)
+	}
+
+	// Only this product is missing from the time estimates fixture.
+	noETAProductID := "2832a1f5-cfc0-48bb-ab76-7ea7a62060e7"
+	for i, pa := range availabilities {
+		if pa.Product.ID == noETAProductID {
+			if pa.Err == nil || pa.TimeEstimate != nil {
+				t.Errorf("#%d: expected an error and no time estimate, got estimate: %#v", i, pa.TimeEstimate)
+			}
+			continue
+		}
+
+		if pa.Err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, pa.Err)
+			continue
+		}
+		if pa.TimeEstimate == nil || pa.TimeEstimate.ProductID != pa.Product.ID {
+			t.Errorf("#%d: time estimate %#v does not match product %q", i, pa.TimeEstimate, pa.Product.ID)
+		}
+	}
+
+	// Null products and time estimates are skipped.
+	client.SetHTTPRoundTripper(pathSuffixRoundTripper{
+		"/products":       endlessPagesRoundTripper(`{"products":[null,{"product_id":"p1"}]}`),
+		"/estimates/time": endlessPagesRoundTripper(`{"times":[null,{"product_id":"p1"}]}`),
+	})
+	availabilities, err = client.ProductsWithETA(&uber.Place{Latitude: 37.7752315, Longitude: -122.418075})
+	if err != nil {
+		t.Fatalf("null elements: unexpected err: %v", err)
+	}
+	if len(availabilities) != 1 || availabilities[0].Err != nil || availabilities[0].TimeEstimate == nil {
+		t.Errorf("null elements: got %#v, want only the availability of p1", availabilities)
+	}
+}
+
+var blankProductPtr = new(uber.Product)
+
+func TestProductByID(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: productByID}
+	client.SetHTTPRoundTripper(backend)
+
+	tests := [...]struct {
+		productID string
+		wantErr   bool
+	}{
+		0: {
+			productID: "", wantErr: true,
+		},
+		1: {
+			productID: "     ", wantErr: true,
+		},
+		2: {
+			productID: "a1111c8c-c720-46c3-8534-2fcdd730040d",
+		},
+	}
+
+	for i, tt := range tests {
+		product, err := client.ProductByID(tt.productID)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: got err: %v want nil error", i, err)
+			continue
+		}
+
+		if product == nil || reflect.DeepEqual(product, blankProductPtr) {
+			t.Errorf("#%d: expecting a non-blank product", i)
+		}
+	}
+}
+
+type slowRoundTripper time.Duration
+
+var _ http.RoundTripper = (*slowRoundTripper)(nil)
+
+func (srt slowRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	select {
+	case <-req.Context().Done():
+		return nil, req.Context().Err()
+	case <-time.After(time.Duration(srt)):
+		return makeResp("Gateway Timeout", http.StatusGatewayTimeout), nil
+	}
+}
+
+func TestCustomHTTPClient(t *testing.T) {
+	tests := [...]struct {
+		hc      *http.Client
+		rt      http.RoundTripper
+		wantErr bool
+	}{
+		0: {
+			hc: &http.Client{Transport: &tRoundTripper{route: listProducts}},
+		},
+		1: {
+			// The client's timeout must be respected.
+			hc:      &http.Client{Timeout: 10 * time.Millisecond, Transport: slowRoundTripper(time.Minute)},
+			wantErr: true,
+		},
+		2: {
+			// The roundtripper takes precedence over the client's transport.
+			hc: &http.Client{Timeout: 10 * time.Second, Transport: slowRoundTripper(time.Minute)},
+			rt: &tRoundTripper{route: listProducts},
+		},
+		3: {
+			// The client's timeout must still be respected with a custom roundtripper.
+			hc:      &http.Client{Timeout: 10 * time.Millisecond},
+			rt:      slowRoundTripper(time.Minute),
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClientWithHTTPClient(testToken1, tt.hc)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
+		}
+		if tt.rt != nil {
+			client.SetHTTPRoundTripper(tt.rt)
+		}
+
+		products, err := client.ListProducts(&uber.Place{})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: got err: %v want nil error", i, err)
+			continue
+		}
+
+		if len(products) == 0 {
+			t.Errorf("#%d: expecting at least one product", i)
+		}
+	}
+}
+
+func TestLoginRedirect(t *testing.T) {
+	backend := &tRoundTripper{route: loginRedirectRoute}
+	noRedirectsClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	tests := [...]struct {
+		hc *http.Client
+	}{
+		0: {
+			// Following the redirect lands on the HTML login page.
+			hc: nil,
+		},
+		1: {
+			// The redirect itself is returned.
+			hc: noRedirectsClient,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClientWithHTTPClient(testToken1, tt.hc)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
+		}
+		client.SetHTTPRoundTripper(backend)
+
+		product, err := client.ProductByID("a1111c8c-c720-46c3-8534-2fcdd730040d")
+		if err != uber.ErrUnauthenticated {
+			t.Errorf("#%d: got err=%v want=%v, product: %#v", i, err, uber.ErrUnauthenticated, product)
+		}
+	}
+}
+
+func TestHTMLErrorPages(t *testing.T) {
+	tests := [...]struct {
+		code                int
+		wantUnauthenticated bool
+		wantCode            int
+	}{
+		0: {code: http.StatusOK, wantUnauthenticated: true},
+		1: {code: http.StatusUnauthorized, wantUnauthenticated: true},
+		2: {code: http.StatusBadGateway, wantCode: http.StatusBadGateway},
+		3: {code: http.StatusServiceUnavailable, wantCode: http.StatusServiceUnavailable},
+		4: {code: http.StatusGatewayTimeout, wantCode: http.StatusGatewayTimeout},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{
+			code:        tt.code,
+			contentType: "text/html; charset=utf-8",
+			body:        "<html><body><h1>" + http.StatusText(tt.code) + "</h1></body></html>",
+		})
+
+		_, err = client.ProductByID("a1111c8c-c720-46c3-8534-2fcdd730040d")
+		if tt.wantUnauthenticated {
+			if err != uber.ErrUnauthenticated {
+				t.Errorf("#%d: got err=%v want=%v", i, err, uber.ErrUnauthenticated)
+			}
+			continue
+		}
+
+		if err == nil || err == uber.ErrUnauthenticated {
+			t.Errorf("#%d: got err=%v want a coded error", i, err)
+			continue
+		}
+		coded, ok := err.(interface{ Code() int })
+		if !ok {
+			t.Errorf("#%d: expecting a coded error, got %#v", i, err)
+			continue
+		}
+		if g, w := coded.Code(), tt.wantCode; g != w {
+			t.Errorf("#%d: code: got=%d want=%d", i, g, w)
+		}
+	}
+}
+
+func TestAPIVersions(t *testing.T) {
+	retrieveMyProfile := func(c *uber.Client) error { _, err := c.RetrieveMyProfile(); return err }
+	driverProfile := func(c *uber.Client) error { _, err := c.DriverProfile(); return err }
+	listDriverTrips := func(c *uber.Client) error {
+		dres, err := c.ListDriverTrips(&uber.DriverInfoQuery{MaxPageNumber: 1})
+		if err != nil {
+			return err
+		}
 		for page := range dres.Pages {
 			if page.Err != nil {
-				t.Errorf("#%d: err: %v", i, page.Err)
-				continue
+				return page.Err
+			}
+		}
+		return nil
+	}
+
+	tests := [...]struct {
+		riderVersion  string
+		driverVersion string
+		do            func(c *uber.Client) error
+		want          string
+		wantErr       bool
+	}{
+		0: {do: retrieveMyProfile, want: "GET /v1.2/me"},
+		1: {do: driverProfile, want: "GET /v1/partners/me"},
+		2: {riderVersion: "v1.3", do: retrieveMyProfile, want: "GET /v1.3/me"},
+		3: {riderVersion: "v2", do: func(c *uber.Client) error {
+			_, err := c.Place(uber.PlaceHome)
+			return err
+		}, want: "GET /v2/places/home"},
+		// The rider version doesn't apply to the driver endpoints and vice versa.
+		4: {riderVersion: "v1.3", do: driverProfile, want: "GET /v1/partners/me"},
+		5: {driverVersion: "v1.1", do: retrieveMyProfile, want: "GET /v1.2/me"},
+		6: {driverVersion: "v1.1", do: driverProfile, want: "GET /v1.1/partners/me"},
+		7: {driverVersion: "v2", do: listDriverTrips, want: "GET /v2/partners/trips"},
+
+		8:  {riderVersion: "1.2", wantErr: true},
+		9:  {riderVersion: "v1.2/", wantErr: true},
+		10: {driverVersion: "../v1", wantErr: true},
+		11: {driverVersion: "latest", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		recorder := &recordingRoundTripper{base: &bodyTrackingRoundTripper{code: http.StatusOK, contentType: "application/json", body: "{}"}}
+		client.SetHTTPRoundTripper(recorder)
+
+		riderErr := client.SetRiderAPIVersion(tt.riderVersion)
+		driverErr := client.SetDriverAPIVersion(tt.driverVersion)
+		if tt.wantErr {
+			if riderErr == nil && driverErr == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+			continue
+		}
+		if riderErr != nil || driverErr != nil {
+			t.Errorf("#%d: setting versions: rider err=%v driver err=%v", i, riderErr, driverErr)
+			continue
+		}
+
+		if err := tt.do(client); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if len(recorder.requests) == 0 || recorder.requests[0] != tt.want {
+			t.Errorf("#%d: requests: got=%q want first=%q", i, recorder.requests, tt.want)
+		}
+
+		// The versions are carried over to clones.
+		clone := client.Clone()
+		clone.SetHTTPRoundTripper(recorder)
+		recorder.requests = nil
+		if err := tt.do(clone); err != nil {
+			t.Errorf("#%d: clone: unexpected error: %v", i, err)
+			continue
+		}
+		if len(recorder.requests) == 0 || recorder.requests[0] != tt.want {
+			t.Errorf("#%d: clone: requests: got=%q want first=%q", i, recorder.requests, tt.want)
+		}
+	}
+}
+
+func TestSetBaseURL(t *testing.T) {
+	tests := [...]struct {
+		baseURL   string
+		sandboxed bool
+		do        func(c *uber.Client) error
+		want      string
+		wantErr   bool
+	}{
+		0: {
+			baseURL: "https://gw.corp/uber-proxy/",
+			do: func(c *uber.Client) error {
+				_, err := c.Place(uber.PlaceHome)
+				return err
+			},
+			want: "https://gw.corp/uber-proxy/v1.2/places/home",
+		},
+		1: {
+			// No trailing slash and a sandboxed client: the
+			// custom base URL takes precedence over sandbox mode.
+			baseURL:   "https://gw.corp/uber-proxy",
+			sandboxed: true,
+			do: func(c *uber.Client) error {
+				_, err := c.ListProducts(&uber.Place{Latitude: 37.7752315, Longitude: -122.418075})
+				return err
+			},
+			want: "https://gw.corp/uber-proxy/v1.2/products?latitude=37.7752315&longitude=-122.418075",
+		},
+		2: {
+			// No path prefix.
+			baseURL: "http://localhost:8080",
+			do: func(c *uber.Client) error {
+				_, err := c.RetrieveMyProfile()
+				return err
+			},
+			want: "http://localhost:8080/v1.2/me",
+		},
+		3: {
+			// Clearing the base URL restores the default.
+			baseURL: "",
+			do: func(c *uber.Client) error {
+				_, err := c.RetrieveMyProfile()
+				return err
+			},
+			want: "https://api.uber.com/v1.2/me",
+		},
+		4: {
+			baseURL: "/uber-proxy",
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
+		}
+		backend := &tRoundTripper{route: baseURLRoute}
+		client.SetHTTPRoundTripper(backend)
+		client.SetSandboxMode(tt.sandboxed)
+
+		err = client.SetBaseURL(tt.baseURL)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: setting base URL; %v", i, err)
+			continue
+		}
+
+		if err := tt.do(client); err != nil {
+			t.Errorf("#%d: unexpected error: %v", i, err)
+			continue
+		}
+		if got, want := backend.exhaust, tt.want; got != want {
+			t.Errorf("#%d: URL:\ngot: %v\nwant:%v", i, got, want)
+		}
+	}
+}
+
+func TestMarshalCanonical(t *testing.T) {
+	tests := [...]struct {
+		v    interface{}
+		want string
+	}{
+		0: {
+			v:    nil,
+			want: `null`,
+		},
+		1: {
+			// Keys are sorted and nulls are omitted at every level.
+			v: map[string]interface{}{
+				"z": 1,
+				"a": nil,
+				"m": map[string]interface{}{"y": []interface{}{nil, "x"}, "b": nil},
+			},
+			want: `{"m":{"y":[null,"x"]},"z":1}`,
+		},
+		2: {
+			// Nil pointers of the package's types are omitted.
+			v:    &uber.Trip{TripID: "trip-1", Unit: "mile"},
+			want: `{"distance_unit":"mile","trip_id":"trip-1"}`,
+		},
+		3: {
+			// Numbers are preserved exactly.
+			v:    map[string]interface{}{"big": int64(1502844378123456789), "small": 0.1},
+			want: `{"big":1502844378123456789,"small":0.1}`,
+		},
+	}
+
+	for i, tt := range tests {
+		got, err := uber.MarshalCanonical(tt.v)
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if g, w := string(got), tt.want; g != w {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, g, w)
+		}
+	}
+
+	// Canonicalization must be stable and must not
+	// change the output of json.Marshal for the types.
+	fare := upfrontFareFromFileByID("surge")
+	first, err := uber.MarshalCanonical(fare)
+	if err != nil {
+		t.Fatalf("canonical: %v", err)
+	}
+	second, _ := uber.MarshalCanonical(fare)
+	if !bytes.Equal(first, second) {
+		t.Errorf("unstable canonical output:\n%s\n%s", first, second)
+	}
+	plain, err := json.Marshal(fare)
+	if err != nil {
+		t.Fatalf("json.Marshal: %v", err)
+	}
+	fromPlain, _ := uber.MarshalCanonical(json.RawMessage(plain))
+	if !bytes.Equal(first, fromPlain) {
+		t.Errorf("json.Marshal output differs:\ngot:  %s\nwant: %s", fromPlain, first)
+	}
+}
+
+func TestSetLocale(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: listProducts}}
+	client.SetHTTPRoundTripper(recorder)
+
+	tests := [...]struct {
+		locale     string
+		wantErr    bool
+		wantLocale string
+	}{
+		0: {locale: "", wantLocale: ""},
+		1: {locale: "fr_FR", wantLocale: "fr_FR"},
+		2: {locale: " zh-Hant-TW ", wantLocale: "zh-Hant-TW"},
+		3: {locale: "en", wantLocale: "en"},
+		4: {locale: "fr FR", wantErr: true},
+		5: {locale: "f", wantErr: true},
+		6: {locale: "fr_FR&x=1", wantErr: true},
+		7: {locale: "", wantLocale: ""},
+	}
+
+	place := &uber.Place{Latitude: 37.77491, Longitude: -122.41941}
+	lastLocale := ""
+	for i, tt := range tests {
+		err := client.SetLocale(tt.locale)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		} else {
+			lastLocale = tt.wantLocale
+		}
+
+		recorder.Lock()
+		recorder.queries = nil
+		recorder.Unlock()
+		if _, err := client.ListProducts(place); err != nil {
+			t.Errorf("#%d: listProducts: %v", i, err)
+			continue
+		}
+
+		// A rejected locale leaves the previous one in place.
+		recorder.Lock()
+		query := recorder.queries[0]
+		recorder.Unlock()
+		if _, ok := query["locale"]; ok != (lastLocale != "") {
+			t.Errorf("#%d: locale param present=%v want=%v", i, ok, lastLocale != "")
+		}
+		if got := query.Get("locale"); got != lastLocale {
+			t.Errorf("#%d: locale: got=%q want=%q", i, got, lastLocale)
+		}
+		if got := query.Get("latitude"); got == "" {
+			t.Errorf("#%d: expecting the latitude to still be sent", i)
+		}
+	}
+}
+
+func TestSetDefaultQueryParams(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: listProducts}}
+	client.SetHTTPRoundTripper(recorder)
+
+	place := &uber.Place{Latitude: 37.77491, Longitude: -122.41941}
+	tests := [...]struct {
+		params url.Values
+		opts   []uber.QueryOption
+		want   url.Values
+	}{
+		0: {
+			params: nil,
+			want:   url.Values{"latitude": {"37.77491"}, "longitude": {"-122.41941"}},
+		},
+		1: {
+			params: url.Values{"experimental": {"1"}},
+			want:   url.Values{"latitude": {"37.77491"}, "longitude": {"-122.41941"}, "experimental": {"1"}},
+		},
+		2: {
+			// Parameters set by the library take precedence.
+			params: url.Values{"latitude": {"0"}, "tags": {"a", "b"}},
+			want:   url.Values{"latitude": {"37.77491"}, "longitude": {"-122.41941"}, "tags": {"a", "b"}},
+		},
+		3: {
+			params: url.Values{},
+			want:   url.Values{"latitude": {"37.77491"}, "longitude": {"-122.41941"}},
+		},
+		4: {
+			opts: []uber.QueryOption{uber.WithQueryParam("experimental", "1")},
+			want: url.Values{"latitude": {"37.77491"}, "longitude": {"-122.41941"}, "experimental": {"1"}},
+		},
+		5: {
+			// Per-call options take precedence over the defaults
+			// but not over the parameters set by the library.
+			params: url.Values{"experimental": {"0"}, "tags": {"a"}},
+			opts: []uber.QueryOption{
+				uber.WithQueryParam("experimental", "1"),
+				uber.WithQueryParam("longitude", "0"),
+				uber.WithQueryParam("fields", "a"),
+				uber.WithQueryParam("fields", "b"),
+				nil,
+			},
+			want: url.Values{
+				"latitude": {"37.77491"}, "longitude": {"-122.41941"},
+				"experimental": {"1"}, "tags": {"a"}, "fields": {"a", "b"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetDefaultQueryParams(tt.params)
+		// Mutating the params after setting them mustn't affect the client.
+		if tt.params != nil {
+			tt.params.Set("mutated", "true")
+		}
+
+		recorder.Lock()
+		recorder.queries = nil
+		recorder.Unlock()
+		if _, err := client.ListProducts(place, tt.opts...); err != nil {
+			t.Errorf("#%d: listProducts: %v", i, err)
+			continue
+		}
+
+		recorder.Lock()
+		got := recorder.queries[0]
+		recorder.Unlock()
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d:\ngot:  %v\nwant: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestDeepLink(t *testing.T) {
+	hq := &uber.DeepLinkLocation{
+		Latitude:         37.775818,
+		Longitude:        -122.418028,
+		Nickname:         "UberHQ",
+		FormattedAddress: "1455 Market St, San Francisco, CA 94103",
+	}
+	coitTower := &uber.DeepLinkLocation{
+		Latitude:  37.802374,
+		Longitude: -122.405818,
+		Nickname:  "Coit Tower",
+	}
+
+	tests := [...]struct {
+		params  uber.DeepLinkParams
+		want    string
+		wantErr bool
+	}{
+		0: {
+			params: uber.DeepLinkParams{ClientID: "client1"},
+			want:   "https://m.uber.com/ul/?client_id=client1&action=setPickup&pickup=my_location",
+		},
+		1: {
+			params: uber.DeepLinkParams{
+				ClientID:  "client1",
+				ProductID: "a1111c8c-c720-46c3-8534-2fcdd730040d",
+				Pickup:    hq,
+				Dropoff:   coitTower,
+			},
+			want: "https://m.uber.com/ul/?client_id=client1&action=setPickup" +
+				"&pickup[latitude]=37.775818&pickup[longitude]=-122.418028&pickup[nickname]=UberHQ" +
+				"&pickup[formatted_address]=1455%20Market%20St%2C%20San%20Francisco%2C%20CA%2094103" +
+				"&dropoff[latitude]=37.802374&dropoff[longitude]=-122.405818&dropoff[nickname]=Coit%20Tower" +
+				"&product_id=a1111c8c-c720-46c3-8534-2fcdd730040d",
+		},
+		2: {
+			// Values are escaped so they can't inject parameters.
+			params: uber.DeepLinkParams{
+				ClientID: "client1",
+				Dropoff:  &uber.DeepLinkLocation{Latitude: 1, Longitude: 2, Nickname: "A&B=C"},
+			},
+			want: "https://m.uber.com/ul/?client_id=client1&action=setPickup&pickup=my_location" +
+				"&dropoff[latitude]=1&dropoff[longitude]=2&dropoff[nickname]=A%26B%3DC",
+		},
+		3: {
+			params:  uber.DeepLinkParams{ClientID: "  "},
+			wantErr: true,
+		},
+		4: {
+			params:  uber.DeepLinkParams{ClientID: "client1", Pickup: &uber.DeepLinkLocation{Nickname: "nowhere"}},
+			wantErr: true,
+		},
+		5: {
+			params:  uber.DeepLinkParams{ClientID: "client1", Dropoff: &uber.DeepLinkLocation{Latitude: 91, Longitude: 2}},
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		got, err := uber.DeepLink(tt.params)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, got, tt.want)
+		}
+		if _, err := url.Parse(got); err != nil {
+			t.Errorf("#%d: unparseable link: %v", i, err)
+		}
+	}
+}
+
+func TestSetUserAgent(t *testing.T) {
+	defaultUARE := regexp.MustCompile(`^uber-go-client/\d+\.\d+\.\d+$`)
+
+	tests := [...]struct {
+		userAgent string
+		want      string
+		wantRE    *regexp.Regexp
+	}{
+		0: {userAgent: "", wantRE: defaultUARE},
+		1: {userAgent: "my-app/2.1", want: "my-app/2.1"},
+		2: {userAgent: "   ", wantRE: defaultUARE},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: requestRideRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+		client.SetUserAgent(tt.userAgent)
+
+		// Clones must keep the User-Agent.
+		clone := client.Clone()
+		if _, err := clone.RequestRide(&uber.RideRequest{FareID: "fareID-1", StartPlace: uber.PlaceHome, EndPlace: uber.PlaceWork}); err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if len(recorder.headers) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.headers))
+			continue
+		}
+		got := recorder.headers[0].Get("User-Agent")
+		if tt.wantRE != nil {
+			if !tt.wantRE.MatchString(got) {
+				t.Errorf("#%d: got %q want a match of %q", i, got, tt.wantRE)
+			}
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("#%d: got %q want %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestSetRequestTimeout(t *testing.T) {
+	tests := [...]struct {
+		timeout     time.Duration
+		delay       time.Duration
+		wantTimeout bool
+	}{
+		// Without a timeout, the request must wait
+		// for slowRoundTripper's Gateway Timeout.
+		0: {timeout: 0, delay: 50 * time.Millisecond},
+		1: {timeout: time.Second, delay: 10 * time.Millisecond},
+		2: {timeout: 20 * time.Millisecond, delay: time.Minute, wantTimeout: true},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(slowRoundTripper(tt.delay))
+		client.SetRequestTimeout(tt.timeout)
+
+		start := time.Now()
+		_, err = client.ListProducts(&uber.Place{Latitude: 37.7752315, Longitude: -122.418075})
+		elapsed := time.Since(start)
+		if err == nil {
+			t.Errorf("#%d: expecting a non-nil error", i)
+			continue
+		}
+
+		timedOut := strings.Contains(err.Error(), context.DeadlineExceeded.Error())
+		if timedOut != tt.wantTimeout {
+			t.Errorf("#%d: timedOut=%v wantTimeout=%v err=%v", i, timedOut, tt.wantTimeout, err)
+		}
+		if tt.wantTimeout && elapsed >= tt.delay {
+			t.Errorf("#%d: the request wasn't cut short, it took %v", i, elapsed)
+		}
+	}
+}
+
+// trackingBody records whether it was read to the end and closed.
+type trackingBody struct {
+	io.Reader
+	eof    bool
+	closed bool
+}
+
+func (tb *trackingBody) Read(b []byte) (int, error) {
+	n, err := tb.Reader.Read(b)
+	if err == io.EOF {
+		tb.eof = true
+	}
+	return n, err
+}
+
+func (tb *trackingBody) Close() error {
+	tb.closed = true
+	return nil
+}
+
+// bodyTrackingRoundTripper responds with the given status code,
+// content type and body, and keeps the bodies it sent out.
+type bodyTrackingRoundTripper struct {
+	code        int
+	contentType string
+	body        string
+
+	bodies []*trackingBody
+}
+
+var _ http.RoundTripper = (*bodyTrackingRoundTripper)(nil)
+
+func (brt *bodyTrackingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	body := &trackingBody{Reader: strings.NewReader(brt.body)}
+	brt.bodies = append(brt.bodies, body)
+	resp := makeResp(http.StatusText(brt.code), brt.code)
+	resp.Header.Set("Content-Type", brt.contentType)
+	resp.Body = body
+	return resp, nil
+}
+
+func TestNoContentResponses(t *testing.T) {
+	cancelDelivery := func(c *uber.Client) error { return c.CancelDelivery(deliveryID1) }
+	requestReceipt := func(c *uber.Client) error {
+		_, err := c.RequestReceipt(requestID1)
+		return err
+	}
+
+	tests := [...]struct {
+		code    int
+		body    string
+		do      func(c *uber.Client) error
+		wantErr error
+	}{
+		// Cancel-style methods don't expect a body.
+		0: {code: http.StatusNoContent, do: cancelDelivery},
+		1: {code: http.StatusResetContent, do: cancelDelivery},
+		2: {code: http.StatusNoContent, body: "{}", do: cancelDelivery},
+		3: {code: http.StatusOK, body: "{}", do: cancelDelivery},
+
+		// Methods that expect a body must fail clearly.
+		4: {code: http.StatusNoContent, do: requestReceipt, wantErr: uber.ErrNoContent},
+		5: {code: http.StatusResetContent, do: requestReceipt, wantErr: uber.ErrNoContent},
+		6: {code: http.StatusNoContent, body: `{"request_id":"r1"}`, do: requestReceipt, wantErr: uber.ErrNoContent},
+		7: {code: http.StatusOK, body: "", do: requestReceipt, wantErr: uber.ErrNoContent},
+		8: {code: http.StatusOK, body: `{"request_id":"r1"}`, do: requestReceipt},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{
+			code:        tt.code,
+			contentType: "application/json",
+			body:        tt.body,
+		})
+
+		if err := tt.do(client); err != tt.wantErr {
+			t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+		}
+	}
+}
+
+// gzipRoundTripper gzip encodes the bodies of the responses from base,
+// failing requests that don't advertise that they accept gzip.
+type gzipRoundTripper struct {
+	base http.RoundTripper
+}
+
+func (grt *gzipRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if got := req.Header.Get("Accept-Encoding"); got != "gzip" {
+		return makeResp(fmt.Sprintf("got Accept-Encoding %q want gzip", got), http.StatusBadRequest), nil
+	}
+	resp, err := grt.base.RoundTrip(req)
+	if err != nil || resp.Body == nil {
+		return resp, err
+	}
+	plain, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	buf := new(bytes.Buffer)
+	gzw := gzip.NewWriter(buf)
+	gzw.Write(plain)
+	gzw.Close()
+	resp.Header.Set("Content-Encoding", "gzip")
+	resp.Body = ioutil.NopCloser(buf)
+	return resp, nil
+}
+
+func TestGzipResponses(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	client.SetHTTPRoundTripper(&gzipRoundTripper{base: &tRoundTripper{route: getMapRoute}})
+	mapInfo, err := client.RequestMap(requestID1)
+	if err != nil {
+		t.Fatalf("gzipped map: %v", err)
+	}
+	gotBlob, wantBlob := jsonSerialize(mapInfo), jsonSerialize(mapFromFile(requestID1))
+	if !bytes.Equal(gotBlob, wantBlob) {
+		t.Errorf("gzipped map:\ngot:  %s\nwant: %s", gotBlob, wantBlob)
+	}
+
+	// Error bodies are decoded too.
+	errRT := &bodyTrackingRoundTripper{
+		code:        http.StatusConflict,
+		contentType: "application/json",
+		body:        `{"errors":[{"status":409,"code":"conflict","title":"Conflict"}]}`,
+	}
+	client.SetHTTPRoundTripper(&gzipRoundTripper{base: errRT})
+	_, err = client.RequestMap(requestID1)
+	if _, ok := err.(*uber.Error); !ok {
+		t.Errorf("gzipped error: got %T(%v) want *uber.Error", err, err)
+	}
+}
+
+func TestResponseBodiesAreDrainedAndClosed(t *testing.T) {
+	noRedirectsClient := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	tests := [...]struct {
+		rt      *bodyTrackingRoundTripper
+		hc      *http.Client
+		wantErr bool
+	}{
+		0: {
+			rt: &bodyTrackingRoundTripper{code: http.StatusOK, contentType: "application/json", body: `{"first_name":"Uber"}`},
+		},
+		1: {
+			rt: &bodyTrackingRoundTripper{
+				code: http.StatusNotFound, contentType: "application/json",
+				body: `{"errors":[{"status":404,"code":"not_found","title":"Not found."}]}`,
+			},
+			wantErr: true,
+		},
+		2: {
+			rt:      &bodyTrackingRoundTripper{code: http.StatusInternalServerError, contentType: "text/plain", body: "internal server error"},
+			wantErr: true,
+		},
+		3: {
+			// The login page of an API gateway.
+			rt:      &bodyTrackingRoundTripper{code: http.StatusOK, contentType: "text/html", body: "<html><body>Please log in</body></html>"},
+			wantErr: true,
+		},
+		4: {
+			rt:      &bodyTrackingRoundTripper{code: http.StatusFound, contentType: "text/plain", body: "Found"},
+			hc:      noRedirectsClient,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClientWithHTTPClient(testToken1, tt.hc)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
+		}
+		client.SetHTTPRoundTripper(tt.rt)
+
+		_, err = client.RetrieveMyProfile()
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("#%d: gotErr=%v wantErr=%v err=%v", i, gotErr, tt.wantErr, err)
+		}
+
+		if len(tt.rt.bodies) != 1 {
+			t.Errorf("#%d: got %d responses want 1", i, len(tt.rt.bodies))
+			continue
+		}
+		body := tt.rt.bodies[0]
+		if !body.eof {
+			t.Errorf("#%d: body was not read to the end", i)
+		}
+		if !body.closed {
+			t.Errorf("#%d: body was not closed", i)
+		}
+	}
+}
+
+func TestClone(t *testing.T) {
+	parent, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: retrieveProfileRoute}
+	parent.SetHTTPRoundTripper(backend)
+
+	clone := parent.Clone()
+	clone.SetBearerToken("unknown-token")
+
+	// The parent must still be using its own token.
+	if _, err := parent.RetrieveMyProfile(); err != nil {
+		t.Errorf("parent: unexpected err: %v", err)
+	}
+	// While the clone uses the new token but the parent's transport.
+	if _, err := clone.RetrieveMyProfile(); err == nil {
+		t.Errorf("clone: expected an error with an unauthorized token")
+	}
+
+	// Rotating tokens concurrently on clones must be safe.
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			perUser := parent.Clone()
+			token := testToken1
+			if i%2 == 1 {
+				token = "unknown-token"
+			}
+			perUser.SetBearerToken(token)
+			_, err := perUser.RetrieveMyProfile()
+			if wantErr := token != testToken1; wantErr != (err != nil) {
+				t.Errorf("#%d: wantErr=%v err=%v", i, wantErr, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestWithToken(t *testing.T) {
+	parent, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: retrieveProfileRoute}
+	parent.SetHTTPRoundTripper(backend)
+
+	child := parent.WithToken("unknown-token")
+	if _, err := child.RetrieveMyProfile(); err == nil {
+		t.Errorf("child: expected an error with an unauthorized token")
+	}
+	if _, err := parent.RetrieveMyProfile(); err != nil {
+		t.Errorf("parent: unexpected err: %v", err)
+	}
+
+	// Changing the parent's token must not affect the child.
+	parent.SetBearerToken("another-unknown-token")
+	child.SetBearerToken(testToken1)
+	if _, err := child.RetrieveMyProfile(); err != nil {
+		t.Errorf("child: unexpected err: %v", err)
+	}
+	if _, err := parent.RetrieveMyProfile(); err == nil {
+		t.Errorf("parent: expected an error with an unauthorized token")
+	}
+
+	// The sandbox mode is inherited but independent afterwards.
+	parent.SetSandboxMode(true)
+	sandboxedChild := parent.WithToken(testToken1)
+	if !sandboxedChild.Sandboxed() {
+		t.Errorf("child: expected the sandbox mode to be inherited")
+	}
+	sandboxedChild.SetSandboxMode(false)
+	if !parent.Sandboxed() {
+		t.Errorf("parent: sandbox mode changed by the child")
+	}
+	parent.SetSandboxMode(false)
+	if child.Sandboxed() || sandboxedChild.Sandboxed() {
+		t.Errorf("children: sandbox mode changed by the parent")
+	}
+}
+
+func TestCancelDelivery(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: cancelDeliveryRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		reqID   string
+		wantErr bool
+	}{
+		0: {"", true},
+		1: {"     ", true},
+		2: {reqID: deliveryID1},
+	}
+
+	for i, tt := range tests {
+		err := client.CancelDelivery(tt.reqID)
+		gotErr := err != nil
+		if gotErr != tt.wantErr {
+			t.Errorf("#%d: gotErr=(%v) wantErr=(%v) err=(%v)", i, gotErr, tt.wantErr, err)
+		}
+	}
+}
+
+func TestIdempotentCancelDelivery(t *testing.T) {
+	const uberErrBody = `{"errors":[{"status":%d,"code":"%s","title":"%s"}]}`
+
+	tests := [...]struct {
+		code       int
+		body       string
+		idempotent bool
+		wantErr    bool
+	}{
+		0: {code: http.StatusNoContent},
+		1: {code: http.StatusNoContent, idempotent: true},
+
+		2: {code: http.StatusNotFound, body: fmt.Sprintf(uberErrBody, 404, "not_found", "Delivery not found."), wantErr: true},
+		3: {code: http.StatusNotFound, body: fmt.Sprintf(uberErrBody, 404, "not_found", "Delivery not found."), idempotent: true},
+		4: {code: http.StatusConflict, body: fmt.Sprintf(uberErrBody, 409, "delivery_already_canceled", "Delivery already canceled."), wantErr: true},
+		5: {code: http.StatusConflict, body: fmt.Sprintf(uberErrBody, 409, "delivery_already_canceled", "Delivery already canceled."), idempotent: true},
+		6: {code: http.StatusNotFound, body: "404 page not found", idempotent: true},
+
+		// Any other failure is still an error.
+		7: {code: http.StatusInternalServerError, body: "internal error", idempotent: true, wantErr: true},
+		8: {code: http.StatusForbidden, body: fmt.Sprintf(uberErrBody, 403, "forbidden", "Forbidden."), idempotent: true, wantErr: true},
+
+		// Other conflicts are real.
+		9:  {code: http.StatusConflict, body: fmt.Sprintf(uberErrBody, 409, "conflict", "Delivery already picked up."), idempotent: true, wantErr: true},
+		10: {code: http.StatusConflict, body: "409 conflict", idempotent: true, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{code: tt.code, contentType: "application/json", body: tt.body})
+		client.SetIdempotentCancel(tt.idempotent)
+
+		err = client.CancelDelivery(deliveryID1)
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("#%d: gotErr=%v wantErr=%v err=%v", i, gotErr, tt.wantErr, err)
+		}
+	}
+}
+
+func TestDeliveryCourierLocation(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: deliveryTrackingRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		deliveryID string
+		want       *uber.CourierLocation
+		wantErr    error
+	}{
+		0: {deliveryID: "", wantErr: errAny},
+		1: {deliveryID: "   ", wantErr: errAny},
+		2: {
+			deliveryID: deliveryID1,
+			want: &uber.CourierLocation{
+				Latitude:  40.7619629893,
+				Longitude: -74.0014480227,
+				Bearing:   33,
+			},
+		},
+		3: {
+			// The delivery was already completed.
+			deliveryID: deliveryID2,
+			wantErr:    uber.ErrDeliveryNotTrackable,
+		},
+		4: {deliveryID: "unknown-delivery", wantErr: errAny},
+	}
+
+	for i, tt := range tests {
+		loc, err := client.DeliveryCourierLocation(tt.deliveryID)
+		if tt.wantErr != nil {
+			if err == nil || (tt.wantErr != errAny && err != tt.wantErr) {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(loc, tt.want) {
+			t.Errorf("#%d:\ngot:  %#v\nwant: %#v", i, loc, tt.want)
+		}
+	}
+}
+
+func TestRequestDelivery(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: deliveryRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		req     *uber.DeliveryRequest
+		want    *uber.Delivery
+		wantErr bool
+	}{
+		0: {
+			req:     &uber.DeliveryRequest{},
+			wantErr: true,
+		},
+		1: {
+			req:     nil,
+			wantErr: true,
+		},
+		2: {
+			req:     &uber.DeliveryRequest{},
+			wantErr: true,
+		},
+		3: {
+			req: &uber.DeliveryRequest{
+				Pickup: &uber.Endpoint{
+					Contact: &uber.Contact{
+						CompanyName:          "orijtech",
+						Email:                "deliveries@orijtech.com",
+						SendSMSNotifications: true,
+					},
+					Location: &uber.Location{
+						PrimaryAddress: "Empire State Building",
+						State:          "NY",
+						Country:        "US",
+					},
+					SpecialInstructions: "Please ask guest services for \"I Man\"",
+				},
+				Dropoff: &uber.Endpoint{
+					Contact: &uber.Contact{
+						FirstName:   "delivery",
+						LastName:    "bot",
+						CompanyName: "Uber",
+
+						SendEmailNotifications: true,
+					},
+					Location: &uber.Location{
+						PrimaryAddress:   "530 W 113th Street",
+						SecondaryAddress: "Floor 2",
+						Country:          "US",
+						PostalCode:       "10025",
+						State:            "NY",
+					},
+				},
+				Items: []*uber.Item{
+					{
+						Title:    "phone chargers",
+						Quantity: 10,
+					},
+					{
+						Title:    "Blue prints",
+						Fragile:  true,
+						Quantity: 1,
+					},
+				},
+			},
+			want: deliveryResponseFromFile(deliveryResponsePath(deliveryResponseID1)),
+		},
+	}
+
+	for i, tt := range tests {
+		dres, err := client.RequestDelivery(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		if dres == nil {
+			t.Errorf("#%d: expecting non-nil delivery response", i)
+			continue
+		}
+		gotBytes := jsonSerialize(dres)
+		wantBytes := jsonSerialize(tt.want)
+		if !bytes.Equal(gotBytes, wantBytes) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBytes, wantBytes)
+		}
+	}
+}
+
+func TestDeliveryRequestValidateItems(t *testing.T) {
+	endpoint := func() *uber.Endpoint {
+		return &uber.Endpoint{
+			Contact:  &uber.Contact{FirstName: "delivery", LastName: "bot"},
+			Location: &uber.Location{PrimaryAddress: "530 W 113th Street", Country: "US"},
+		}
+	}
+
+	tests := [...]struct {
+		items []*uber.Item
+		// wantErrIndex is the index of the item that must be
+		// named by the error, -1 if no item is to blame.
+		wantErrIndex int
+		wantErr      bool
+	}{
+		0: {items: nil, wantErrIndex: -1, wantErr: true},
+		1: {items: []*uber.Item{}, wantErrIndex: -1, wantErr: true},
+		2: {items: []*uber.Item{{Title: "phone chargers", Quantity: 0}}, wantErrIndex: 0, wantErr: true},
+		3: {items: []*uber.Item{{Title: "phone chargers", Quantity: -2}}, wantErrIndex: 0, wantErr: true},
+		4: {
+			// A valid item doesn't make up for an invalid one.
+			items: []*uber.Item{
+				{Title: "phone chargers", Quantity: 10},
+				{Title: "Blue prints", Quantity: 0},
+			},
+			wantErrIndex: 1, wantErr: true,
+		},
+		5: {items: []*uber.Item{{Title: "phone chargers", Quantity: 3}, nil}, wantErrIndex: 1, wantErr: true},
+		6: {items: []*uber.Item{{Quantity: 1}}, wantErrIndex: 0, wantErr: true},
+		7: {items: []*uber.Item{{Title: "phone chargers", Quantity: 1}}},
+		8: {
+			items: []*uber.Item{
+				{Title: "phone chargers", Quantity: 10},
+				{Title: "Blue prints", Fragile: true, Quantity: 1},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		dreq := &uber.DeliveryRequest{Pickup: endpoint(), Dropoff: endpoint(), Items: tt.items}
+		err := dreq.Validate()
+		if !tt.wantErr {
+			if err != nil {
+				t.Errorf("#%d: unexpected err: %v", i, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("#%d: expecting a non-nil error", i)
+			continue
+		}
+		if tt.wantErrIndex >= 0 {
+			if want := fmt.Sprintf("item #%d", tt.wantErrIndex); !strings.Contains(err.Error(), want) {
+				t.Errorf("#%d: error %q doesn't name %q", i, err, want)
+			}
+		}
+	}
+}
+
+func TestRequestDeliveryPaymentMethodID(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	endpoint := func(address string) *uber.Endpoint {
+		return &uber.Endpoint{
+			Contact:  &uber.Contact{CompanyName: "orijtech"},
+			Location: &uber.Location{PrimaryAddress: address, State: "NY", Country: "US"},
+		}
+	}
+
+	tests := [...]struct {
+		paymentMethodID string
+	}{
+		0: {paymentMethodID: ""},
+		1: {paymentMethodID: "5f384f7d-8323-4207-a297-51c571234a8c"},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: deliveryRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		_, err := client.RequestDelivery(&uber.DeliveryRequest{
+			Pickup:          endpoint("Empire State Building"),
+			Dropoff:         endpoint("530 W 113th Street"),
+			Items:           []*uber.Item{{Title: "phone chargers", Quantity: 10}},
+			PaymentMethodID: tt.paymentMethodID,
+		})
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if len(recorder.bodies) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.bodies))
+			continue
+		}
+
+		body := make(map[string]interface{})
+		if err := json.Unmarshal(recorder.bodies[0], &body); err != nil {
+			t.Errorf("#%d: unmarshaling body: %v", i, err)
+			continue
+		}
+		got, present := body["payment_method_id"]
+		if tt.paymentMethodID == "" {
+			if present {
+				t.Errorf("#%d: unexpected payment_method_id: %v", i, got)
+			}
+			continue
+		}
+		if got != tt.paymentMethodID {
+			t.Errorf("#%d: payment_method_id: got %v want %q", i, got, tt.paymentMethodID)
+		}
+	}
+}
+
+func TestFilterPayments(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDriverPaymentsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	dres, err := client.ListDriverPayments(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+	if err != nil {
+		t.Fatalf("listDriverPayments: %v", err)
+	}
+	var payments []*uber.Payment
+	for page := range dres.Pages {
+		if page.Err != nil {
+			t.Fatalf("page #%d: %v", page.PageNumber, page.Err)
+		}
+		payments = append(payments, page.Payments...)
+	}
+
+	tests := [...]struct {
+		category uber.PaymentCategory
+		wantIDs  []string
+	}{
+		0: {
+			category: uber.CategoryTip,
+			wantIDs:  []string{"8e367a25-7fe2-4430-953e-54ad2dd94f3e"},
+		},
+		1: {
+			category: uber.CategoryToll,
+			wantIDs:  []string{"135832d7-e3ff-400d-a8d6-055509704cc1"},
+		},
+		2: {
+			category: uber.CategoryPromotion,
+			wantIDs:  []string{"62bcf820-cad0-451d-b991-86b75dc7f1e1"},
+		},
+		3: {category: uber.CategoryDevicePayment},
+	}
+
+	for i, tt := range tests {
+		var gotIDs []string
+		for _, payment := range uber.FilterPayments(payments, tt.category) {
+			gotIDs = append(gotIDs, payment.ID)
+			if got, want := payment.IsTip(), tt.category == uber.CategoryTip; got != want {
+				t.Errorf("#%d: %q isTip: got %v want %v", i, payment.ID, got, want)
+			}
+		}
+		if !reflect.DeepEqual(gotIDs, tt.wantIDs) {
+			t.Errorf("#%d: got %q want %q", i, gotIDs, tt.wantIDs)
+		}
+	}
+
+	if fares := uber.FilterPayments(payments, uber.CategoryFare); len(fares) != len(payments)-3 {
+		t.Errorf("got %d fares want %d", len(fares), len(payments)-3)
+	}
+}
+
+func TestDriverPaymentSummary(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDriverPaymentsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		query   *uber.DriverInfoQuery
+		wantErr error
+		want    *uber.PaymentSummary
+	}{
+		0: {
+			// The last page has payments in CAD and CTH.
+			query:   &uber.DriverInfoQuery{},
+			wantErr: uber.ErrMixedCurrencies,
+		},
+		1: {
+			query: &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
+			want: &uber.PaymentSummary{
+				NetEarnings:  uber.Money{Amount: 29.36, CurrencyCode: "USD"},
+				Tips:         uber.Money{CurrencyCode: "USD"},
+				Tolls:        uber.Money{Amount: 13.12, CurrencyCode: "USD"},
+				Promotions:   uber.Money{CurrencyCode: "USD"},
+				Other:        uber.Money{CurrencyCode: "USD"},
+				Total:        uber.Money{Amount: 42.48, CurrencyCode: "USD"},
+				PaymentCount: 6,
+			},
+		},
+		2: {
+			query: &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
+			want: &uber.PaymentSummary{
+				NetEarnings:  uber.Money{Amount: 16.24, CurrencyCode: "USD"},
+				Tips:         uber.Money{CurrencyCode: "USD"},
+				Tolls:        uber.Money{CurrencyCode: "USD"},
+				Promotions:   uber.Money{CurrencyCode: "USD"},
+				Other:        uber.Money{CurrencyCode: "USD"},
+				Total:        uber.Money{Amount: 16.24, CurrencyCode: "USD"},
+				PaymentCount: 2,
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		tt.query.Throttle = uber.NoThrottle
+		summary, err := client.DriverPaymentSummary(tt.query)
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		// Compare the formatted amounts to ignore floating point error.
+		got := fmt.Sprintf("%v %v %v %v %v %v %d", summary.NetEarnings, summary.Tips, summary.Tolls,
+			summary.Promotions, summary.Other, summary.Total, summary.PaymentCount)
+		want := fmt.Sprintf("%v %v %v %v %v %v %d", tt.want.NetEarnings, tt.want.Tips, tt.want.Tolls,
+			tt.want.Promotions, tt.want.Other, tt.want.Total, tt.want.PaymentCount)
+		if got != want {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, got, want)
+		}
+	}
+}
+
+func TestDriverEarningsByDay(t *testing.T) {
+	losAngeles, err := time.LoadLocation("America/Los_Angeles")
+	if err != nil {
+		t.Skipf("loading timezone: %v", err)
+	}
+
+	tests := [...]struct {
+		query    *uber.DriverInfoQuery
+		timezone *time.Location
+		wantErr  error
+		want     map[string]uber.Money
+	}{
+		0: {
+			// The last page has payments in CAD and CTH.
+			query:   &uber.DriverInfoQuery{},
+			wantErr: uber.ErrMixedCurrencies,
+		},
+		1: {
+			query: &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
+			want: map[string]uber.Money{
+				"2017-08-04": {Amount: 0, CurrencyCode: "USD"},
+				"2017-08-16": {Amount: 42.48, CurrencyCode: "USD"},
+			},
+		},
+		2: {
+			// Just after midnight UTC is still the previous day in Los Angeles.
+			query:    &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
+			timezone: losAngeles,
+			want: map[string]uber.Money{
+				"2017-08-04": {Amount: 0, CurrencyCode: "USD"},
+				"2017-08-15": {Amount: 42.48, CurrencyCode: "USD"},
+			},
+		},
+		3: {
+			query: &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
+			want: map[string]uber.Money{
+				"2017-08-16": {Amount: 16.24, CurrencyCode: "USD"},
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		backend := &tRoundTripper{route: listDriverPaymentsRoute}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, backend))
+		client.SetTimezone(tt.timezone)
+
+		tt.query.Throttle = uber.NoThrottle
+		got, err := client.DriverEarningsByDay(tt.query)
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+		}
+	}
+}
+
+func TestListDriverPayments(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDriverPaymentsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		req           *uber.DriverInfoQuery
+		wantPageCount int
+		wantItemCount int
+		wantErr       bool
+	}{
+		0: {
+			req:           nil, // No page limit, pagination all of them
+			wantPageCount: 4,
+			wantItemCount: 10,
+		},
+		1: {
+			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
+			wantPageCount: 1,
+			wantItemCount: 2,
+		},
+		2: {
+			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
+			wantPageCount: 3,
+			wantItemCount: 6,
+		},
+	}
+
+	for i, tt := range tests {
+		if tt.req != nil {
+			tt.req.Throttle = uber.NoThrottle
+		}
+		dres, err := client.ListDriverPayments(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		if dres == nil {
+			t.Errorf("#%d: expecting non-nil delivery response", i)
+			continue
+		}
+
+		itemCount := 0
+		pageCount := 0
+		for page := range dres.Pages {
+			if page.Err != nil {
+				t.Errorf("#%d: err: %v", i, page.Err)
+				continue
+			}
+			pageCount += 1
+			itemCount += len(page.Payments)
+		}
+		if g, w := itemCount, tt.wantItemCount; g != w {
+			t.Errorf("#%d: itemCount:: got=%d want=%d", i, g, w)
+		}
+		if g, w := pageCount, tt.wantPageCount; g != w {
+			t.Errorf("#%d: pageCount:: got=%d want=%d", i, g, w)
+		}
+	}
+}
+
+func TestListDriverInfoSortByTime(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDriverPaymentsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	dres, err := client.ListDriverPayments(&uber.DriverInfoQuery{
+		SortByTime: true,
+		Throttle:   uber.NoThrottle,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	var allPayments []*uber.Payment
+	for page := range dres.Pages {
+		if page.Err != nil {
+			t.Errorf("page #%d: err: %v", page.PageNumber, page.Err)
+			continue
+		}
+		for i := 1; i < len(page.Payments); i++ {
+			if prev, cur := page.Payments[i-1], page.Payments[i]; prev.EventTime > cur.EventTime {
+				t.Errorf("page #%d: payment #%d (%v) is after payment #%d (%v)",
+					page.PageNumber, i-1, prev.EventTime, i, cur.EventTime)
+			}
+		}
+		allPayments = append(allPayments, page.Payments...)
+	}
+
+	if len(allPayments) == 0 {
+		t.Fatalf("expecting at least one payment")
+	}
+
+	// The fixtures are deliberately not in order across pages.
+	uber.SortPaymentsByTime(allPayments)
+	for i := 1; i < len(allPayments); i++ {
+		if prev, cur := allPayments[i-1], allPayments[i]; prev.EventTime > cur.EventTime {
+			t.Errorf("aggregated: payment #%d (%v) is after payment #%d (%v)",
+				i-1, prev.EventTime, i, cur.EventTime)
+		}
+	}
+
+	trips := []*uber.Trip{
+		{TripID: "c", StartTimeUnix: 300},
+		{TripID: "a", Pickup: &uber.Endpoint{TimestampUnix: 100}},
+		{TripID: "b1", Dropoff: &uber.Endpoint{TimestampUnix: 200}},
+		{TripID: "b2", StartTimeUnix: 200},
+	}
+	uber.SortTripsByTime(trips)
+	var gotIDs []string
+	for _, trip := range trips {
+		gotIDs = append(gotIDs, trip.TripID)
+	}
+	if got, want := strings.Join(gotIDs, ","), "a,b1,b2,c"; got != want {
+		t.Errorf("sorted trips: got=%q want=%q", got, want)
+	}
+}
+
+func TestDriverActivity(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: driverActivityRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	activity, err := client.DriverActivity(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// From the acceptance of the first trip to the dropoff of the last.
+	wantOnlineTime := time.Duration(1502847300-1502843899) * time.Second
+	wantTripTime := (600 + 900 + 300) * time.Second
+
+	if g, w := activity.Trips, 3; g != w {
+		t.Errorf("trips: got=%d want=%d", g, w)
+	}
+	if g, w := activity.FirstActiveTime, time.Unix(1502843899, 0); !g.Equal(w) {
+		t.Errorf("firstActiveTime: got=%v want=%v", g, w)
+	}
+	if g, w := activity.LastActiveTime, time.Unix(1502847300, 0); !g.Equal(w) {
+		t.Errorf("lastActiveTime: got=%v want=%v", g, w)
+	}
+	if g, w := activity.OnlineTime, wantOnlineTime; g != w {
+		t.Errorf("onlineTime: got=%v want=%v", g, w)
+	}
+	if g, w := activity.TripTime, wantTripTime; g != w {
+		t.Errorf("tripTime: got=%v want=%v", g, w)
+	}
+	if g, w := activity.Utilization, float64(wantTripTime)/float64(wantOnlineTime); g != w {
+		t.Errorf("utilization: got=%v want=%v", g, w)
+	}
+}
+
+// largePaymentsRoundTripper serves a single large page of payments.
+type largePaymentsRoundTripper []byte
+
+var _ http.RoundTripper = (largePaymentsRoundTripper)(nil)
+
+func (lrt largePaymentsRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := makeResp("200 OK", http.StatusOK)
+	if offset := req.URL.Query().Get("offset"); offset != "" && offset != "0" {
+		resp.Body = ioutil.NopCloser(strings.NewReader(`{"payments":[]}`))
+	} else {
+		resp.Body = ioutil.NopCloser(bytes.NewReader(lrt))
+	}
+	return resp, nil
+}
+
+func makeLargePaymentsPage(n int) []byte {
+	payments := make([]*uber.Payment, n)
+	for i := range payments {
+		payments[i] = &uber.Payment{
+			ID:           fmt.Sprintf("payment-%d", i),
+			Category:     "fare",
+			EventTime:    otils.NullableFloat64(1502842757 + i),
+			TripID:       otils.NullableString(fmt.Sprintf("trip-%d", i)),
+			Amount:       13.12,
+			CurrencyCode: "USD",
+		}
+	}
+	blob, _ := json.Marshal(map[string]interface{}{
+		"count":    n,
+		"limit":    n,
+		"payments": payments,
+	})
+	return blob
+}
+
+func BenchmarkListDriverPayments(b *testing.B) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		b.Fatalf("initializing client; %v", err)
+	}
+	page := makeLargePaymentsPage(10000)
+	client.SetHTTPRoundTripper(largePaymentsRoundTripper(page))
+
+	b.SetBytes(int64(len(page)))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		dres, err := client.ListDriverPayments(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+		if err != nil {
+			b.Fatalf("unexpected err: %v", err)
+		}
+		for page := range dres.Pages {
+			if page.Err != nil {
+				b.Fatalf("paging err: %v", page.Err)
+			}
+		}
+	}
+}
+
+// connCountingServer serves the product fixture, or a 404 error
+// for the product "missing", and counts the connections opened to it.
+// If barrier is greater than 1, requests are held until that
+// many of them are being served concurrently.
+type connCountingServer struct {
+	*httptest.Server
+
+	newConns int32
+
+	mu       sync.Mutex
+	barrier  int
+	arrived  int
+	released chan bool
+}
+
+func newConnCountingServer() *connCountingServer {
+	cs := &connCountingServer{released: make(chan bool)}
+	product, _ := ioutil.ReadFile("./fakeserver/fixtures/product.json")
+	cs.Server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		cs.mu.Lock()
+		cs.arrived++
+		if cs.arrived == cs.barrier {
+			close(cs.released)
+		}
+		barrier, released := cs.barrier, cs.released
+		cs.mu.Unlock()
+		if barrier > 1 {
+			select {
+			case <-released:
+			case <-time.After(5 * time.Second):
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasSuffix(req.URL.Path, "/missing") {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"errors":[{"status":404,"code":"not_found","title":"No such product."}]}`))
+			return
+		}
+		w.Write(product)
+	}))
+	cs.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&cs.newConns, 1)
+		}
+	}
+	cs.Start()
+	return cs
+}
+
+// setBarrier makes the next n requests wait for each other.
+func (cs *connCountingServer) setBarrier(n int) {
+	cs.mu.Lock()
+	cs.barrier, cs.arrived, cs.released = n, 0, make(chan bool)
+	cs.mu.Unlock()
+}
+
+func (cs *connCountingServer) connCount() int {
+	return int(atomic.LoadInt32(&cs.newConns))
+}
+
+func TestConnectionReuse(t *testing.T) {
+	cs := newConnCountingServer()
+	defer cs.Close()
+
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetBaseURL(cs.URL)
+	// Not to share the connections of http.DefaultTransport with other tests.
+	client.SetMaxIdleConnsPerHost(2)
+
+	// Sequential requests, even failed ones, must all reuse one connection.
+	for i := 0; i < 100; i++ {
+		productID := "a1111c8c-c720-46c3-8534-2fcdd730040d"
+		if i%3 == 0 {
+			productID = "missing"
+		}
+		_, err := client.ProductByID(productID)
+		if (err != nil) != (productID == "missing") {
+			t.Fatalf("#%d: productByID(%q): unexpected err: %v", i, productID, err)
+		}
+	}
+	if got := cs.connCount(); got != 1 {
+		t.Errorf("sequential requests: got %d connections want 1", got)
+	}
+
+	concurrently := func(n int) {
+		cs.setBarrier(n)
+		var wg sync.WaitGroup
+		for i := 0; i < n; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				client.ProductByID("a1111c8c-c720-46c3-8534-2fcdd730040d")
+			}()
+		}
+		wg.Wait()
+	}
+
+	// Concurrent requests open connections of which
+	// only MaxIdleConnsPerHost are kept for reuse.
+	tests := [...]struct {
+		maxIdle      int
+		wantNewConns int
+	}{
+		0: {maxIdle: 2, wantNewConns: 6},
+		1: {maxIdle: 8, wantNewConns: 0},
+	}
+	for i, tt := range tests {
+		client.SetMaxIdleConnsPerHost(tt.maxIdle)
+		concurrently(8)
+		before := cs.connCount()
+		concurrently(8)
+		if got := cs.connCount() - before; got != tt.wantNewConns {
+			t.Errorf("#%d: maxIdle=%d: got %d new connections want %d", i, tt.maxIdle, got, tt.wantNewConns)
+		}
+	}
+}
+
+func TestClientClose(t *testing.T) {
+	cs := newConnCountingServer()
+	defer cs.Close()
+
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetBaseURL(cs.URL)
+	client.SetMaxIdleConnsPerHost(2)
+
+	productID := "a1111c8c-c720-46c3-8534-2fcdd730040d"
+	for i := 0; i < 3; i++ {
+		if _, err := client.ProductByID(productID); err != nil {
+			t.Fatalf("#%d: productByID: %v", i, err)
+		}
+		// Close must be safe to call repeatedly.
+		for j := 0; j < 2; j++ {
+			if err := client.Close(); err != nil {
+				t.Errorf("#%d: close #%d: unexpected err: %v", i, j, err)
+			}
+		}
+	}
+
+	// The idle connection was closed every time so every
+	// request after a Close needed a new connection.
+	if got := cs.connCount(); got != 3 {
+		t.Errorf("got %d connections want 3", got)
+	}
+
+	// A client without any transport of its own can be closed too.
+	plain, _ := uber.NewClient(testToken1)
+	if err := plain.Close(); err != nil {
+		t.Errorf("closing a plain client: %v", err)
+	}
+}
+
+// BenchmarkProductByIDConnectionReuse makes 100 sequential ProductByID
+// calls per op and reports the connections opened across all ops,
+// which is 1 when connections are reused.
+func BenchmarkProductByIDConnectionReuse(b *testing.B) {
+	cs := newConnCountingServer()
+	defer cs.Close()
+
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		b.Fatalf("initializing client; %v", err)
+	}
+	client.SetBaseURL(cs.URL)
+	client.SetMaxIdleConnsPerHost(2)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		for j := 0; j < 100; j++ {
+			if _, err := client.ProductByID("a1111c8c-c720-46c3-8534-2fcdd730040d"); err != nil {
+				b.Fatalf("productByID: %v", err)
+			}
+		}
+	}
+	b.ReportMetric(float64(cs.connCount()), "conns")
+}
+
+func TestListDriverTrips(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDriverTripsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		req           *uber.DriverInfoQuery
+		wantPageCount int
+		wantItemCount int
+		wantErr       bool
+	}{
+		0: {
+			req:           nil, // No page limit, pagination all of them
+			wantPageCount: 4,
+			wantItemCount: 10,
+		},
+		1: {
+			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
+			wantPageCount: 1,
+			wantItemCount: 2,
+		},
+		2: {
+			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
+			wantPageCount: 3,
+			wantItemCount: 6,
+		},
+	}
+
+	for i, tt := range tests {
+		if tt.req != nil {
+			tt.req.Throttle = uber.NoThrottle
+		}
+		dres, err := client.ListDriverTrips(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		if dres == nil {
+			t.Errorf("#%d: expecting non-nil delivery response", i)
+			continue
+		}
+
+		itemCount := 0
+		pageCount := 0
+		for page := range dres.Pages {
+			if page.Err != nil {
+				t.Errorf("#%d: err: %v", i, page.Err)
+				continue
+			}
+			pageCount += 1
+			itemCount += len(page.Trips)
+		}
+		if g, w := itemCount, tt.wantItemCount; g != w {
+			t.Errorf("#%d: itemCount:: got=%d want=%d", i, g, w)
+		}
+		if g, w := pageCount, tt.wantPageCount; g != w {
+			t.Errorf("#%d: pageCount:: got=%d want=%d", i, g, w)
+		}
+	}
+}
+
+func TestDriverTripReceipts(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: driverTripReceiptsRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	pagesChan, cancelPaging, err := client.DriverTripReceipts(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	defer cancelPaging()
+
+	var pages []*uber.DriverReceiptPage
+	for page := range pagesChan {
+		pages = append(pages, page)
+	}
+
+	if len(pages) != 2 {
+		t.Fatalf("got %d pages want 2", len(pages))
+	}
+
+	type wantPage struct {
+		receiptIDs    []string
+		failedTripIDs []string
+	}
+	wantPages := []wantPage{
+		0: {receiptIDs: []string{"driver-trip-1", "driver-trip-2"}},
+		// The receipt of driver-trip-3 can't be retrieved, nor that of
+		// the third trip which has no ID, but that mustn't stop the rest
+		// of the paging.
+		1: {receiptIDs: []string{"driver-trip-4"}, failedTripIDs: []string{"#2", "driver-trip-3"}},
+	}
+
+	for i, page := range pages {
+		if page.Err != nil {
+			t.Errorf("#%d: page err: %v", i, page.Err)
+			continue
+		}
+		if page.PageNumber != i {
+			t.Errorf("#%d: pageNumber: got %d want %d", i, page.PageNumber, i)
+		}
+
+		var receiptIDs []string
+		for _, receipt := range page.Receipts {
+			receiptIDs = append(receiptIDs, receipt.RequestID)
+		}
+		var failedTripIDs []string
+		for tripID, err := range page.ReceiptErrs {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error for %q", i, tripID)
+			}
+			failedTripIDs = append(failedTripIDs, tripID)
+		}
+		sort.Strings(failedTripIDs)
+		got := wantPage{receiptIDs: receiptIDs, failedTripIDs: failedTripIDs}
+		if want := wantPages[i]; !reflect.DeepEqual(got, want) {
+			t.Errorf("#%d:\ngot:  %+v\nwant: %+v", i, got, want)
+		}
+	}
+
+	if got, want := pages[1].Receipts[0].TotalMoney(), (uber.Money{Amount: 22.80, CurrencyCode: "USD"}); got != want {
+		t.Errorf("totalMoney: got %v want %v", got, want)
+	}
+}
+
+func TestListDriverTripsByStatus(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	tests := [...]struct {
+		status  uber.Status
+		wantErr bool
+	}{
+		0: {status: ""},
+		1: {status: uber.StatusCompleted},
+		2: {status: uber.StatusRiderCanceled},
+		3: {status: uber.StatusDriverCanceled},
+		4: {status: uber.StatusInProgress, wantErr: true},
+		5: {status: "bogus", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: listDriverTripsRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		dres, err := client.ListDriverTrips(&uber.DriverInfoQuery{
+			Status:        tt.status,
+			MaxPageNumber: 1,
+			Throttle:      uber.NoThrottle,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			if len(recorder.requests) != 0 {
+				t.Errorf("#%d: an invalid query must not be sent", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		for page := range dres.Pages {
+			if page.Err != nil {
+				t.Errorf("#%d: page err: %v", i, page.Err)
+			}
+		}
+
+		recorder.Lock()
+		queries := recorder.queries
+		recorder.Unlock()
+		if len(queries) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(queries))
+			continue
+		}
+		_, sent := queries[0]["status"]
+		if got := queries[0].Get("status"); got != string(tt.status) || (tt.status == "" && sent) {
+			t.Errorf("#%d: status param: got %q (sent=%v) want %q", i, got, sent, tt.status)
+		}
+	}
+}
+
+func TestListTripsByProduct(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	tests := [...]struct {
+		productID string
+		wantErr   bool
+	}{
+		0: {productID: ""},
+		1: {productID: "a1111c8c-c720-46c3-8534-2fcdd730040d"},
+		2: {productID: "   ", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: listDriverTripsRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		dres, err := client.ListDriverTrips(&uber.DriverInfoQuery{
+			ProductID:     tt.productID,
+			MaxPageNumber: 1,
+			Throttle:      uber.NoThrottle,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			if len(recorder.requests) != 0 {
+				t.Errorf("#%d: an invalid query must not be sent", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		for page := range dres.Pages {
+			if page.Err != nil {
+				t.Errorf("#%d: page err: %v", i, page.Err)
+			}
+		}
+
+		recorder.Lock()
+		queries := recorder.queries
+		recorder.Unlock()
+		if len(queries) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(queries))
+			continue
+		}
+		_, sent := queries[0]["product_id"]
+		if got := queries[0].Get("product_id"); got != tt.productID || (tt.productID == "" && sent) {
+			t.Errorf("#%d: product_id param: got %q (sent=%v) want %q", i, got, sent, tt.productID)
+		}
+	}
+
+	// Payments can't be filtered by product.
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: listDriverPaymentsRoute}}
+	client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+	dres, err := client.ListDriverPayments(&uber.DriverInfoQuery{
+		ProductID:     "a1111c8c-c720-46c3-8534-2fcdd730040d",
+		MaxPageNumber: 1,
+		Throttle:      uber.NoThrottle,
+	})
+	if err != nil {
+		t.Fatalf("payments: unexpected err: %v", err)
+	}
+	for range dres.Pages {
+	}
+	recorder.Lock()
+	for i, query := range recorder.queries {
+		if _, sent := query["product_id"]; sent {
+			t.Errorf("payments #%d: unexpectedly sent product_id", i)
+		}
+	}
+	recorder.Unlock()
+
+	// The rider's history.
+	if _, _, err := client.ListHistoryByProduct(nil, " "); err == nil {
+		t.Errorf("history: expecting an error for a blank productID")
+	}
+	recorder = &recordingRoundTripper{base: &tRoundTripper{route: listHistoryRoute}}
+	client.SetHTTPRoundTripper(recorder)
+	pagesChan, cancelPaging, err := client.ListHistoryByProduct(&uber.Pager{LimitPerPage: 2, MaxPages: 1}, "a1111c8c-c720-46c3-8534-2fcdd730040d")
+	if err != nil {
+		t.Fatalf("history: unexpected err: %v", err)
+	}
+	for page := range pagesChan {
+		if page.Err != nil {
+			t.Errorf("history: page err: %v", page.Err)
+		}
+	}
+	cancelPaging()
+	recorder.Lock()
+	defer recorder.Unlock()
+	if len(recorder.queries) == 0 {
+		t.Fatalf("history: no requests were sent")
+	}
+	for i, query := range recorder.queries {
+		if got, want := query.Get("product_id"), "a1111c8c-c720-46c3-8534-2fcdd730040d"; got != want {
+			t.Errorf("history #%d: product_id param: got %q want %q", i, got, want)
+		}
+	}
+}
+func TestListDeliveries(t *testing.T) {
+	t.Skipf("Need to get ListDelivery samples from Uber")
+
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: listDeliveriesRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		req         *uber.DeliveryListRequest
+		wantAtLeast int
+		wantErr     bool
+	}{
+		0: {
+			req: &uber.DeliveryListRequest{},
+		},
+		1: {
+			req: nil,
+		},
+		2: {
+			req: &uber.DeliveryListRequest{
+				LimitPerPage:  4,
+				MaxPageNumber: 2,
+				StartOffset:   10,
+			},
+			wantAtLeast: 8,
+		},
+	}
+
+	for i, tt := range tests {
+		dres, err := client.ListDeliveries(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: want non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		if dres == nil {
+			t.Errorf("#%d: expecting non-nil delivery response", i)
+			continue
+		}
+
+		itemCount := 0
+		for page := range dres.Pages {
+			if page.Err != nil {
+				t.Errorf("#%d: err: %v", i, page.Err)
+				continue
+			}
+
+			itemCount += len(page.Deliveries)
+		}
+		if itemCount < tt.wantAtLeast {
+			t.Errorf("#%d: got=%d wantAtLeast=%d", i, itemCount, tt.wantAtLeast)
+		}
+	}
+}
+
+type sandboxState string
+
+const (
+	sandboxUnknown    sandboxState = "unknown"
+	sandboxSandbox    sandboxState = "sandboxed"
+	sandboxProduction sandboxState = "production"
+)
+
+func TestNewClientFromEnv(t *testing.T) {
+	tests := [...]struct {
+		token, legacyToken, sandbox string
+		wantErr                     bool
+		wantSandboxed               bool
+	}{
+		0: {wantErr: true},
+		1: {token: "  ", wantErr: true},
+		2: {token: testToken1},
+		3: {legacyToken: testToken1},
+		4: {token: testToken1, legacyToken: "ignored"},
+		5: {token: testToken1, sandbox: "true", wantSandboxed: true},
+		6: {token: testToken1, sandbox: "1", wantSandboxed: true},
+		7: {token: testToken1, sandbox: "false"},
+		8: {token: testToken1, sandbox: "yes please", wantErr: true},
+		9: {sandbox: "true", wantErr: true},
+	}
+
+	for i, tt := range tests {
+		t.Setenv("UBER_TOKEN", tt.token)
+		t.Setenv("UBER_TOKEN_KEY", tt.legacyToken)
+		t.Setenv("UBER_SANDBOX", tt.sandbox)
+
+		client, err := uber.NewClientFromEnv()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		if got := client.Sandboxed(); got != tt.wantSandboxed {
+			t.Errorf("#%d: sandboxed: got=%v want=%v", i, got, tt.wantSandboxed)
+		}
+
+		// The token from the environment must be the one sent.
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: listProducts}}
+		client.SetHTTPRoundTripper(recorder)
+		if _, err := client.ListProducts(&uber.Place{Latitude: 37.77, Longitude: -122.41}); err != nil {
+			t.Errorf("#%d: listProducts: %v", i, err)
+			continue
+		}
+		wantAuth := "Bearer " + otils.FirstNonEmptyString(tt.token, tt.legacyToken)
+		if got := recorder.headers[0].Get("Authorization"); got != wantAuth {
+			t.Errorf("#%d: authorization: got=%q want=%q", i, got, wantAuth)
+		}
+	}
+}
+
+func TestSandboxSetSurge(t *testing.T) {
+	productID := "a1111c8c-c720-46c3-8534-2fcdd730040d"
+
+	tests := [...]struct {
+		sandboxed  bool
+		productID  string
+		multiplier float64
+		wantErr    error
+		wantBody   string
+	}{
+		0: {sandboxed: false, productID: productID, multiplier: 2, wantErr: uber.ErrNotSandboxed},
+		1: {sandboxed: true, productID: " ", multiplier: 2, wantErr: errAny},
+		2: {sandboxed: true, productID: productID, multiplier: 0.9, wantErr: errAny},
+		3: {sandboxed: true, productID: productID, multiplier: 2.2, wantBody: `{"surge_multiplier":2.2}`},
+		4: {sandboxed: true, productID: productID, multiplier: 1, wantBody: `{"surge_multiplier":1}`},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetSandboxMode(tt.sandboxed)
+		recorder := &recordingRoundTripper{base: &bodyTrackingRoundTripper{code: http.StatusNoContent}}
+		client.SetHTTPRoundTripper(recorder)
+
+		err = client.SandboxSetSurge(tt.productID, tt.multiplier)
+		if tt.wantErr != nil {
+			if err == nil || (tt.wantErr != errAny && err != tt.wantErr) {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			if len(recorder.requests) != 0 {
+				t.Errorf("#%d: no requests should be made, got %q", i, recorder.requests)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		if len(recorder.requests) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.requests))
+			continue
+		}
+		if got, want := recorder.requests[0], "PUT /v1.2/sandbox/products/"+productID; got != want {
+			t.Errorf("#%d: request: got=%q want=%q", i, got, want)
+		}
+		if got, want := recorder.hosts[0], "sandbox-api.uber.com"; got != want {
+			t.Errorf("#%d: host: got=%q want=%q", i, got, want)
+		}
+		if got := string(recorder.bodies[0]); got != tt.wantBody {
+			t.Errorf("#%d: body: got=%s want=%s", i, got, tt.wantBody)
+		}
+	}
+}
+
+func TestClientSandboxing(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	backend := &tRoundTripper{route: sandboxTesterRoute}
+	client.SetHTTPRoundTripper(backend)
+
+	tests := [...]struct {
+		do func(c *uber.Client)
+
+		sandboxed bool
+		want      sandboxState
+	}{
+
+		0: {
+			want:      sandboxProduction,
+			sandboxed: false,
+
+			do: func(c *uber.Client) {
+				resChan, _, _ := c.EstimatePrice(&uber.EstimateRequest{
+					StartLatitude:  37.7752315,
+					EndLatitude:    37.7752415,
+					StartLongitude: -122.418075,
+					EndLongitude:   -122.518075,
+				})
+				for res := range resChan {
+					if false {
+						t.Logf("res: %#v\n", res)
+					}
+				}
+			},
+		},
+		1: {
+			want:      sandboxSandbox,
+			sandboxed: true,
+
+			do: func(c *uber.Client) {
+				_, _ = c.RequestRide(&uber.RideRequest{
+					FareID:     "fareID-1",
+					StartPlace: uber.PlaceHome,
+					EndPlace:   uber.PlaceWork,
+				})
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetSandboxMode(tt.sandboxed)
+		tt.do(client)
+
+		got := backend.exhaust.(sandboxState)
+		if want := tt.want; got != want {
+			t.Errorf("#%d: sandboxed: got=(%v) want=(%v)", i, got, want)
+		}
+	}
+}
+
+func TestRequestOptionsSandbox(t *testing.T) {
+	sandboxed, production := true, false
+	const tripID = "a1111c8c-c720-46c3-8534-2fcdd730040d"
+
+	tests := [...]struct {
+		clientSandboxed bool
+		opts            *uber.RequestOptions
+		wantHost        string
+	}{
+		0: {clientSandboxed: false, wantHost: "api.uber.com"},
+		1: {clientSandboxed: true, wantHost: "sandbox-api.uber.com"},
+		2: {clientSandboxed: false, opts: &uber.RequestOptions{}, wantHost: "api.uber.com"},
+		3: {clientSandboxed: false, opts: &uber.RequestOptions{Sandbox: &sandboxed}, wantHost: "sandbox-api.uber.com"},
+		4: {clientSandboxed: true, opts: &uber.RequestOptions{Sandbox: &production}, wantHost: "api.uber.com"},
+		5: {clientSandboxed: true, opts: &uber.RequestOptions{Sandbox: &sandboxed}, wantHost: "sandbox-api.uber.com"},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetSandboxMode(tt.clientSandboxed)
+		tripRecorder := &recordingRoundTripper{base: &tRoundTripper{route: tripByIDRoute}}
+		client.SetHTTPRoundTripper(tripRecorder)
+
+		if _, err := client.TripByIDWithOptions(tripID, tt.opts); err != nil {
+			t.Errorf("#%d: tripByID: err: %v", i, err)
+			continue
+		}
+
+		fareRecorder := &recordingRoundTripper{base: &tRoundTripper{route: upfrontFareRoute}}
+		client.SetHTTPRoundTripper(fareRecorder)
+		fareReq := &uber.EstimateRequest{
+			StartLatitude:  37.7752415,
+			StartLongitude: -122.518075,
+
+			EndPlace: uber.PlaceWork,
+		}
+		if _, err := client.UpfrontFareWithOptions(fareReq, tt.opts); err != nil {
+			t.Errorf("#%d: upfrontFare: err: %v", i, err)
+			continue
+		}
+
+		for _, hosts := range [][]string{tripRecorder.hosts, fareRecorder.hosts} {
+			if len(hosts) != 1 || hosts[0] != tt.wantHost {
+				t.Errorf("#%d: hosts: got=%q want=[%q]", i, hosts, tt.wantHost)
+			}
+		}
+		if got := client.Sandboxed(); got != tt.clientSandboxed {
+			t.Errorf("#%d: the options changed the sandbox mode of the client to %v", i, got)
+		}
+	}
+
+	// Calls with different options can be made concurrently on the same client.
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: tripByIDRoute}}
+	client.SetHTTPRoundTripper(recorder)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		opts := &uber.RequestOptions{Sandbox: &production}
+		if i%2 == 0 {
+			opts.Sandbox = &sandboxed
+		}
+		wg.Add(1)
+		go func(opts *uber.RequestOptions) {
+			defer wg.Done()
+			if _, err := client.TripByIDWithOptions(tripID, opts); err != nil {
+				t.Errorf("concurrent tripByID: err: %v", err)
+			}
+		}(opts)
+	}
+	wg.Wait()
+
+	hostCounts := make(map[string]int)
+	for _, host := range recorder.hosts {
+		hostCounts[host] += 1
+	}
+	if want := map[string]int{"api.uber.com": 5, "sandbox-api.uber.com": 5}; !reflect.DeepEqual(hostCounts, want) {
+		t.Errorf("concurrent hosts: got=%v want=%v", hostCounts, want)
+	}
+}
+
+// endlessPagesRoundTripper responds to every request with the
+// same non-empty page so that the pagination never ends by itself.
+type endlessPagesRoundTripper string
+
+var _ http.RoundTripper = (*endlessPagesRoundTripper)(nil)
+
+func (ept endlessPagesRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := makeResp("200 OK", http.StatusOK)
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Body = ioutil.NopCloser(strings.NewReader(string(ept)))
+	return resp, nil
+}
+
+// droppedConnectionRoundTripper responds with the given body
+// but the connection drops right after the body was read.
+type droppedConnectionRoundTripper string
+
+var _ http.RoundTripper = (*droppedConnectionRoundTripper)(nil)
+
+func (dct droppedConnectionRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := makeResp("200 OK", http.StatusOK)
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Body = ioutil.NopCloser(io.MultiReader(strings.NewReader(string(dct)), iotest.ErrReader(io.ErrUnexpectedEOF)))
+	return resp, nil
+}
+
+func TestTruncatedPages(t *testing.T) {
+	truncatedPage, err := ioutil.ReadFile("./testdata/driver_trips_truncated.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+
+	listDriverTrips := func(c *uber.Client) (int, error) {
+		dres, err := c.ListDriverTrips(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+		if err != nil {
+			return 0, err
+		}
+		n := 0
+		for page := range dres.Pages {
+			n += len(page.Trips)
+			if page.Err != nil {
+				return n, page.Err
+			}
+		}
+		return n, nil
+	}
+	listHistory := func(c *uber.Client) (int, error) {
+		pagesChan, cancelPaging, err := c.ListHistory(nil)
+		if err != nil {
+			return 0, err
+		}
+		defer cancelPaging()
+		n := 0
+		for page := range pagesChan {
+			n += len(page.Trips)
+			if page.Err != nil {
+				return n, page.Err
+			}
+		}
+		return n, nil
+	}
+	estimateReq := &uber.EstimateRequest{StartLatitude: 37.7752315, StartLongitude: -122.418075}
+	estimatePrice := func(c *uber.Client) (int, error) {
+		pagesChan, cancelPaging, err := c.EstimatePrice(estimateReq)
+		if err != nil {
+			return 0, err
+		}
+		defer cancelPaging()
+		n := 0
+		for page := range pagesChan {
+			n += len(page.Estimates)
+			if page.Err != nil {
+				return n, page.Err
+			}
+		}
+		return n, nil
+	}
+	estimateTime := func(c *uber.Client) (int, error) {
+		pagesChan, cancelPaging, err := c.EstimateTime(estimateReq)
+		if err != nil {
+			return 0, err
+		}
+		defer cancelPaging()
+		n := 0
+		for page := range pagesChan {
+			n += len(page.Estimates)
+			if page.Err != nil {
+				return n, page.Err
+			}
+		}
+		return n, nil
+	}
+	listDeliveries := func(c *uber.Client) (int, error) {
+		dres, err := c.ListDeliveries(&uber.DeliveryListRequest{ThrottleDurationMs: uber.NoThrottle})
+		if err != nil {
+			return 0, err
+		}
+		defer dres.Cancel()
+		n := 0
+		for page := range dres.Pages {
+			n += len(page.Deliveries)
+			if page.Err != nil {
+				return n, page.Err
+			}
+		}
+		return n, nil
+	}
+
+	tests := [...]struct {
+		name      string
+		transport http.RoundTripper
+		list      func(*uber.Client) (int, error)
+
+		wantTruncated bool
+		wantItems     int
+	}{
+		0: {
+			name:      "body cut short",
+			transport: endlessPagesRoundTripper(truncatedPage),
+			list:      listDriverTrips, wantTruncated: true, wantItems: 1,
+		},
+		1: {
+			name:      "connection dropped",
+			transport: droppedConnectionRoundTripper(truncatedPage),
+			list:      listDriverTrips, wantTruncated: true, wantItems: 1,
+		},
+		2: {
+			name:      "cut short before any item",
+			transport: endlessPagesRoundTripper(`{"count": 4, "limit": 2, "trips": [{"trip_id": "t`),
+			list:      listDriverTrips, wantTruncated: true, wantItems: 0,
+		},
+		3: {
+			name:      "history cut short",
+			transport: endlessPagesRoundTripper(`{"count": 4, "history": [{"request_id": "r1"}, {"request_id": "r2"}, `),
+			list:      listHistory, wantTruncated: true, wantItems: 2,
+		},
+		4: {
+			name:      "malformed but whole body",
+			transport: endlessPagesRoundTripper(`{"count": 4, "trips": [{"trip_id": "t1"}, }`),
+			list:      listDriverTrips,
+		},
+		5: {
+			name:      "price estimates cut short",
+			transport: endlessPagesRoundTripper(`{"count": 4, "prices": [{"product_id": "p1"}, {"product_id": "p2"`),
+			list:      estimatePrice, wantTruncated: true, wantItems: 1,
+		},
+		6: {
+			name:      "time estimates cut short",
+			transport: droppedConnectionRoundTripper(`{"count": 4, "times": [{"product_id": "p1"}, {"product_id": "p2"}]`),
+			list:      estimateTime, wantTruncated: true, wantItems: 2,
+		},
+		7: {
+			name:      "deliveries cut short",
+			transport: endlessPagesRoundTripper(`{"count": 4, "deliveries": [{"delivery_id": "d1"}, {"deli`),
+			list:      listDeliveries, wantTruncated: true, wantItems: 1,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(tt.transport)
+
+		n, err := tt.list(client)
+		if err == nil {
+			t.Errorf("#%d: %s: expecting a non-nil error", i, tt.name)
+			continue
+		}
+		etr, truncated := err.(*uber.ErrTruncatedResponse)
+		if truncated != tt.wantTruncated {
+			t.Errorf("#%d: %s: got err %#v, want truncated=%v", i, tt.name, err, tt.wantTruncated)
+			continue
+		}
+		if n != tt.wantItems {
+			t.Errorf("#%d: %s: got %d items want %d", i, tt.name, n, tt.wantItems)
+		}
+		if truncated && etr.ItemsParsed != tt.wantItems {
+			t.Errorf("#%d: %s: ItemsParsed: got %d want %d", i, tt.name, etr.ItemsParsed, tt.wantItems)
+		}
+	}
+}
+
+func TestEstimatePagingStopsWhenContextDone(t *testing.T) {
+	estimateReq := &uber.EstimateRequest{StartLatitude: 37.7752315, StartLongitude: -122.418075}
+
+	tests := [...]struct {
+		name           string
+		page           string
+		cancelUpfront  bool
+		wantFirstPages int
+		pages          func(ctx context.Context, c *uber.Client) (interface{}, error)
+	}{
+		0: {
+			name:           "EstimatePriceWithContext",
+			page:           `{"prices":[{"product_id":"p1"}],"count":100}`,
+			wantFirstPages: 1,
+			pages: func(ctx context.Context, c *uber.Client) (interface{}, error) {
+				pagesChan, _, err := c.EstimatePriceWithContext(ctx, estimateReq)
+				return pagesChan, err
+			},
+		},
+		1: {
+			name:           "EstimateTimeWithContext",
+			page:           `{"times":[{"product_id":"p1"}],"count":100}`,
+			wantFirstPages: 1,
+			pages: func(ctx context.Context, c *uber.Client) (interface{}, error) {
+				pagesChan, _, err := c.EstimateTimeWithContext(ctx, estimateReq)
+				return pagesChan, err
+			},
+		},
+		2: {
+			name:          "EstimatePriceWithContext",
+			page:          `{"prices":[{"product_id":"p1"}],"count":100}`,
+			cancelUpfront: true,
+			pages: func(ctx context.Context, c *uber.Client) (interface{}, error) {
+				pagesChan, _, err := c.EstimatePriceWithContext(ctx, estimateReq)
+				return pagesChan, err
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(endlessPagesRoundTripper(tt.page))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		if tt.cancelUpfront {
+			cancel()
+		}
+		pages, err := tt.pages(ctx, client)
+		if err != nil {
+			t.Errorf("#%d: %s: unexpected err: %v", i, tt.name, err)
+			cancel()
+			continue
+		}
+		pagesChan := reflect.ValueOf(pages)
+		for j := 0; j < tt.wantFirstPages; j++ {
+			if _, ok := pagesChan.Recv(); !ok {
+				t.Errorf("#%d: %s: expecting page #%d", i, tt.name, j)
+			}
+		}
+		cancel()
+
+		// Only the cancelation of the context stops the
+		// paging, after which the channel must be closed.
+		closed := make(chan bool)
+		go func() {
+			defer close(closed)
+			for {
+				if _, ok := pagesChan.Recv(); !ok {
+					return
+				}
+			}
+		}()
+		select {
+		case <-closed:
+		case <-time.After(3 * time.Second):
+			t.Errorf("#%d: %s: pages channel not closed after the context was canceled", i, tt.name)
+		}
+	}
+}
+
+func TestPagingStopsAfterCancel(t *testing.T) {
+	estimateReq := &uber.EstimateRequest{StartLatitude: 37.7752315, StartLongitude: -122.418075}
+
+	tests := [...]struct {
+		name  string
+		page  string
+		pages func(c *uber.Client) (interface{}, func(), error)
+	}{
+		0: {
+			name: "EstimatePrice",
+			page: `{"prices":[{"product_id":"p1"}],"count":100}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				return c.EstimatePrice(estimateReq)
+			},
+		},
+		1: {
+			name: "EstimateTime",
+			page: `{"times":[{"product_id":"p1"}],"count":100}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				return c.EstimateTime(estimateReq)
+			},
+		},
+		2: {
+			name: "ListHistory",
+			page: `{"history":[{"request_id":"r1"}],"count":1000}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				return c.ListHistory(&uber.Pager{LimitPerPage: 1})
+			},
+		},
+		3: {
+			name: "ListDriverTrips",
+			page: `{"trips":[{"trip_id":"t1"}],"limit":1}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				dres, err := c.ListDriverTrips(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+				if err != nil {
+					return nil, nil, err
+				}
+				return dres.Pages, dres.Cancel, nil
+			},
+		},
+		4: {
+			name: "ListDriverPayments",
+			page: `{"payments":[{"payment_id":"p1"}],"limit":1}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				dres, err := c.ListDriverPayments(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+				if err != nil {
+					return nil, nil, err
+				}
+				return dres.Pages, dres.Cancel, nil
+			},
+		},
+		5: {
+			name: "ListDeliveries",
+			page: `{"deliveries":[{"delivery_id":"d1"}],"next_page":"offset=1"}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				dres, err := c.ListDeliveries(&uber.DeliveryListRequest{ThrottleDurationMs: uber.NoThrottle})
+				if err != nil {
+					return nil, nil, err
+				}
+				return dres.Pages, dres.Cancel, nil
+			},
+		},
+		6: {
+			name: "DriverTripReceipts",
+			page: `{"trips":[{"trip_id":"t1"}],"limit":1}`,
+			pages: func(c *uber.Client) (interface{}, func(), error) {
+				return c.DriverTripReceipts(&uber.DriverInfoQuery{Throttle: uber.NoThrottle})
+			},
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(endlessPagesRoundTripper(tt.page))
+
+		pages, cancel, err := tt.pages(client)
+		if err != nil {
+			t.Errorf("#%d: %s: unexpected err: %v", i, tt.name, err)
+			continue
+		}
+		pagesChan := reflect.ValueOf(pages)
+		if _, ok := pagesChan.Recv(); !ok {
+			t.Errorf("#%d: %s: expecting a first page", i, tt.name)
+			continue
+		}
+		cancel()
+
+		// Stop reading for a while: the producer must not
+		// block on sending the next page and must instead
+		// discard it and close the channel.
+		time.Sleep(200 * time.Millisecond)
+
+		chosen, _, recvOK := reflect.Select([]reflect.SelectCase{
+			{Dir: reflect.SelectRecv, Chan: pagesChan},
+			{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(time.After(time.Second))},
+		})
+		switch {
+		case chosen == 1:
+			t.Errorf("#%d: %s: the pages channel wasn't closed after cancel", i, tt.name)
+		case recvOK:
+			t.Errorf("#%d: %s: got a page after cancel", i, tt.name)
+		}
+	}
+}
+
+// rateLimitingRoundTripper responds with a 429 to the requests
+// whose index is in limited and passes the others on to base.
+type rateLimitingRoundTripper struct {
+	sync.Mutex
+	base       http.RoundTripper
+	body       []byte
+	retryAfter string
+	limited    map[int]bool
+	requests   int
+}
+
+var _ http.RoundTripper = (*rateLimitingRoundTripper)(nil)
+
+func (rlrt *rateLimitingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rlrt.Lock()
+	index := rlrt.requests
+	rlrt.requests += 1
+	rlrt.Unlock()
+
+	if !rlrt.limited[index] {
+		return rlrt.base.RoundTrip(req)
+	}
+	resp := makeResp("429 Too Many Requests", http.StatusTooManyRequests)
+	resp.Header.Set("Content-Type", "application/json")
+	resp.Header.Set("Retry-After", rlrt.retryAfter)
+	resp.Body = ioutil.NopCloser(bytes.NewReader(rlrt.body))
+	return resp, nil
+}
+
+func TestDriverPagingRateLimited(t *testing.T) {
+	rateLimitedBody, err := ioutil.ReadFile("./testdata/rate_limited.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	pastDate := time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat)
+
+	tests := [...]struct {
+		limited    map[int]bool
+		retryAfter string
+		maxRetries int
+
+		wantItems    int
+		wantPages    int
+		wantErrEnd   bool
+		wantRequests int
+	}{
+		// The second page is rate limited once and then retried.
+		0: {limited: map[int]bool{1: true}, retryAfter: "0", wantItems: 10, wantPages: 4, wantRequests: 6},
+		1: {limited: map[int]bool{1: true}, retryAfter: pastDate, wantItems: 10, wantPages: 4, wantRequests: 6},
+		2: {limited: map[int]bool{1: true, 3: true}, retryAfter: "0", maxRetries: 1, wantItems: 10, wantPages: 4, wantRequests: 7},
+
+		// The retries are exhausted.
+		3: {limited: map[int]bool{1: true, 2: true}, retryAfter: "0", maxRetries: 1, wantItems: 2, wantPages: 1, wantErrEnd: true, wantRequests: 3},
+		4: {limited: map[int]bool{1: true}, retryAfter: "0", maxRetries: uber.NoRateLimitRetries, wantItems: 2, wantPages: 1, wantErrEnd: true, wantRequests: 2},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		backend := &rateLimitingRoundTripper{
+			base:       &tRoundTripper{route: listDriverTripsRoute},
+			body:       rateLimitedBody,
+			retryAfter: tt.retryAfter,
+			limited:    tt.limited,
+		}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, backend))
+
+		dres, err := client.ListDriverTrips(&uber.DriverInfoQuery{
+			LimitPerPage:        2,
+			Throttle:            uber.NoThrottle,
+			MaxRateLimitRetries: tt.maxRetries,
+		})
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		var pages []*uber.DriverInfoPage
+		for page := range dres.Pages {
+			pages = append(pages, page)
+		}
+
+		var errPage *uber.DriverInfoPage
+		if n := len(pages); n > 0 && pages[n-1].Err != nil {
+			errPage, pages = pages[n-1], pages[:n-1]
+		}
+		if gotErrEnd := errPage != nil; gotErrEnd != tt.wantErrEnd {
+			t.Errorf("#%d: gotErrEnd=%v wantErrEnd=%v errPage=%#v", i, gotErrEnd, tt.wantErrEnd, errPage)
+		}
+		items := 0
+		for _, page := range pages {
+			if page.Err != nil {
+				t.Errorf("#%d: page #%d: unexpected err: %v", i, page.PageNumber, page.Err)
+			}
+			items += len(page.Trips)
+		}
+		if len(pages) != tt.wantPages || items != tt.wantItems {
+			t.Errorf("#%d: got %d pages with %d items want %d pages with %d items", i, len(pages), items, tt.wantPages, tt.wantItems)
+		}
+		if backend.requests != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, backend.requests, tt.wantRequests)
+		}
+	}
+}
+
+func TestDriverPagingStalled(t *testing.T) {
+	stalledPage, err := ioutil.ReadFile("./testdata/driver_trips_stalled.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+
+	tests := [...]struct {
+		name          string
+		page          string
+		maxPageNumber int
+		payments      bool
+
+		wantPages  int
+		wantErrEnd bool
+	}{
+		// The same offset is sent back for every page.
+		0: {name: "non-advancing offset", page: string(stalledPage), wantPages: 1, wantErrEnd: true},
+		1: {name: "payments with non-advancing offset", page: `{"payments":[{"payment_id":"p1"}],"limit":1}`, payments: true, wantPages: 1, wantErrEnd: true},
+		2: {name: "no limit", page: `{"trips":[{"trip_id":"t1"}],"offset":0}`, wantPages: 1, wantErrEnd: true},
+		3: {name: "capped by MaxPageNumber", page: string(stalledPage), maxPageNumber: 1, wantPages: 1},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(endlessPagesRoundTripper(tt.page))
+
+		query := &uber.DriverInfoQuery{Throttle: uber.NoThrottle, MaxPageNumber: tt.maxPageNumber}
+		list := client.ListDriverTrips
+		if tt.payments {
+			list = client.ListDriverPayments
+		}
+		dres, err := list(query)
+		if err != nil {
+			t.Errorf("#%d: %s: unexpected err: %v", i, tt.name, err)
+			continue
+		}
+
+		var pages []*uber.DriverInfoPage
+		timeout := time.After(5 * time.Second)
+	receive:
+		for {
+			select {
+			case page, ok := <-dres.Pages:
+				if !ok {
+					break receive
+				}
+				pages = append(pages, page)
+			case <-timeout:
+				dres.Cancel()
+				t.Fatalf("#%d: %s: paging didn't end after %d pages", i, tt.name, len(pages))
+			}
+		}
+
+		var errPage *uber.DriverInfoPage
+		if n := len(pages); n > 0 && pages[n-1].Err != nil {
+			errPage, pages = pages[n-1], pages[:n-1]
+		}
+		if len(pages) != tt.wantPages {
+			t.Errorf("#%d: %s: got %d pages want %d", i, tt.name, len(pages), tt.wantPages)
+		}
+		if tt.wantErrEnd {
+			if errPage == nil || errPage.Err != uber.ErrPaginationStalled {
+				t.Errorf("#%d: %s: expected the last page to have ErrPaginationStalled, got %+v", i, tt.name, errPage)
+			}
+		} else if errPage != nil {
+			t.Errorf("#%d: %s: unexpected err: %v", i, tt.name, errPage.Err)
+		}
+	}
+}
+
+func TestListHistory(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: listHistoryRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	tests := [...]struct {
+		req           *uber.Pager
+		cancelAfter   int
+		wantPageCount int
+		wantTripCount int
+	}{
+		0: {
+			req:           &uber.Pager{LimitPerPage: 2},
+			wantPageCount: 3,
+			wantTripCount: 5,
+		},
+		1: {
+			req:           &uber.Pager{LimitPerPage: 2, MaxPages: 2},
+			wantPageCount: 2,
+			wantTripCount: 4,
+		},
+		2: {
+			req:           &uber.Pager{LimitPerPage: 2, StartOffset: 2},
+			wantPageCount: 2,
+			wantTripCount: 3,
+		},
+		3: {
+			// Canceling after the first page.
+			req:           &uber.Pager{LimitPerPage: 2},
+			cancelAfter:   1,
+			wantPageCount: 1,
+			wantTripCount: 2,
+		},
+	}
+
+	for i, tt := range tests {
+		pagesChan, cancelPaging, err := client.ListHistory(tt.req)
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+
+		pageCount, tripCount := 0, 0
+		for page := range pagesChan {
+			if page.Err != nil {
+				t.Errorf("#%d: page #%d err: %v", i, page.PageNumber, page.Err)
+				continue
+			}
+			pageCount += 1
+			tripCount += len(page.Trips)
+			if pageCount == tt.cancelAfter {
+				cancelPaging()
+			}
+		}
+
+		if g, w := pageCount, tt.wantPageCount; g != w {
+			t.Errorf("#%d: pageCount: got=%d want=%d", i, g, w)
+		}
+		if g, w := tripCount, tt.wantTripCount; g != w {
+			t.Errorf("#%d: tripCount: got=%d want=%d", i, g, w)
+		}
+	}
+}
+
+func TestEstimatePrice(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: estimatePriceRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	tests := [...]struct {
+		ereq    *uber.EstimateRequest
+		wantErr bool
+		want    []*uber.PriceEstimate
+	}{
+		0: {
+			ereq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				EndLatitude:    37.7752415,
+				StartLongitude: -122.418075,
+				EndLongitude:   -122.518075,
+			},
+			want: priceEstimateFromFile("./fakeserver/fixtures/estimates-price.json"),
+		},
+		1: {
+			ereq:    nil,
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		estimatesChan, cancelPaging, err := client.EstimatePrice(tt.ereq)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d err: %v", i, err)
+			continue
+		}
+
+		firstPage := <-estimatesChan
+		// Then cancel it
+		cancelPaging()
+
+		if err := firstPage.Err; err != nil {
+			t.Errorf("#%d paging err: %v, firstPage: %#v", i, err, firstPage)
+			continue
+		}
+		estimates := firstPage.Estimates
+
+		gotBlob, wantBlob := jsonSerialize(estimates), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestEstimatePriceStream(t *testing.T) {
+	pagedEstimates, err := ioutil.ReadFile("./testdata/price-estimate-paged.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	perPage := len(priceEstimateFromFile("./fakeserver/fixtures/estimates-price.json"))
+
+	tests := [...]struct {
+		rt        http.RoundTripper
+		ereq      *uber.EstimateRequest
+		wantErr   bool
+		want      int
+		streamErr bool
+	}{
+		0: {ereq: nil, wantErr: true},
+		1: {
+			rt: endlessPagesRoundTripper(pagedEstimates),
+			ereq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				StartLongitude: -122.418075,
+				Pager:          uber.Pager{MaxPages: 3},
+			},
+			want: 3 * perPage,
+		},
+		2: {
+			// A single page without a count.
+			rt: &tRoundTripper{route: estimatePriceRoute},
+			ereq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				StartLongitude: -122.418075,
+			},
+			want: perPage,
+		},
+		3: {
+			rt: &bodyTrackingRoundTripper{code: http.StatusInternalServerError},
+			ereq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				StartLongitude: -122.418075,
+			},
+			streamErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(tt.rt)
+
+		stream, err := client.EstimatePriceStream(tt.ereq)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		got := 0
+		for estimate := range stream.Estimates {
+			if estimate == nil {
+				t.Errorf("#%d: got a nil estimate", i)
+			}
+			got += 1
+		}
+		if got != tt.want {
+			t.Errorf("#%d: estimates: got=%d want=%d", i, got, tt.want)
+		}
+		if gotErr := stream.Err() != nil; gotErr != tt.streamErr {
+			t.Errorf("#%d: stream err: got=%v wantErr=%v", i, stream.Err(), tt.streamErr)
+		}
+	}
+}
+
+func TestEstimatePriceStreamCancel(t *testing.T) {
+	pagedEstimates, err := ioutil.ReadFile("./testdata/price-estimate-paged.json")
+	if err != nil {
+		t.Fatalf("reading fixture: %v", err)
+	}
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetHTTPRoundTripper(endlessPagesRoundTripper(pagedEstimates))
+
+	// Without a page limit, the stream only ends once canceled.
+	stream, err := client.EstimatePriceStream(&uber.EstimateRequest{
+		StartLatitude:  37.7752315,
+		StartLongitude: -122.418075,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if estimate := <-stream.Estimates; estimate == nil {
+		t.Fatal("expecting a first estimate")
+	}
+	stream.Cancel()
+	// Canceling more than once must be safe.
+	stream.Cancel()
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case _, ok := <-stream.Estimates:
+			if !ok {
+				if err := stream.Err(); err != nil {
+					t.Errorf("unexpected err after canceling: %v", err)
+				}
+				return
+			}
+		case <-timeout:
+			t.Fatal("estimates channel was not closed after canceling")
+		}
+	}
+}
+
+func TestEstimatePriceSeatCount(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	tests := [...]struct {
+		seatCount int
+		wantErr   bool
+		// wantParam is the expected seat_count query
+		// parameter, "" if it must not be sent at all.
+		wantParam string
+	}{
+		0: {seatCount: 0, wantParam: ""},
+		1: {seatCount: 1, wantParam: "1"},
+		2: {seatCount: 2, wantParam: "2"},
+		3: {seatCount: 3, wantErr: true},
+		4: {seatCount: -1, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: estimatePriceRoute}}
+		client.SetHTTPRoundTripper(recorder)
+
+		estimatesChan, cancelPaging, err := client.EstimatePrice(&uber.EstimateRequest{
+			StartLatitude:  37.7752315,
+			EndLatitude:    37.7752415,
+			StartLongitude: -122.418075,
+			EndLongitude:   -122.518075,
+			SeatCount:      tt.seatCount,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		firstPage := <-estimatesChan
+		cancelPaging()
+		if err := firstPage.Err; err != nil {
+			t.Errorf("#%d: paging err: %v", i, err)
+			continue
+		}
+
+		recorder.Lock()
+		queries := recorder.queries
+		recorder.Unlock()
+		if len(queries) == 0 {
+			t.Errorf("#%d: expected at least one request", i)
+			continue
+		}
+		query := queries[0]
+		_, sent := query["seat_count"]
+		if tt.wantParam == "" {
+			if sent {
+				t.Errorf("#%d: unexpectedly sent seat_count=%q", i, query.Get("seat_count"))
+			}
+			continue
+		}
+		if got := query.Get("seat_count"); got != tt.wantParam {
+			t.Errorf("#%d: seat_count: got %q want %q", i, got, tt.wantParam)
+		}
+	}
+}
+
+func TestEstimatePriceCancelBeforeReading(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetHTTPRoundTripper(&tRoundTripper{route: estimatePriceRoute})
+
+	estimatesChan, cancelPaging, err := client.EstimatePrice(&uber.EstimateRequest{
+		StartLatitude:  37.7752315,
+		EndLatitude:    37.7752415,
+		StartLongitude: -122.418075,
+		EndLongitude:   -122.518075,
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	cancelPaging()
+	// Canceling more than once must be safe.
+	cancelPaging()
+
+	timeout := time.After(3 * time.Second)
+	for {
+		select {
+		case page, ok := <-estimatesChan:
+			if !ok {
+				return
+			}
+			// A page that was in-flight can still be
+			// delivered but it must not be an error page.
+			if page.Err != nil {
+				t.Fatalf("unexpected error page after canceling: %v", page.Err)
+			}
+		case <-timeout:
+			t.Fatal("pages channel was not closed after canceling")
+		}
+	}
+}
+
+func TestPriceEstimateIsSurging(t *testing.T) {
+	estimates := priceEstimateFromFile("./fakeserver/fixtures/estimates-price.json")
+	if len(estimates) == 0 {
+		t.Fatal("expecting at least one price estimate")
+	}
+
+	wantSurging := map[string]bool{
+		"POOL":   false, // No surge_multiplier.
+		"uberX":  false, // A multiplier of exactly 1.0.
+		"uberXL": true,
+		"SELECT": true,
+	}
+	wantMultipliers := map[string]float64{
+		"POOL":   0,
+		"uberX":  1.0,
+		"uberXL": 1.6,
+		"SELECT": 1.2,
+	}
+
+	for i, estimate := range estimates {
+		if g, w := estimate.IsSurging(), wantSurging[estimate.Name]; g != w {
+			t.Errorf("#%d: %q: isSurging: got=%v want=%v", i, estimate.Name, g, w)
+		}
+		if g, w := float64(estimate.SurgeMultiplier), wantMultipliers[estimate.Name]; g != w {
+			t.Errorf("#%d: %q: surgeMultiplier: got=%v want=%v", i, estimate.Name, g, w)
+		}
+
+		// Ensure that the multiplier survives a JSON round trip.
+		recv := new(uber.PriceEstimate)
+		if err := json.Unmarshal(jsonSerialize(estimate), recv); err != nil {
+			t.Errorf("#%d: %q: unmarshal err: %v", i, estimate.Name, err)
+			continue
+		}
+		if g, w := recv.SurgeMultiplier, estimate.SurgeMultiplier; g != w {
+			t.Errorf("#%d: %q: roundtrip surgeMultiplier: got=%v want=%v", i, estimate.Name, g, w)
+		}
+	}
+
+	var nilEstimate *uber.PriceEstimate
+	if nilEstimate.IsSurging() {
+		t.Error("a nil estimate cannot be surging")
+	}
+}
+
+func TestEstimatePrices(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetHTTPRoundTripper(estimatesByStartRoundTripper{
+		"37.7752315": "./fakeserver/fixtures/estimates-price.json",
+		"37.7752415": "./testdata/price-estimate-2.json",
+		"37.7752515": "./testdata/price-estimate-3.json",
+	})
+
+	if _, err := client.EstimatePrices(nil); err == nil {
+		t.Errorf("no requests: expected a non-nil error")
+	}
+
+	reqFromLat := func(lat float64) *uber.EstimateRequest {
+		return &uber.EstimateRequest{
+			StartLatitude:  lat,
+			StartLongitude: -122.418075,
+			EndLatitude:    37.7752415,
+			EndLongitude:   -122.518075,
+		}
+	}
+	reqs := []*uber.EstimateRequest{
+		0: reqFromLat(37.7752515),
+		1: reqFromLat(37.7752315),
+		// There are no estimates for this start location.
+		2: reqFromLat(40.7128),
+		3: nil,
+		4: reqFromLat(37.7752415),
+		5: reqFromLat(37.7752515),
+	}
+	wantFixtures := []string{
+		0: "./testdata/price-estimate-3.json",
+		1: "./fakeserver/fixtures/estimates-price.json",
+		4: "./testdata/price-estimate-2.json",
+		5: "./testdata/price-estimate-3.json",
+	}
+
+	results, err := client.EstimatePrices(reqs)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if len(results) != len(reqs) {
+		t.Fatalf("got %d results want %d", len(results), len(reqs))
+	}
+
+	for i, result := range results {
+		if result.Request != reqs[i] {
+			t.Errorf("#%d: result is for request %+v want %+v", i, result.Request, reqs[i])
+		}
+		if i >= len(wantFixtures) || wantFixtures[i] == "" {
+			if result.Err == nil || result.Estimates != nil {
+				t.Errorf("#%d: expected only an error, got estimates=%v err=%v", i, result.Estimates, result.Err)
+			}
+			continue
+		}
+		if result.Err != nil {
+			t.Errorf("#%d: err: %v", i, result.Err)
+			continue
+		}
+		gotBlob := jsonSerialize(result.Estimates)
+		wantBlob := jsonSerialize(priceEstimateFromFile(wantFixtures[i]))
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+// estimatesByStartRoundTripper serves the price estimates
+// fixture keyed by the start_latitude of the request.
+type estimatesByStartRoundTripper map[string]string
+
+func (ert estimatesByStartRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	diskPath, ok := ert[req.URL.Query().Get("start_latitude")]
+	if !ok {
+		return makeResp("no estimates for the start location", http.StatusNotFound), nil
+	}
+	return responseFromFileContent(diskPath), nil
+}
+
+func TestFlexibleNumbers(t *testing.T) {
+	tests := [...]struct {
+		blob string
+		v    interface{}
+		get  func(v interface{}) float64
+		want float64
+	}{
+		0: {
+			blob: `{"distance": 6.17}`, v: new(uber.PriceEstimate), want: 6.17,
+			get: func(v interface{}) float64 { return float64(v.(*uber.PriceEstimate).Distance) },
+		},
+		1: {
+			blob: `{"distance": "6.17"}`, v: new(uber.PriceEstimate), want: 6.17,
+			get: func(v interface{}) float64 { return float64(v.(*uber.PriceEstimate).Distance) },
+		},
+		2: {
+			blob: `{"fee": 5.5}`, v: new(uber.Delivery), want: 5.5,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Delivery).Fee) },
+		},
+		3: {
+			blob: `{"fee": "5.5"}`, v: new(uber.Delivery), want: 5.5,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Delivery).Fee) },
+		},
+		4: {
+			blob: `{"surge_multiplier": "1.5"}`, v: new(uber.Ride), want: 1.5,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Ride).SurgeMultiplier) },
+		},
+		5: {
+			blob: `{"distance": 2.5}`, v: new(uber.Trip), want: 2.5,
+			get: func(v interface{}) float64 { return v.(*uber.Trip).DistanceMiles },
+		},
+		6: {
+			blob: `{"distance": "2.5", "distance_unit": "mile"}`, v: new(uber.Trip), want: 2.5,
+			get: func(v interface{}) float64 { return v.(*uber.Trip).DistanceMiles },
+		},
+		7: {
+			blob: `{"distance": "2.5"}`, v: new(uber.Trip), want: 2.5,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Trip).Distance) },
+		},
+		8: {
+			// Distances in other units aren't in miles.
+			blob: `{"distance": 4, "distance_unit": "km"}`, v: new(uber.Trip), want: 0,
+			get: func(v interface{}) float64 { return v.(*uber.Trip).DistanceMiles },
+		},
+		9: {
+			blob: `{"fare": "6.2"}`, v: new(uber.Trip), want: 6.2,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Trip).Fare) },
+		},
+		10: {
+			blob: `{"surge_multiplier": 1.5}`, v: new(uber.Ride), want: 1.5,
+			get: func(v interface{}) float64 { return float64(v.(*uber.Ride).SurgeMultiplier) },
+		},
+		11: {
+			// DistanceMiles as sent back by a marshaled Trip.
+			blob: `{"distance": 4, "distance_unit": "km", "distance_miles": 2.5}`, v: new(uber.Trip), want: 2.5,
+			get: func(v interface{}) float64 { return v.(*uber.Trip).DistanceMiles },
+		},
+	}
+
+	for i, tt := range tests {
+		if err := json.Unmarshal([]byte(tt.blob), tt.v); err != nil {
+			t.Errorf("#%d: unmarshal: %v", i, err)
+			continue
+		}
+		if got := tt.get(tt.v); got != tt.want {
+			t.Errorf("#%d: got=%v want=%v", i, got, tt.want)
+		}
+	}
+
+	// The decoded values survive being marshaled.
+	trip := &uber.Trip{Distance: 4, Unit: "km", DistanceMiles: 2.5}
+	ride := &uber.Ride{SurgeMultiplier: 1.5}
+	delivery := &uber.Delivery{Fee: 5.5}
+	for i, v := range []interface{}{trip, ride, delivery} {
+		blob, err := json.Marshal(v)
+		if err != nil {
+			t.Errorf("roundtrip #%d: marshal: %v", i, err)
+			continue
+		}
+		recv := reflect.New(reflect.TypeOf(v).Elem()).Interface()
+		if err := json.Unmarshal(blob, recv); err != nil {
+			t.Errorf("roundtrip #%d: unmarshal: %v", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(recv, v) {
+			t.Errorf("roundtrip #%d:\ngot:  %#v\nwant: %#v", i, recv, v)
+		}
+	}
+}
+
+func TestEstimateRoundTrip(t *testing.T) {
+	there := &uber.EstimateRequest{
+		StartLatitude: 37.7752315, StartLongitude: -122.418075,
+		EndLatitude: 37.7752415, EndLongitude: -122.518075,
+	}
+	back := &uber.EstimateRequest{
+		StartLatitude: 37.7752415, StartLongitude: -122.518075,
+		EndLatitude: 37.7752315, EndLongitude: -122.418075,
+	}
+
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	client.SetHTTPRoundTripper(estimatesByStartRoundTripper{
+		"37.7752315": "./fakeserver/fixtures/estimates-price.json",
+		"37.7752415": "./testdata/price-estimate-2.json",
+	})
+
+	rte, err := client.EstimateRoundTrip(there, back)
+	if err != nil {
+		t.Fatalf("estimateRoundTrip: %v", err)
+	}
+
+	wantRanges := []string{
+		"uberX: 27.00 USD-36.00 USD",
+		"uberXL: 50.00 USD-65.00 USD",
+		"SELECT: 58.00 USD-74.00 USD",
+	}
+	var gotRanges []string
+	for _, est := range rte.Estimates {
+		gotRanges = append(gotRanges, fmt.Sprintf("%s: %s-%s", est.Name, est.Low, est.High))
+		if est.There == nil || est.Back == nil || est.There.ProductID != est.ProductID || est.Back.ProductID != est.ProductID {
+			t.Errorf("%s: expecting the estimates both ways", est.Name)
+		}
+	}
+	if !reflect.DeepEqual(gotRanges, wantRanges) {
+		t.Errorf("ranges:\ngot:  %q\nwant: %q", gotRanges, wantRanges)
+	}
+	// The fixture of the trip back has uberX's distance as a string.
+	if len(rte.Estimates) > 0 {
+		if g, w := float64(rte.Estimates[0].Back.Distance), 6.31; g != w {
+			t.Errorf("distance back: got=%v want=%v", g, w)
+		}
+		if g, w := float64(rte.Estimates[0].There.Distance), 6.17; g != w {
+			t.Errorf("distance there: got=%v want=%v", g, w)
+		}
+	}
+
+	wantExcluded := map[string]string{
+		// POOL
+		"26546650-e557-4a7b-86e7-6a3942445247": "not available for the trip back",
+		// BLACK
+		"d4abaae7-f4d6-4152-91cc-77523e8165a4": "not available for the trip there",
+	}
+	if !reflect.DeepEqual(rte.Excluded, wantExcluded) {
+		t.Errorf("excluded:\ngot:  %v\nwant: %v", rte.Excluded, wantExcluded)
+	}
+
+	// Both estimates are needed.
+	unknown := &uber.EstimateRequest{StartLatitude: 1, StartLongitude: 1, EndLatitude: 2, EndLongitude: 2}
+	if _, err := client.EstimateRoundTrip(there, unknown); err == nil {
+		t.Errorf("expecting an error when the trip back can't be estimated")
+	}
+	if _, err := client.EstimateRoundTrip(nil, back); err == nil {
+		t.Errorf("expecting an error for a nil estimate request")
+	}
+}
+
+func TestEstimateTime(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: estimateTimeRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	tests := [...]struct {
+		treq    *uber.EstimateRequest
+		wantErr bool
+		want    []*uber.TimeEstimate
+	}{
+		0: {
+			treq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				EndLatitude:    37.7752415,
+				StartLongitude: -122.418075,
+				EndLongitude:   -122.518075,
+				ProductID:      "a1111c8c-c720-46c3-8534-2fcdd730040d",
+			},
+			// Only the requested product.
+			want: timeEstimatesForProducts(timeEstimateFromFile("./fakeserver/fixtures/estimates-time.json"), "a1111c8c-c720-46c3-8534-2fcdd730040d"),
+		},
+		1: {
+			treq:    nil,
+			wantErr: true,
+		},
+		2: {
+			// No ProductID so the ETAs of all the products.
+			treq: &uber.EstimateRequest{
+				StartLatitude:  37.7752315,
+				StartLongitude: -122.418075,
+			},
+			want: timeEstimateFromFile("./fakeserver/fixtures/estimates-time.json"),
+		},
+	}
+
+	for i, tt := range tests {
+		if !tt.wantErr && len(tt.want) == 0 {
+			t.Errorf("#%d: expecting at least one estimate to compare against", i)
+			continue
+		}
+
+		estimatesChan, cancelPaging, err := client.EstimateTime(tt.treq)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d err: %v", i, err)
+			continue
+		}
+
+		firstPage := <-estimatesChan
+		// Then cancel it
+		cancelPaging()
+
+		if err := firstPage.Err; err != nil {
+			t.Errorf("#%d paging err: %v, firstPage: %#v", i, err, firstPage)
+			continue
+		}
+		estimates := firstPage.Estimates
+
+		gotBlob, wantBlob := jsonSerialize(estimates), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestDriverProfile(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: retrieveDriverProfileRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	invalidToken := fmt.Sprintf("%v", time.Now().Unix())
+
+	tests := [...]struct {
+		wantErr     bool
+		bearerToken string
+		want        *uber.Profile
+	}{
+		0: {
+			bearerToken: testToken1,
+			want:        driverProfileFromFileByToken(testToken1),
+		},
+		1: {
+			bearerToken: invalidToken,
+			wantErr:     true,
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetBearerToken(tt.bearerToken)
+		prof, err := client.DriverProfile()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		gotBlob, wantBlob := jsonSerialize(prof), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+		if got, want := prof.Vehicle.String(), "Silver Toyota Prius (7ABC123)"; got != want {
+			t.Errorf("#%d: vehicle: got=%q want=%q", i, got, want)
+		}
+	}
+}
+
+func TestVehicleString(t *testing.T) {
+	tests := [...]struct {
+		vehicle *uber.Vehicle
+		want    string
+	}{
+		0: {vehicle: nil, want: ""},
+		1: {vehicle: &uber.Vehicle{}, want: ""},
+		2: {
+			vehicle: &uber.Vehicle{Make: "Toyota", Model: "Prius", Color: "Black", LicensePlate: "7ABC123"},
+			want:    "Black Toyota Prius (7ABC123)",
+		},
+		3: {vehicle: &uber.Vehicle{Make: "Toyota", Model: "Prius"}, want: "Toyota Prius"},
+		4: {vehicle: &uber.Vehicle{LicensePlate: " 7ABC123 "}, want: "7ABC123"},
+		5: {vehicle: &uber.Vehicle{Make: " Bugatti", Model: "Veyron ", LicensePlate: "I<3Uber"}, want: "Bugatti Veyron (I<3Uber)"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.vehicle.String(); got != tt.want {
+			t.Errorf("#%d: got=%q want=%q", i, got, tt.want)
+		}
+	}
+}
+
+func TestPIIMasking(t *testing.T) {
+	prof := &uber.Profile{FirstName: "Jane", LastName: "Doe", Email: "jane.doe@example.com", ID: "user-1"}
+	contact := &uber.Contact{
+		FirstName: "Émile", Email: "emile@example.org",
+		Phone: &uber.Phone{Number: "+1 (415) 555-1212", SMSEnabled: true},
+	}
+
+	tests := [...]struct {
+		v            fmt.Stringer
+		unmasked     func() string
+		wantMasked   string
+		wantUnmasked string
+	}{
+		0: {
+			v: prof, unmasked: prof.Unmasked,
+			wantMasked:   `{"first_name":"J***","last_name":"D***","email":"j***@example.com","mobile_verified":false,"uuid":"user-1"}`,
+			wantUnmasked: `{"first_name":"Jane","last_name":"Doe","email":"jane.doe@example.com","mobile_verified":false,"uuid":"user-1"}`,
+		},
+		1: {
+			v: contact, unmasked: contact.Unmasked,
+			wantMasked:   `{"first_name":"É***","email":"e***@example.org","phone":{"number":"+* (***) ***-1212","sms_enabled":true}}`,
+			wantUnmasked: `{"first_name":"Émile","email":"emile@example.org","phone":{"number":"+1 (415) 555-1212","sms_enabled":true}}`,
+		},
+		2: {
+			v: new(uber.Profile), unmasked: new(uber.Profile).Unmasked,
+			wantMasked:   `{"mobile_verified":false}`,
+			wantUnmasked: `{"mobile_verified":false}`,
+		},
+	}
+
+	for i, tt := range tests {
+		if got := fmt.Sprintf("%v", tt.v); got != tt.wantMasked {
+			t.Errorf("#%d: masked:\ngot:  %s\nwant: %s", i, got, tt.wantMasked)
+		}
+		if got := tt.unmasked(); got != tt.wantUnmasked {
+			t.Errorf("#%d: unmasked:\ngot:  %s\nwant: %s", i, got, tt.wantUnmasked)
+		}
+	}
+
+	// The contact mustn't be modified by masking it.
+	if contact.Phone.Number != "+1 (415) 555-1212" || contact.Email != "emile@example.org" {
+		t.Errorf("masking modified the contact: %s", contact.Unmasked())
+	}
+
+	splitWith := "Split fare with jane.doe@example.com (415) 555-1212"
+	receipt := &uber.Receipt{
+		RequestID:         "b5512127-a134-4bf4-b1ba-fe9f48f56d9d",
+		ChargeAdjustments: []*uber.Charge{{Name: splitWith, Type: "split"}},
+	}
+	masked, unmasked := receipt.String(), receipt.Unmasked()
+	if want := `"name":"Split fare with j***@example.com (***) ***-1212"`; !strings.Contains(masked, want) {
+		t.Errorf("receipt: masked %s doesn't have %s", masked, want)
+	}
+	if !strings.Contains(masked, receipt.RequestID) {
+		t.Errorf("receipt: masked %s doesn't have the request ID", masked)
+	}
+	if !strings.Contains(unmasked, splitWith) {
+		t.Errorf("receipt: unmasked %s doesn't have %q", unmasked, splitWith)
+	}
+	if receipt.ChargeAdjustments[0].Name != splitWith {
+		t.Errorf("masking modified the receipt: %s", unmasked)
+	}
+}
+
+func TestErrorBodiesMaskPII(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	body := "invalid request: email=jane.doe@example.com phone=415-555-1212 " + strings.Repeat("x", 1024)
+	client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{code: http.StatusBadRequest, contentType: "text/plain", body: body})
+
+	_, err = client.RetrieveMyProfile()
+	if err == nil {
+		t.Fatal("expected a non-nil error")
+	}
+	errMsg := err.Error()
+	for _, pii := range []string{"jane.doe@example.com", "415-555-1212"} {
+		if strings.Contains(errMsg, pii) {
+			t.Errorf("error %q leaks %q", errMsg, pii)
+		}
+	}
+	if !strings.Contains(errMsg, "j***@example.com") || !strings.Contains(errMsg, "***-***-1212") {
+		t.Errorf("error %q doesn't have the masked details", errMsg)
+	}
+	if len(errMsg) > 300 {
+		t.Errorf("error message of %d bytes wasn't truncated", len(errMsg))
+	}
+}
+
+func TestErrorBodiesMaskOnlyPhoneNumbers(t *testing.T) {
+	tests := [...]struct {
+		body string
+		want string
+	}{
+		0: {body: "call (415) 555-1212", want: "call (***) ***-1212"},
+		1: {body: "call 415.555.1212 now", want: "call ***.***.1212 now"},
+		2: {body: "call 415 555 1212", want: "call *** *** 1212"},
+		3: {body: "call +1-415-555-1212", want: "call +*-***-***-1212"},
+		4: {body: "call +44 20 7946 0958", want: "call +** ** **** 0958"},
+		5: {body: "call +14155551212", want: "call +*******1212"},
+
+		// Timestamps, IDs, dates, addresses and amounts are left as is.
+		6:  {body: "expired at 1502844378", want: "expired at 1502844378"},
+		7:  {body: "no such trip 12345678901234", want: "no such trip 12345678901234"},
+		8:  {body: "no such trip a1111c8c-c720-46c3-8534-2fcdd730040d", want: "no such trip a1111c8c-c720-46c3-8534-2fcdd730040d"},
+		9:  {body: "blocked 192.168.100.200 on 2017-08-15", want: "blocked 192.168.100.200 on 2017-08-15"},
+		10: {body: "owed 1234567.89 (12345678)", want: "owed 1234567.89 (12345678)"},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{code: http.StatusBadRequest, contentType: "text/plain", body: tt.body})
+
+		_, err = client.RetrieveMyProfile()
+		if err == nil {
+			t.Errorf("#%d: expected a non-nil error", i)
+			continue
+		}
+		if got := err.Error(); !strings.Contains(got, tt.want) {
+			t.Errorf("#%d: got %q want it to contain %q", i, got, tt.want)
+		}
+	}
+}
+
+func TestRetrieveMyProfile(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: retrieveProfileRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	invalidToken := fmt.Sprintf("%v", time.Now().Unix())
+
+	tests := [...]struct {
+		wantErr     bool
+		bearerToken string
+		want        *uber.Profile
+	}{
+		0: {
+			bearerToken: testToken1,
+			want:        profileFromFileByToken(testToken1),
+		},
+		1: {
+			bearerToken: invalidToken,
+			wantErr:     true,
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetBearerToken(tt.bearerToken)
+		prof, err := client.RetrieveMyProfile()
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		gotBlob, wantBlob := jsonSerialize(prof), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+// requestIDRoundTripper sets the X-Request-Id header of the
+// responses of base to the successive ids, if any are left.
+type requestIDRoundTripper struct {
+	base http.RoundTripper
+	ids  []string
+}
+
+var _ http.RoundTripper = (*requestIDRoundTripper)(nil)
+
+func (rirt *requestIDRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	res, err := rirt.base.RoundTrip(req)
+	if err != nil || len(rirt.ids) == 0 {
+		return res, err
+	}
+	res.Header.Set("X-Request-Id", rirt.ids[0])
+	rirt.ids = rirt.ids[1:]
+	return res, nil
+}
+
+func TestLastRequestID(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	if got := client.LastRequestID(); got != "" {
+		t.Errorf("before any request: got=%q want an empty request ID", got)
+	}
+
+	backend := &requestIDRoundTripper{
+		base: &tRoundTripper{route: applyPromoCodeRoute},
+		ids:  []string{"req-1", "req-2"},
+	}
+	client.SetHTTPRoundTripper(backend)
+
+	tests := [...]struct {
+		promoCode string
+		wantErr   bool
+		want      string
+	}{
+		0: {promoCode: promoCode1, want: "req-1"},
+		// Failed requests are the ones most needed for tickets.
+		1: {promoCode: "unknown-promo-code", wantErr: true, want: "req-2"},
+		// The next response has no request ID.
+		2: {promoCode: promoCode1, want: ""},
+	}
+
+	for i, tt := range tests {
+		_, err := client.ApplyPromoCode(tt.promoCode)
+		if gotErr := err != nil; gotErr != tt.wantErr {
+			t.Errorf("#%d: gotErr=%v wantErr=%v err=%v", i, gotErr, tt.wantErr, err)
+		}
+		if got := client.LastRequestID(); got != tt.want {
+			t.Errorf("#%d: lastRequestID: got=%q want=%q", i, got, tt.want)
+		}
+	}
+
+	backend.ids = []string{"req-3"}
+	clone := client.Clone()
+	if got := clone.LastRequestID(); got != "" {
+		t.Errorf("clone: got=%q want an empty request ID", got)
+	}
+	if _, err := clone.ApplyPromoCode(promoCode1); err != nil {
+		t.Fatalf("clone: err: %v", err)
+	}
+	if got, want := clone.LastRequestID(), "req-3"; got != want {
+		t.Errorf("clone: got=%q want=%q", got, want)
+	}
+	if got := client.LastRequestID(); got != "" {
+		t.Errorf("the clone's request changed the original's request ID to %q", got)
+	}
+
+	// Calls with options are made on behalf of the client.
+	sandboxed := true
+	client.SetHTTPRoundTripper(&requestIDRoundTripper{
+		base: &tRoundTripper{route: tripByIDRoute},
+		ids:  []string{"req-4"},
+	})
+	if _, err := client.TripByIDWithOptions("a1111c8c-c720-46c3-8534-2fcdd730040d", &uber.RequestOptions{Sandbox: &sandboxed}); err != nil {
+		t.Fatalf("tripByIDWithOptions: err: %v", err)
+	}
+	if got, want := client.LastRequestID(), "req-4"; got != want {
+		t.Errorf("withOptions: got=%q want=%q", got, want)
+	}
+}
+
+func TestApplyPromoCode(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: applyPromoCodeRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	tests := [...]struct {
+		wantErr   bool
+		promoCode string
+		want      *uber.PromoCode
+	}{
+		0: {
+			promoCode: promoCode1,
+			want:      promoCodeFromFileByToken(promoCode1),
+		},
+		1: {
+			// Try with a random promo code that's unauthorized.
+			promoCode: fmt.Sprintf("%v", time.Now().Unix()),
+			wantErr:   true,
+		},
+	}
+
+	for i, tt := range tests {
+		appliedPromoCode, err := client.ApplyPromoCode(tt.promoCode)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		gotBlob, wantBlob := jsonSerialize(appliedPromoCode), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+func TestRequestRide(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: requestRideRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, testingRoundTripper)
+	client.SetHTTPRoundTripper(transport)
+
+	tests := [...]struct {
+		wantErr bool
+		req     *uber.RideRequest
+	}{
+		0: {
+			req:     nil,
+			wantErr: true,
+		},
+		1: {
+			req: &uber.RideRequest{
+				FareID:     "fareID-1",
+				StartPlace: uber.PlaceHome,
+				EndPlace:   uber.PlaceWork,
+			},
+		},
+		2: {
+			req: &uber.RideRequest{
+				StartPlace: uber.PlaceHome,
+				EndPlace:   uber.PlaceWork,
+				PromptOnFare: func(fare *uber.UpfrontFare) error {
+					if fare.Fare.Value >= 0.90 {
+						return fmt.Errorf("times are hard, not paying more than 90 cents!")
+					}
+					return nil
+				},
+			},
+			wantErr: true,
+		},
+	}
+
+	blankRide := new(uber.Ride)
+	for i, tt := range tests {
+		ride, err := client.RequestRide(tt.req)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d expecting a non-nil error", i)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		if ride == nil {
+			t.Errorf("#%d: expecting non-nil ride", i)
+			continue
+		}
+
+		if reflect.DeepEqual(blankRide, ride) {
+			t.Errorf("#%d: expecting a non-blank ride response", i)
+		}
+	}
+}
+
+// recordingRoundTripper records the method, path, query, headers
+// and body of every request before passing it on to its base.
+type recordingRoundTripper struct {
+	sync.Mutex
+	base     http.RoundTripper
+	requests []string
+	queries  []url.Values
+	headers  []http.Header
+	bodies   [][]byte
+	hosts    []string
+}
+
+var _ http.RoundTripper = (*recordingRoundTripper)(nil)
+
+func (rrt *recordingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	rrt.Lock()
+	rrt.requests = append(rrt.requests, req.Method+" "+req.URL.Path)
+	rrt.queries = append(rrt.queries, req.URL.Query())
+	var body []byte
+	if req.Body != nil {
+		body, _ = ioutil.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	rrt.bodies = append(rrt.bodies, body)
+	rrt.headers = append(rrt.headers, req.Header)
+	rrt.hosts = append(rrt.hosts, req.URL.Host)
+	rrt.Unlock()
+	return rrt.base.RoundTrip(req)
+}
+
+func TestRequestRideWithFareIDSkipsEstimate(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: requestRideRoute}}
+	client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+	promptCalled := false
+	ride, err := client.RequestRide(&uber.RideRequest{
+		FareID:     "fareID-1",
+		StartPlace: uber.PlaceHome,
+		EndPlace:   uber.PlaceWork,
+		PromptOnFare: func(fare *uber.UpfrontFare) error {
+			promptCalled = true
+			return nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+	if ride == nil {
+		t.Fatal("expecting a non-nil ride")
+	}
+	if promptCalled {
+		t.Error("PromptOnFare must not be invoked when FareID is set")
+	}
+
+	want := []string{"POST /v1.2/requests"}
+	if g, w := recorder.requests, want; !reflect.DeepEqual(g, w) {
+		t.Errorf("requests:\ngot:  %q\nwant: %q", g, w)
+	}
+}
+
+func TestRequestRideWithWaypoints(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	tests := [...]struct {
+		waypoints []*uber.Waypoint
+		wantErr   bool
+		// want is the expected "waypoints" of the body,
+		// nil if the body must not contain any.
+		want []interface{}
+	}{
+		0: {waypoints: nil},
+		1: {waypoints: []*uber.Waypoint{}},
+		2: {
+			waypoints: []*uber.Waypoint{
+				{Latitude: 37.7752315, Longitude: -122.418075},
+				{Place: uber.PlaceWork},
+			},
+			want: []interface{}{
+				map[string]interface{}{"latitude": 37.7752315, "longitude": -122.418075},
+				map[string]interface{}{"place_id": "work"},
+			},
+		},
+		3: {waypoints: []*uber.Waypoint{nil}, wantErr: true},
+		4: {waypoints: []*uber.Waypoint{{}}, wantErr: true},
+		5: {waypoints: []*uber.Waypoint{{Place: "gym"}}, wantErr: true},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: requestRideRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		_, err := client.RequestRide(&uber.RideRequest{
+			FareID:     "fareID-1",
+			StartPlace: uber.PlaceHome,
+			EndPlace:   uber.PlaceWork,
+			Waypoints:  tt.waypoints,
+		})
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expecting a non-nil error", i)
+			}
+			if len(recorder.bodies) != 0 {
+				t.Errorf("#%d: an invalid request must not be sent", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if len(recorder.bodies) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.bodies))
+			continue
+		}
+
+		body := make(map[string]interface{})
+		if err := json.Unmarshal(recorder.bodies[0], &body); err != nil {
+			t.Errorf("#%d: unmarshaling body: %v", i, err)
+			continue
+		}
+		got, present := body["waypoints"]
+		if tt.want == nil {
+			if present {
+				t.Errorf("#%d: unexpected waypoints: %v", i, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("#%d: waypoints:\ngot:  %v\nwant: %v", i, got, tt.want)
+		}
+	}
+}
+
+func TestStatusTerminalAndActive(t *testing.T) {
+	tests := [...]struct {
+		status       uber.Status
+		wantTerminal bool
+		wantActive   bool
+	}{
+		0:  {status: uber.StatusProcessing, wantActive: true},
+		1:  {status: uber.StatusAccepted, wantActive: true},
+		2:  {status: uber.StatusArriving, wantActive: true},
+		3:  {status: uber.StatusInProgress, wantActive: true},
+		4:  {status: uber.StatusCompleted, wantTerminal: true},
+		5:  {status: uber.StatusDriverCanceled, wantTerminal: true},
+		6:  {status: uber.StatusRiderCanceled, wantTerminal: true},
+		7:  {status: uber.StatusNoDriversAvailable, wantTerminal: true},
+		8:  {status: uber.StatusReceiptReady},
+		9:  {status: uber.StatusEnRouteToDropoff, wantActive: true},
+		10: {status: ""},
+		11: {status: "unknown"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.status.IsTerminal(); got != tt.wantTerminal {
+			t.Errorf("#%d: %q.IsTerminal()=%v want %v", i, tt.status, got, tt.wantTerminal)
+		}
+		if got := tt.status.IsActive(); got != tt.wantActive {
+			t.Errorf("#%d: %q.IsActive()=%v want %v", i, tt.status, got, tt.wantActive)
+		}
+	}
+}
+
+func TestRequestRideNoteForDriver(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	longestNote := strings.Repeat("é", uber.MaxNoteForDriverLength)
+	tests := [...]struct {
+		note    string
+		wantErr error
+	}{
+		0: {note: ""},
+		1: {note: "I'm by the blue door on the corner"},
+		// The limit is in characters, not bytes.
+		2: {note: longestNote},
+		3: {note: longestNote + "!", wantErr: uber.ErrNoteForDriverTooLong},
+	}
+
+	for i, tt := range tests {
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: requestRideRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+
+		_, err := client.RequestRide(&uber.RideRequest{
+			FareID:        "fareID-1",
+			StartPlace:    uber.PlaceHome,
+			EndPlace:      uber.PlaceWork,
+			NoteForDriver: tt.note,
+		})
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			if len(recorder.bodies) != 0 {
+				t.Errorf("#%d: an invalid request must not be sent", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: unexpected err: %v", i, err)
+			continue
+		}
+		if len(recorder.bodies) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.bodies))
+			continue
+		}
+
+		body := make(map[string]interface{})
+		if err := json.Unmarshal(recorder.bodies[0], &body); err != nil {
+			t.Errorf("#%d: unmarshaling body: %v", i, err)
+			continue
+		}
+		got, present := body["note_for_driver"]
+		if tt.note == "" {
+			if present {
+				t.Errorf("#%d: unexpected note_for_driver: %v", i, got)
+			}
+			continue
+		}
+		if got != tt.note {
+			t.Errorf("#%d: note_for_driver: got=%q want=%q", i, got, tt.note)
+		}
+	}
+}
+
+func TestNilRequests(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+	recorder := &recordingRoundTripper{base: &bodyTrackingRoundTripper{code: http.StatusInternalServerError}}
+	client.SetHTTPRoundTripper(recorder)
+
+	// Methods for which a nil argument means the defaults,
+	// such as ListHistory or ListDriverTrips, aren't listed.
+	tests := [...]struct {
+		name string
+		call func() error
+	}{
+		0:  {"RequestRide", func() error { _, err := client.RequestRide(nil); return err }},
+		1:  {"RequestRideWithOptions", func() error { _, err := client.RequestRideWithOptions(nil, nil); return err }},
+		2:  {"UpdateRideDestination", func() error { return client.UpdateRideDestination(requestID1, nil) }},
+		3:  {"EstimatePrice", func() error { _, _, err := client.EstimatePrice(nil); return err }},
+		4:  {"EstimatePriceStream", func() error { _, err := client.EstimatePriceStream(nil); return err }},
+		5:  {"EstimateRoundTrip", func() error { _, err := client.EstimateRoundTrip(nil, nil); return err }},
+		6:  {"EstimateTime", func() error { _, _, err := client.EstimateTime(nil); return err }},
+		7:  {"UpfrontFare", func() error { _, err := client.UpfrontFare(nil); return err }},
+		8:  {"FareWithProduct", func() error { _, _, err := client.FareWithProduct(nil); return err }},
+		9:  {"ProductsWithPricing", func() error { _, err := client.ProductsWithPricing(nil); return err }},
+		10: {"ListProducts", func() error { _, err := client.ListProducts(nil); return err }},
+		11: {"ProductsWithETA", func() error { _, err := client.ProductsWithETA(nil); return err }},
+		12: {"SmallestProductForParty", func() error { _, err := client.SmallestProductForParty(nil, 2); return err }},
+		13: {"DriversAvailable", func() error { _, err := client.DriversAvailable(nil); return err }},
+		14: {"UpdatePlace", func() error { _, err := client.UpdatePlace(nil); return err }},
+		15: {"RequestDelivery", func() error { _, err := client.RequestDelivery(nil); return err }},
+		16: {"UpdateEnrollmentByID", func() error { _, err := client.UpdateEnrollmentByID("enrollment-1", nil); return err }},
+		17: {"EstimatePrices", func() error {
+			results, err := client.EstimatePrices([]*uber.EstimateRequest{nil})
+			if err == nil && len(results) == 1 {
+				err = results[0].Err
+			}
+			return err
+		}},
+	}
+
+	for i, tt := range tests {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					t.Errorf("#%d: %s: panicked with a nil argument: %v", i, tt.name, r)
+				}
+			}()
+			if err := tt.call(); err == nil {
+				t.Errorf("#%d: %s: expecting a non-nil error", i, tt.name)
+			}
+		}()
+	}
+
+	if len(recorder.requests) != 0 {
+		t.Errorf("no requests should be made, got %q", recorder.requests)
+	}
+}
+
+func TestRideRequestValidate(t *testing.T) {
+	tests := [...]struct {
+		req     *uber.RideRequest
+		wantErr error
+	}{
+		0: {req: nil, wantErr: uber.ErrInvalidFareID},
+		1: {req: &uber.RideRequest{StartPlace: uber.PlaceHome, EndPlace: uber.PlaceWork}, wantErr: uber.ErrInvalidFareID},
+		2: {req: &uber.RideRequest{FareID: "f1", StartPlace: uber.PlaceHome, EndPlace: uber.PlaceWork}},
+		3: {
+			req: &uber.RideRequest{
+				FareID:        "f1",
+				StartLatitude: 37.7752315, StartLongitude: -122.418075,
+				EndLatitude: 37.7752415, EndLongitude: -122.518075,
+			},
+		},
+		4: {
+			req: &uber.RideRequest{
+				FareID: "f1", StartPlace: uber.PlaceWork,
+				EndLatitude: 37.7752415, EndLongitude: -122.518075,
+			},
+		},
+		5: {
+			// Both a start place and start coordinates.
+			req: &uber.RideRequest{
+				FareID: "f1", StartPlace: uber.PlaceHome, StartLatitude: 37.7752315, StartLongitude: -122.418075,
+				EndPlace: uber.PlaceWork,
+			},
+			wantErr: uber.ErrAmbiguousStartPlaceAndCoords,
+		},
+		6: {
+			// Even a single start coordinate conflicts with a start place.
+			req: &uber.RideRequest{
+				FareID: "f1", StartPlace: uber.PlaceHome, StartLongitude: -122.418075,
+				EndPlace: uber.PlaceWork,
+			},
+			wantErr: uber.ErrAmbiguousStartPlaceAndCoords,
+		},
+		7: {
+			// Both an end place and end coordinates.
+			req: &uber.RideRequest{
+				FareID: "f1", StartPlace: uber.PlaceHome,
+				EndPlace: uber.PlaceWork, EndLatitude: 37.7752415, EndLongitude: -122.518075,
+			},
+			wantErr: uber.ErrAmbiguousEndPlaceAndCoords,
+		},
+		8: {
+			// Neither a start place nor start coordinates.
+			req:     &uber.RideRequest{FareID: "f1", EndPlace: uber.PlaceWork},
+			wantErr: uber.ErrInvalidStartPlaceOrCoords,
+		},
+		9: {
+			// Neither an end place nor end coordinates.
+			req:     &uber.RideRequest{FareID: "f1", StartPlace: uber.PlaceHome},
+			wantErr: uber.ErrInvalidEndPlaceOrCoords,
+		},
+		10: {
+			// Only home and work are known places.
+			req:     &uber.RideRequest{FareID: "f1", StartPlace: "gym", EndPlace: uber.PlaceWork},
+			wantErr: uber.ErrInvalidStartPlaceOrCoords,
+		},
+		11: {
+			req:     &uber.RideRequest{FareID: "f1", StartPlace: uber.PlaceHome, EndPlace: "gym"},
+			wantErr: uber.ErrInvalidEndPlaceOrCoords,
+		},
+	}
+
+	for i, tt := range tests {
+		if err := tt.req.Validate(); err != tt.wantErr {
+			t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSetFollowFareRedirect(t *testing.T) {
+	tests := [...]struct {
+		follow       bool
+		wantErr      error
+		wantPrompt   bool
+		wantRequests []string
+	}{
+		0: {
+			follow:       true,
+			wantPrompt:   true,
+			wantRequests: []string{"POST /v1.2/requests/estimate", "POST /v1.2/requests"},
+		},
+		1: {
+			// Without the redirect, the ride can't be
+			// requested since it doesn't have a fare.
+			follow:  false,
+			wantErr: uber.ErrInvalidFareID,
+		},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: requestRideRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+		client.SetFollowFareRedirect(tt.follow)
+
+		prompted := false
+		_, err = client.RequestRide(&uber.RideRequest{
+			StartLatitude:  37.7752315,
+			StartLongitude: -122.418075,
+			EndLatitude:    37.7752415,
+			EndLongitude:   -122.518075,
+			PromptOnFare: func(*uber.UpfrontFare) error {
+				prompted = true
+				return nil
+			},
+		})
+		if err != tt.wantErr {
+			t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+		}
+		if prompted != tt.wantPrompt {
+			t.Errorf("#%d: prompted=%v wantPrompt=%v", i, prompted, tt.wantPrompt)
+		}
+		if !reflect.DeepEqual(recorder.requests, tt.wantRequests) {
+			t.Errorf("#%d: requests:\ngot:  %q\nwant: %q", i, recorder.requests, tt.wantRequests)
+		}
+	}
+}
+
+var uuidV4RE = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestIdempotencyKey(t *testing.T) {
+	rideRequest := func(key string) func(c *uber.Client) error {
+		return func(c *uber.Client) error {
+			_, err := c.RequestRide(&uber.RideRequest{
+				FareID:         "fareID-1",
+				StartPlace:     uber.PlaceHome,
+				EndPlace:       uber.PlaceWork,
+				IdempotencyKey: key,
+			})
+			return err
+		}
+	}
+	deliveryRequest := func(key string) func(c *uber.Client) error {
+		return func(c *uber.Client) error {
+			endpoint := &uber.Endpoint{
+				Contact:  &uber.Contact{CompanyName: "orijtech"},
+				Location: &uber.Location{PrimaryAddress: "530 W 113th Street", Country: "US"},
 			}
-			pageCount += 1
-			itemCount += len(page.Payments)
+			_, err := c.RequestDelivery(&uber.DeliveryRequest{
+				Pickup:         endpoint,
+				Dropoff:        endpoint,
+				Items:          []*uber.Item{{Title: "phone chargers", Quantity: 1}},
+				IdempotencyKey: key,
+			})
+			return err
 		}
-		if g, w := itemCount, tt.wantItemCount; g != w {
-			t.Errorf("#%d: itemCount:: got=%d want=%d", i, g, w)
+	}
+
+	tests := [...]struct {
+		route string
+		auto  bool
+		do    func(c *uber.Client) error
+
+		want string
+		// wantUUID if set expects a generated key.
+		wantUUID bool
+	}{
+		0: {route: requestRideRoute, do: rideRequest("ride-key-1"), want: "ride-key-1"},
+		1: {route: requestRideRoute, do: rideRequest("")},
+		2: {route: requestRideRoute, auto: true, do: rideRequest(""), wantUUID: true},
+		3: {route: requestRideRoute, auto: true, do: rideRequest("ride-key-2"), want: "ride-key-2"},
+		4: {route: deliveryRoute, do: deliveryRequest("delivery-key-1"), want: "delivery-key-1"},
+		5: {route: deliveryRoute, auto: true, do: deliveryRequest(""), wantUUID: true},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
 		}
-		if g, w := pageCount, tt.wantPageCount; g != w {
-			t.Errorf("#%d: pageCount:: got=%d want=%d", i, g, w)
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: tt.route}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
+		client.SetAutoIdempotency(tt.auto)
+
+		// Make the request twice to ensure that
+		// generated keys are unique per call.
+		for j := 0; j < 2; j++ {
+			if err := tt.do(client); err != nil {
+				t.Errorf("#%d: call #%d: unexpected err: %v", i, j, err)
+			}
+		}
+		if len(recorder.headers) != 2 {
+			t.Errorf("#%d: got %d requests want 2", i, len(recorder.headers))
+			continue
+		}
+
+		keys := []string{
+			recorder.headers[0].Get("Idempotency-Key"),
+			recorder.headers[1].Get("Idempotency-Key"),
+		}
+		if !tt.wantUUID {
+			for j, key := range keys {
+				if key != tt.want {
+					t.Errorf("#%d: call #%d: got key=%q want=%q", i, j, key, tt.want)
+				}
+			}
+			continue
+		}
+		for j, key := range keys {
+			if !uuidV4RE.MatchString(key) {
+				t.Errorf("#%d: call #%d: got key=%q want a UUID", i, j, key)
+			}
+		}
+		if keys[0] == keys[1] {
+			t.Errorf("#%d: expecting a different key per call, got %q twice", i, keys[0])
 		}
 	}
 }
 
-func TestListDriverTrips(t *testing.T) {
+const outOfAreaRideID = "out-of-area-ride"
+
+func TestUpdateRideDestination(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
 
-	backend := &tRoundTripper{route: listDriverTripsRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
+	testingRoundTripper := &tRoundTripper{route: updateRideDestinationRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, testingRoundTripper)
 	client.SetHTTPRoundTripper(transport)
 
 	tests := [...]struct {
-		req           *uber.DriverInfoQuery
-		wantPageCount int
-		wantItemCount int
-		wantErr       bool
+		requestID string
+		update    *uber.DestinationUpdate
+		wantErr   error
 	}{
 		0: {
-			req:           nil, // No page limit, pagination all of them
-			wantPageCount: 4,
-			wantItemCount: 10,
+			requestID: ride1,
+			update:    &uber.DestinationUpdate{EndPlace: uber.PlaceWork},
 		},
 		1: {
-			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 1},
-			wantPageCount: 1,
-			wantItemCount: 2,
+			requestID: ride1,
+			update:    &uber.DestinationUpdate{EndLatitude: 37.7752415, EndLongitude: -122.518075},
 		},
 		2: {
-			req:           &uber.DriverInfoQuery{LimitPerPage: 2, MaxPageNumber: 3},
-			wantPageCount: 3,
-			wantItemCount: 6,
+			// Blank requestID.
+			requestID: "   ",
+			update:    &uber.DestinationUpdate{EndPlace: uber.PlaceWork},
+			wantErr:   errAny,
+		},
+		3: {
+			requestID: ride1,
+			update:    nil,
+			wantErr:   errAny,
+		},
+		4: {
+			// Neither a place nor coordinates.
+			requestID: ride1,
+			update:    &uber.DestinationUpdate{},
+			wantErr:   errAny,
+		},
+		5: {
+			// Both a place and coordinates.
+			requestID: ride1,
+			update: &uber.DestinationUpdate{
+				EndPlace:    uber.PlaceHome,
+				EndLatitude: 37.7752415, EndLongitude: -122.518075,
+			},
+			wantErr: uber.ErrAmbiguousEndPlaceAndCoords,
+		},
+		6: {
+			// Unknown place.
+			requestID: ride1,
+			update:    &uber.DestinationUpdate{EndPlace: "gym"},
+			wantErr:   errAny,
+		},
+		7: {
+			requestID: outOfAreaRideID,
+			update:    &uber.DestinationUpdate{EndPlace: uber.PlaceHome},
+			wantErr:   uber.ErrDestinationOutsideServiceArea,
 		},
 	}
 
 	for i, tt := range tests {
-		if tt.req != nil {
-			tt.req.Throttle = uber.NoThrottle
+		err := client.UpdateRideDestination(tt.requestID, tt.update)
+		switch {
+		case tt.wantErr == nil:
+			if err != nil {
+				t.Errorf("#%d: unexpected err: %v", i, err)
+			}
+		case tt.wantErr == errAny:
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error", i)
+			}
+		case err != tt.wantErr:
+			t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
 		}
-		dres, err := client.ListDriverTrips(tt.req)
+	}
+
+	// Null errors in an error response mustn't cause a panic.
+	client.SetHTTPRoundTripper(&bodyTrackingRoundTripper{code: http.StatusConflict, contentType: "application/json", body: `{"errors":[null]}`})
+	if err := client.UpdateRideDestination(ride1, &uber.DestinationUpdate{EndPlace: uber.PlaceHome}); err == nil {
+		t.Errorf("null errors: expected a non-nil error")
+	}
+}
+
+const (
+	requestID1 = "b5512127-a134-4bf4-b1ba-fe9f48f56d9d"
+)
+
+func TestPlaceRetrieval(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: getPlacesRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	tests := [...]struct {
+		wantErr bool
+		place   uber.PlaceName
+		want    *uber.Place
+	}{
+		0: {
+			place: "home",
+			want:  placeFromFile("685-market"),
+		},
+		1: {
+			place: "work",
+			want:  placeFromFile("wallaby-way"),
+		},
+		2: {
+			place:   "workz",
+			wantErr: true,
+		},
+		3: {
+			place:   "",
+			wantErr: true,
+		},
+	}
+
+	for i, tt := range tests {
+		place, err := client.Place(tt.place)
 		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d: want non-nil error", i)
+				t.Errorf("#%d expecting a non-nil error", i)
 			}
 			continue
 		}
 
 		if err != nil {
-			t.Errorf("#%d: unexpected err: %v", i, err)
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		gotBlob, wantBlob := jsonSerialize(place), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
+type tGeocoder map[string][2]float64
+
+var _ uber.Geocoder = (tGeocoder)(nil)
+
+func (tg tGeocoder) Geocode(address string) (float64, float64, error) {
+	coords, ok := tg[address]
+	if !ok {
+		return 0, 0, fmt.Errorf("no coordinates for %q", address)
+	}
+	return coords[0], coords[1], nil
+}
+
+func TestPlaceCoordinates(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	testingRoundTripper := &tRoundTripper{route: getPlacesRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	marketGeocoder := tGeocoder{
+		"685 Market St, San Francisco, CA 94103, USA": {37.7873, -122.4037},
+	}
+
+	tests := [...]struct {
+		place    uber.PlaceName
+		geocoder uber.Geocoder
+
+		wantCoords bool
+		wantLat    float64
+		wantLon    float64
+	}{
+		0: {
+			// Address only and no geocoder.
+			place: uber.PlaceHome,
+		},
+		1: {
+			// Uber sent back the coordinates.
+			place:      uber.PlaceWork,
+			wantCoords: true,
+			wantLat:    -33.8688,
+			wantLon:    151.2093,
+		},
+		2: {
+			// Address only but resolved by the geocoder.
+			place:      uber.PlaceHome,
+			geocoder:   marketGeocoder,
+			wantCoords: true,
+			wantLat:    37.7873,
+			wantLon:    -122.4037,
+		},
+		3: {
+			// Geocoding failures leave the place as is.
+			place:    uber.PlaceHome,
+			geocoder: tGeocoder{},
+		},
+		4: {
+			// The geocoder must not override Uber's coordinates.
+			place:      uber.PlaceWork,
+			geocoder:   marketGeocoder,
+			wantCoords: true,
+			wantLat:    -33.8688,
+			wantLon:    151.2093,
+		},
+	}
+
+	for i, tt := range tests {
+		client.SetGeocoder(tt.geocoder)
+		place, err := client.Place(tt.place)
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+
+		if got, want := place.HasCoordinates(), tt.wantCoords; got != want {
+			t.Errorf("#%d: hasCoordinates: got=%v want=%v", i, got, want)
+			continue
+		}
+		if place.Latitude != tt.wantLat || place.Longitude != tt.wantLon {
+			t.Errorf("#%d: coordinates: got=(%v, %v) want=(%v, %v)",
+				i, place.Latitude, place.Longitude, tt.wantLat, tt.wantLon)
+		}
+	}
+}
+
+func TestResolvePlace(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	recorder := &recordingRoundTripper{base: &tRoundTripper{route: getPlacesRoute}}
+	client.SetHTTPRoundTripper(recorder)
+
+	marketGeocoder := tGeocoder{
+		"685 Market St, San Francisco, CA 94103, USA": {37.7873, -122.4037},
+	}
+
+	tests := [...]struct {
+		place    uber.PlaceName
+		geocoder uber.Geocoder
+
+		wantLat      float64
+		wantLon      float64
+		wantErr      error
+		wantRequests int
+	}{
+		0: {place: uber.PlaceWork, wantLat: -33.8688, wantLon: 151.2093, wantRequests: 1},
+		1: {place: uber.PlaceHome, geocoder: marketGeocoder, wantLat: 37.7873, wantLon: -122.4037, wantRequests: 1},
+		2: {place: uber.PlaceHome, wantErr: uber.ErrPlaceWithoutCoordinates, wantRequests: 1},
+
+		// Invalid names are rejected without a request.
+		3: {place: "", wantErr: errAny},
+		4: {place: "gym", wantErr: errAny},
+	}
+
+	for i, tt := range tests {
+		recorder.requests = nil
+		client.SetGeocoder(tt.geocoder)
+
+		lat, lon, err := client.ResolvePlace(tt.place)
+		if got := len(recorder.requests); got != tt.wantRequests {
+			t.Errorf("#%d: got %d requests want %d", i, got, tt.wantRequests)
+		}
+		if tt.wantErr != nil {
+			if err == nil || (tt.wantErr != errAny && err != tt.wantErr) {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
+		if lat != tt.wantLat || lon != tt.wantLon {
+			t.Errorf("#%d: coordinates: got=(%v, %v) want=(%v, %v)", i, lat, lon, tt.wantLat, tt.wantLon)
+		}
+	}
+}
+
+// etagRoundTripper tags the responses of base with etag and
+// responds with 304 Not Modified to requests that match it.
+type etagRoundTripper struct {
+	sync.Mutex
+	base http.RoundTripper
+	etag string
+
+	ifNoneMatches []string
+}
+
+func (ert *etagRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ifNoneMatch := req.Header.Get("If-None-Match")
+	ert.Lock()
+	ert.ifNoneMatches = append(ert.ifNoneMatches, ifNoneMatch)
+	ert.Unlock()
+
+	if ifNoneMatch == ert.etag {
+		resp := makeResp(http.StatusText(http.StatusNotModified), http.StatusNotModified)
+		resp.Header.Set("ETag", ert.etag)
+		return resp, nil
+	}
+	resp, err := ert.base.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		resp.Header.Set("ETag", ert.etag)
+	}
+	return resp, err
+}
+
+func TestETagCaching(t *testing.T) {
+	tests := [...]struct {
+		name    string
+		route   string
+		caching bool
+		get     func(c *uber.Client) (interface{}, error)
+		want    interface{}
+	}{
+		0: {
+			name: "profile", route: retrieveProfileRoute, caching: true,
+			get:  func(c *uber.Client) (interface{}, error) { return c.RetrieveMyProfile() },
+			want: profileFromFileByToken(testToken1),
+		},
+		1: {
+			name: "place", route: getPlacesRoute, caching: true,
+			get:  func(c *uber.Client) (interface{}, error) { return c.Place(uber.PlaceHome) },
+			want: placeFromFile("685-market"),
+		},
+		2: {
+			name: "profile without caching", route: retrieveProfileRoute,
+			get:  func(c *uber.Client) (interface{}, error) { return c.RetrieveMyProfile() },
+			want: profileFromFileByToken(testToken1),
+		},
+	}
 
-		if dres == nil {
-			t.Errorf("#%d: expecting non-nil delivery response", i)
-			continue
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
 		}
-
-		itemCount := 0
-		pageCount := 0
-		for page := range dres.Pages {
-			if page.Err != nil {
-				t.Errorf("#%d: err: %v", i, page.Err)
+		ert := &etagRoundTripper{base: &tRoundTripper{route: tt.route}, etag: `"v1"`}
+		client.SetHTTPRoundTripper(ert)
+		client.SetETagCaching(tt.caching)
+
+		wantBlob := jsonSerialize(tt.want)
+		for j := 0; j < 2; j++ {
+			got, err := tt.get(client)
+			if err != nil {
+				t.Errorf("#%d: %s: request #%d: err: %v", i, tt.name, j, err)
 				continue
 			}
-			pageCount += 1
-			itemCount += len(page.Trips)
+			if gotBlob := jsonSerialize(got); !bytes.Equal(gotBlob, wantBlob) {
+				t.Errorf("#%d: %s: request #%d:\ngot:  %s\nwant: %s", i, tt.name, j, gotBlob, wantBlob)
+			}
 		}
-		if g, w := itemCount, tt.wantItemCount; g != w {
-			t.Errorf("#%d: itemCount:: got=%d want=%d", i, g, w)
+
+		// Only the second request of a cached response is conditional.
+		wantIfNoneMatches := []string{"", ""}
+		if tt.caching {
+			wantIfNoneMatches[1] = ert.etag
 		}
-		if g, w := pageCount, tt.wantPageCount; g != w {
-			t.Errorf("#%d: pageCount:: got=%d want=%d", i, g, w)
+		if !reflect.DeepEqual(ert.ifNoneMatches, wantIfNoneMatches) {
+			t.Errorf("#%d: %s: If-None-Match: got=%q want=%q", i, tt.name, ert.ifNoneMatches, wantIfNoneMatches)
 		}
 	}
 }
 
-func TestListDeliveries(t *testing.T) {
-	t.Skipf("Need to get ListDelivery samples from Uber")
-
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
-	}
-
-	backend := &tRoundTripper{route: listDeliveriesRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, backend)
-	client.SetHTTPRoundTripper(transport)
-
+func TestListPlaces(t *testing.T) {
 	tests := [...]struct {
-		req         *uber.DeliveryListRequest
-		wantAtLeast int
-		wantErr     bool
+		failures   map[uber.PlaceName]int
+		want       map[uber.PlaceName]*uber.Place
+		wantErrFor []uber.PlaceName
 	}{
 		0: {
-			req: &uber.DeliveryListRequest{},
+			want: map[uber.PlaceName]*uber.Place{
+				uber.PlaceHome: placeFromFile("685-market"),
+				uber.PlaceWork: placeFromFile("wallaby-way"),
+			},
 		},
 		1: {
-			req: nil,
+			// An unset place is left out without an error.
+			failures: map[uber.PlaceName]int{uber.PlaceWork: http.StatusNotFound},
+			want: map[uber.PlaceName]*uber.Place{
+				uber.PlaceHome: placeFromFile("685-market"),
+			},
 		},
 		2: {
-			req: &uber.DeliveryListRequest{
-				LimitPerPage:  4,
-				MaxPageNumber: 2,
-				StartOffset:   10,
+			failures: map[uber.PlaceName]int{uber.PlaceHome: http.StatusInternalServerError},
+			want: map[uber.PlaceName]*uber.Place{
+				uber.PlaceWork: placeFromFile("wallaby-way"),
 			},
-			wantAtLeast: 8,
+			wantErrFor: []uber.PlaceName{uber.PlaceHome},
+		},
+		3: {
+			failures: map[uber.PlaceName]int{
+				uber.PlaceHome: http.StatusNotFound,
+				uber.PlaceWork: http.StatusInternalServerError,
+			},
+			want:       map[uber.PlaceName]*uber.Place{},
+			wantErrFor: []uber.PlaceName{uber.PlaceWork},
 		},
 	}
 
 	for i, tt := range tests {
-		dres, err := client.ListDeliveries(tt.req)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d: want non-nil error", i)
-			}
-			continue
-		}
-
+		client, err := uber.NewClient(testToken1)
 		if err != nil {
-			t.Errorf("#%d: unexpected err: %v", i, err)
-			continue
-		}
-
-		if dres == nil {
-			t.Errorf("#%d: expecting non-nil delivery response", i)
+			t.Errorf("#%d: initializing client; %v", i, err)
 			continue
 		}
+		client.SetHTTPRoundTripper(&tRoundTripper{route: listPlacesRoute, exhaust: tt.failures})
 
-		itemCount := 0
-		for page := range dres.Pages {
-			if page.Err != nil {
-				t.Errorf("#%d: err: %v", i, page.Err)
+		places, err := client.ListPlaces()
+		if len(tt.wantErrFor) == 0 {
+			if err != nil {
+				t.Errorf("#%d: unexpected err: %v", i, err)
+			}
+		} else {
+			placesErr, ok := err.(uber.PlacesError)
+			if !ok {
+				t.Errorf("#%d: got err=%#v want a PlacesError", i, err)
 				continue
 			}
-
-			itemCount += len(page.Deliveries)
+			if g, w := len(placesErr), len(tt.wantErrFor); g != w {
+				t.Errorf("#%d: got %d place errors want %d: %v", i, g, w, placesErr)
+			}
+			for _, placeName := range tt.wantErrFor {
+				if placesErr[placeName] == nil {
+					t.Errorf("#%d: expecting an error for %q", i, placeName)
+				}
+			}
 		}
-		if itemCount < tt.wantAtLeast {
-			t.Errorf("#%d: got=%d wantAtLeast=%d", i, itemCount, tt.wantAtLeast)
+
+		gotBlob, wantBlob := jsonSerialize(places), jsonSerialize(tt.want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
 		}
 	}
 }
 
-type sandboxState string
-
-const (
-	sandboxUnknown    sandboxState = "unknown"
-	sandboxSandbox    sandboxState = "sandboxed"
-	sandboxProduction sandboxState = "production"
-)
-
-func TestClientSandboxing(t *testing.T) {
+func TestBootstrap(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
 
-	backend := &tRoundTripper{route: sandboxTesterRoute}
-	client.SetHTTPRoundTripper(backend)
+	testingRoundTripper := &tRoundTripper{route: bootstrapRoute}
+	client.SetHTTPRoundTripper(testingRoundTripper)
 
-	tests := [...]struct {
-		do func(c *uber.Client)
+	// A nil place makes the products section fail
+	// while the rest of the sections must succeed.
+	bs, err := client.Bootstrap(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
 
-		sandboxed bool
-		want      sandboxState
-	}{
+	if bs.ProfileErr != nil {
+		t.Errorf("profile: unexpected err: %v", bs.ProfileErr)
+	}
+	gotBlob, wantBlob := jsonSerialize(bs.Profile), jsonSerialize(profileFromFileByToken(testToken1))
+	if !bytes.Equal(gotBlob, wantBlob) {
+		t.Errorf("profile:\ngot:  %s\nwant: %s", gotBlob, wantBlob)
+	}
 
-		0: {
-			want:      sandboxProduction,
-			sandboxed: false,
+	if bs.PaymentMethodsErr != nil {
+		t.Errorf("paymentMethods: unexpected err: %v", bs.PaymentMethodsErr)
+	}
+	gotBlob, wantBlob = jsonSerialize(bs.PaymentMethods), jsonSerialize(paymentListingFromFile("./fakeserver/fixtures/payment-methods.json"))
+	if !bytes.Equal(gotBlob, wantBlob) {
+		t.Errorf("paymentMethods:\ngot:  %s\nwant: %s", gotBlob, wantBlob)
+	}
 
-			do: func(c *uber.Client) {
-				resChan, _, _ := c.EstimatePrice(&uber.EstimateRequest{
-					StartLatitude:  37.7752315,
-					EndLatitude:    37.7752415,
-					StartLongitude: -122.418075,
-					EndLongitude:   -122.518075,
-				})
-				for res := range resChan {
-					if false {
-						t.Logf("res: %#v\n", res)
-					}
-				}
-			},
-		},
-		1: {
-			want:      sandboxSandbox,
-			sandboxed: true,
+	if bs.HomeErr != nil {
+		t.Errorf("home: unexpected err: %v", bs.HomeErr)
+	}
+	gotBlob, wantBlob = jsonSerialize(bs.Home), jsonSerialize(placeFromFile("685-market"))
+	if !bytes.Equal(gotBlob, wantBlob) {
+		t.Errorf("home:\ngot:  %s\nwant: %s", gotBlob, wantBlob)
+	}
 
-			do: func(c *uber.Client) {
-				_, _ = c.RequestRide(&uber.RideRequest{
-					FareID:     "fareID-1",
-					StartPlace: uber.PlaceHome,
-					EndPlace:   uber.PlaceWork,
-				})
-			},
-		},
+	if bs.WorkErr != nil {
+		t.Errorf("work: unexpected err: %v", bs.WorkErr)
+	}
+	gotBlob, wantBlob = jsonSerialize(bs.Work), jsonSerialize(placeFromFile("wallaby-way"))
+	if !bytes.Equal(gotBlob, wantBlob) {
+		t.Errorf("work:\ngot:  %s\nwant: %s", gotBlob, wantBlob)
 	}
 
-	for i, tt := range tests {
-		client.SetSandboxMode(tt.sandboxed)
-		tt.do(client)
+	if bs.ProductsErr == nil {
+		t.Errorf("products: expected a non-nil error")
+	}
+	if len(bs.Products) != 0 {
+		t.Errorf("products: got %d products, expected none", len(bs.Products))
+	}
 
-		got := backend.exhaust.(sandboxState)
-		if want := tt.want; got != want {
-			t.Errorf("#%d: sandboxed: got=(%v) want=(%v)", i, got, want)
-		}
+	// Now with a context that is already canceled.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if bs, err := client.Bootstrap(ctx, &uber.Place{}); err == nil {
+		t.Errorf("canceled context: expected a non-nil error, got bootstrap: %#v", bs)
 	}
-}
 
-func TestListHistory(t *testing.T) {
-	t.Skipf("Needs quite detailed data and intricate tests with paging")
+	// Canceling the context must abort the requests in flight too.
+	inFlight := &inFlightRoundTripper{base: slowRoundTripper(time.Minute)}
+	client.SetHTTPRoundTripper(inFlight)
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if bs, err := client.Bootstrap(ctx, &uber.Place{}); err == nil {
+		t.Errorf("timed out context: expected a non-nil error, got bootstrap: %#v", bs)
+	}
+	doneChan := make(chan bool)
+	go func() {
+		defer close(doneChan)
+		inFlight.Wait()
+	}()
+	select {
+	case <-doneChan:
+	case <-time.After(5 * time.Second):
+		t.Errorf("requests are still in flight after the context was canceled")
+	}
+}
 
-	// client, err := uber.NewClient(testToken1)
-	// if err != nil {
-	// 	t.Fatalf("initializing client; %v", err)
-	// }
+// inFlightRoundTripper tracks the requests that
+// its base RoundTripper hasn't yet returned from.
+type inFlightRoundTripper struct {
+	sync.WaitGroup
+	base http.RoundTripper
+}
 
-	// if err != nil {
-	// 	t.Fatalf("initializing client; %v", err)
-	// }
+var _ http.RoundTripper = (*inFlightRoundTripper)(nil)
 
-	// testingRoundTripper := &tRoundTripper{route: listHistory}
-	// client.SetHTTPRoundTripper(testingRoundTripper)
+func (ifrt *inFlightRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	ifrt.Add(1)
+	defer ifrt.Done()
+	return ifrt.base.RoundTrip(req)
+}
 
-	// tests := [...]struct{}{}
+var testOAuth2Token1 = &oauth2.Token{
+	AccessToken:  testOAuth2AccessToken1,
+	TokenType:    "Bearer",
+	RefreshToken: "uber-test-refresh-token",
 }
 
-func TestEstimatePrice(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
+// tokenCheckingRoundTripper rejects with a 401 all
+// the requests not authorized with its access token.
+type tokenCheckingRoundTripper string
+
+var _ http.RoundTripper = (*tokenCheckingRoundTripper)(nil)
+
+func (trt tokenCheckingRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Header.Get("Authorization") != "Bearer "+string(trt) {
+		return makeResp("401 Unauthorized", http.StatusUnauthorized), nil
 	}
+	resp := makeResp("200 OK", http.StatusOK)
+	resp.Body = ioutil.NopCloser(strings.NewReader(`{"promo_code":"FREE_RIDEZ","description":"$20 has been applied"}`))
+	return resp, nil
+}
 
-	testingRoundTripper := &tRoundTripper{route: estimatePriceRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
+func TestRetryingTransport(t *testing.T) {
+	refreshedToken := &oauth2.Token{AccessToken: "uber-test-refreshed-token", TokenType: "Bearer"}
 
 	tests := [...]struct {
-		ereq    *uber.EstimateRequest
-		wantErr bool
-		want    []*uber.PriceEstimate
+		refreshErr   error
+		wantErr      bool
+		wantRequests int
 	}{
-		0: {
-			ereq: &uber.EstimateRequest{
-				StartLatitude:  37.7752315,
-				EndLatitude:    37.7752415,
-				StartLongitude: -122.418075,
-				EndLongitude:   -122.518075,
-			},
-			want: priceEstimateFromFile("./testdata/estimate-1.json"),
-		},
-		1: {
-			ereq:    nil,
-			wantErr: true,
-		},
+		// The first attempt is rejected and the replay succeeds with the new token.
+		0: {wantRequests: 2},
+		// Without a new token, the request isn't replayed.
+		1: {refreshErr: errors.New("refresh token revoked"), wantErr: true, wantRequests: 1},
 	}
 
 	for i, tt := range tests {
-		estimatesChan, cancelPaging, err := client.EstimatePrice(tt.ereq)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
+		var refreshed []*oauth2.Token
+		refresh := func(expired *oauth2.Token) (*oauth2.Token, error) {
+			refreshed = append(refreshed, expired)
+			if tt.refreshErr != nil {
+				return nil, tt.refreshErr
 			}
-			continue
+			return refreshedToken, nil
 		}
 
+		recorder := &recordingRoundTripper{base: tokenCheckingRoundTripper(refreshedToken.AccessToken)}
+		transport := uberOAuth2.RetryingTransportWithBase(testOAuth2Token1, refresh, recorder)
+		client, err := uber.NewClient(testToken1)
 		if err != nil {
-			t.Errorf("#%d err: %v", i, err)
-			continue
+			t.Fatalf("initializing client; %v", err)
 		}
+		client.SetHTTPRoundTripper(transport)
 
-		firstPage := <-estimatesChan
-		// Then cancel it
-		cancelPaging()
+		promo, err := client.ApplyPromoCode("FREE_RIDEZ")
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("#%d: expected a non-nil error, got promo: %#v", i, promo)
+			}
+			// The refresh error must reach the caller.
+			var refreshErr *uberOAuth2.RefreshError
+			if !errors.As(err, &refreshErr) || !errors.Is(err, tt.refreshErr) {
+				t.Errorf("#%d: got err %v, want a *RefreshError of %v", i, err, tt.refreshErr)
+			}
+		} else if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+		} else if promo.Code != "FREE_RIDEZ" {
+			t.Errorf("#%d: promo code: got=%q want=%q", i, promo.Code, "FREE_RIDEZ")
+		}
 
-		if err := firstPage.Err; err != nil {
-			t.Errorf("#%d paging err: %v, firstPage: %#v", i, err, firstPage)
+		if len(refreshed) != 1 || refreshed[0] != testOAuth2Token1 {
+			t.Errorf("#%d: expected exactly one refresh of the rejected token, got: %v", i, refreshed)
+		}
+		if got := len(recorder.requests); got != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, got, tt.wantRequests)
+			continue
+		}
+		if tt.wantRequests < 2 {
 			continue
 		}
-		estimates := firstPage.Estimates
 
-		gotBlob, wantBlob := jsonSerialize(estimates), jsonSerialize(tt.want)
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		// The replay must be the same request, with the same body, and the new token.
+		if recorder.requests[0] != recorder.requests[1] {
+			t.Errorf("#%d: replayed %q instead of %q", i, recorder.requests[1], recorder.requests[0])
+		}
+		if len(recorder.bodies[0]) == 0 || !bytes.Equal(recorder.bodies[0], recorder.bodies[1]) {
+			t.Errorf("#%d: replayed body %q instead of %q", i, recorder.bodies[1], recorder.bodies[0])
+		}
+		if got, want := recorder.headers[1].Get("Authorization"), "Bearer "+refreshedToken.AccessToken; got != want {
+			t.Errorf("#%d: replay authorization: got=%q want=%q", i, got, want)
+		}
+
+		// Subsequent requests go out with the new token right away.
+		if _, err := client.ApplyPromoCode("FREE_RIDEZ"); err != nil {
+			t.Errorf("#%d: after refresh: err: %v", i, err)
+		}
+		if got := len(recorder.requests); got != 3 || len(refreshed) != 1 {
+			t.Errorf("#%d: after refresh: got %d requests and %d refreshes, want 3 and 1", i, got, len(refreshed))
 		}
 	}
 }
 
-func TestEstimateTime(t *testing.T) {
+func TestUpfrontFare(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
-
-	testingRoundTripper := &tRoundTripper{route: estimateTimeRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
+	testingRoundTripper := &tRoundTripper{route: upfrontFareRoute}
+	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, testingRoundTripper)
+	client.SetHTTPRoundTripper(transport)
 
 	tests := [...]struct {
-		treq    *uber.EstimateRequest
 		wantErr bool
-		want    []*uber.TimeEstimate
+		req     *uber.EstimateRequest
+		want    *uber.UpfrontFare
+
+		wantSurgeConfirmation *uber.SurgeConfirmation
 	}{
 		0: {
-			treq: &uber.EstimateRequest{
-				StartLatitude:  37.7752315,
-				EndLatitude:    37.7752415,
-				StartLongitude: -122.418075,
-				EndLongitude:   -122.518075,
-				ProductID:      "a1111c8c-c720-46c3-8534-2fcdd730040d",
+			req: &uber.EstimateRequest{
+				StartPlace: uber.PlaceHome,
+
+				EndLatitude:  37.7752415,
+				EndLongitude: -122.518075,
+			},
+			want: upfrontFareFromFileByID("surge"),
+			wantSurgeConfirmation: &uber.SurgeConfirmation{
+				URL:           "https://api.uber.com/surge-confirmations/7d604f5e",
+				ID:            "7d604f5e",
+				Multiplier:    1.5,
+				ExpiresAtUnix: 1502844378,
 			},
-			want: timeEstimateFromFile("./testdata/time-estimate-1.json"),
 		},
 		1: {
-			treq:    nil,
+			req: &uber.EstimateRequest{
+				StartLatitude:  37.7752415,
+				StartLongitude: -122.518075,
+
+				EndPlace: uber.PlaceWork,
+			},
+			want: upfrontFareFromFileByID("no-surge"),
+		},
+		2: {
+			req:     nil,
 			wantErr: true,
 		},
 	}
 
 	for i, tt := range tests {
-		estimatesChan, cancelPaging, err := client.EstimateTime(tt.treq)
+		upfrontFare, err := client.UpfrontFare(tt.req)
 		if tt.wantErr {
 			if err == nil {
 				t.Errorf("#%d expecting a non-nil error", i)
@@ -772,358 +6335,426 @@ func TestEstimateTime(t *testing.T) {
 		}
 
 		if err != nil {
-			t.Errorf("#%d err: %v", i, err)
+			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
 
-		firstPage := <-estimatesChan
-		// Then cancel it
-		cancelPaging()
-
-		if err := firstPage.Err; err != nil {
-			t.Errorf("#%d paging err: %v, firstPage: %#v", i, err, firstPage)
+		if tt.want == nil {
+			t.Errorf("#%d: inconsistency want is nil", i)
 			continue
 		}
-		estimates := firstPage.Estimates
 
-		gotBlob, wantBlob := jsonSerialize(estimates), jsonSerialize(tt.want)
+		gotBlob, wantBlob := jsonSerialize(upfrontFare), jsonSerialize(tt.want)
 		if !bytes.Equal(gotBlob, wantBlob) {
 			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
 		}
+
+		if g, w := upfrontFare.NeedsSurgeConfirmation(), tt.wantSurgeConfirmation != nil; g != w {
+			t.Errorf("#%d: needsSurgeConfirmation: got=%v want=%v", i, g, w)
+		}
+		if g, w := upfrontFare.SurgeConfirmation, tt.wantSurgeConfirmation; !reflect.DeepEqual(g, w) {
+			t.Errorf("#%d: surgeConfirmation:\ngot:  %#v\nwant: %#v", i, g, w)
+		}
 	}
 }
 
-func TestDriverProfile(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
+func TestTrackingURLs(t *testing.T) {
+	ride := new(uber.Ride)
+	if err := readFromFileAndDeserialize(rideFromPath("a1111c8c-c720-46c3-8534-2fcdd730040d"), ride); err != nil {
+		t.Fatalf("reading ride: %v", err)
+	}
+	wantRideURL := "https://m.uber.com/looking?request_id=a1111c8c-c720-46c3-8534-2fcdd730040d"
+	if got := ride.TrackingURL(); got != wantRideURL {
+		t.Errorf("ride: got=%q want=%q", got, wantRideURL)
+	}
+	if got := (*uber.Ride)(nil).TrackingURL(); got != "" {
+		t.Errorf("nil ride: got=%q want \"\"", got)
 	}
 
-	testingRoundTripper := &tRoundTripper{route: retrieveDriverProfileRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
+	fare := upfrontFareFromFileByID("surge")
+	wantFareURL := "https://m.uber.com/ul/?action=setPickup&fare_id=4f6b33ad54b2a45e"
+	if fare == nil || fare.TrackingURL != wantFareURL {
+		t.Errorf("fare: got=%+v want TrackingURL=%q", fare, wantFareURL)
+	}
 
-	invalidToken := fmt.Sprintf("%v", time.Now().Unix())
+	// The URLs must survive JSON round trips.
+	rideCopy, fareCopy := new(uber.Ride), new(uber.UpfrontFare)
+	if err := json.Unmarshal(jsonSerialize(ride), rideCopy); err != nil {
+		t.Fatalf("unmarshaling ride: %v", err)
+	}
+	if got := rideCopy.TrackingURL(); got != wantRideURL {
+		t.Errorf("ride round trip: got=%q want=%q", got, wantRideURL)
+	}
+	if err := json.Unmarshal(jsonSerialize(fare), fareCopy); err != nil {
+		t.Fatalf("unmarshaling fare: %v", err)
+	}
+	if got := fareCopy.TrackingURL; got != wantFareURL {
+		t.Errorf("fare round trip: got=%q want=%q", got, wantFareURL)
+	}
+}
+
+func TestRideETAs(t *testing.T) {
+	fixtureRide := new(uber.Ride)
+	if err := readFromFileAndDeserialize(rideFromPath("a1111c8c-c720-46c3-8534-2fcdd730040d"), fixtureRide); err != nil {
+		t.Fatalf("reading ride: %v", err)
+	}
 
 	tests := [...]struct {
-		wantErr     bool
-		bearerToken string
-		want        *uber.Profile
+		ride        *uber.Ride
+		blob        string
+		wantPickup  time.Duration
+		wantDropoff time.Duration
 	}{
-		0: {
-			bearerToken: testToken1,
-			want:        driverProfileFromFileByToken(testToken1),
-		},
-		1: {
-			bearerToken: invalidToken,
-			wantErr:     true,
-		},
+		0: {ride: fixtureRide, wantPickup: 5 * time.Minute, wantDropoff: 19 * time.Minute},
+		1: {ride: nil},
+		2: {blob: `{"status":"processing"}`},
+		// Once picked up, only the destination has an ETA.
+		3: {blob: `{"status":"in_progress","destination":{"latitude":37.62,"longitude":-122.37,"eta":12}}`, wantDropoff: 12 * time.Minute},
+		4: {blob: `{"pickup":{"eta":1.5},"destination":{"eta":0}}`, wantPickup: 90 * time.Second},
+		// The ETA of the ride itself is used if the pickup has none.
+		5: {blob: `{"eta":7,"pickup":{"latitude":37.33,"longitude":-121.88}}`, wantPickup: 7 * time.Minute},
 	}
 
 	for i, tt := range tests {
-		client.SetBearerToken(tt.bearerToken)
-		prof, err := client.DriverProfile()
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
+		ride := tt.ride
+		if tt.blob != "" {
+			ride = new(uber.Ride)
+			if err := json.Unmarshal([]byte(tt.blob), ride); err != nil {
+				t.Errorf("#%d: unmarshaling ride: %v", i, err)
+				continue
 			}
-			continue
 		}
-
-		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
-			continue
+		if got := ride.PickupETA(); got != tt.wantPickup {
+			t.Errorf("#%d: pickupETA: got=%v want=%v", i, got, tt.wantPickup)
 		}
-
-		gotBlob, wantBlob := jsonSerialize(prof), jsonSerialize(tt.want)
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		if got := ride.DropoffETA(); got != tt.wantDropoff {
+			t.Errorf("#%d: dropoffETA: got=%v want=%v", i, got, tt.wantDropoff)
 		}
 	}
 }
 
-func TestRetrieveMyProfile(t *testing.T) {
+func TestUpfrontFarePaymentMethodID(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
 
-	testingRoundTripper := &tRoundTripper{route: retrieveProfileRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
-
-	invalidToken := fmt.Sprintf("%v", time.Now().Unix())
-
 	tests := [...]struct {
-		wantErr     bool
-		bearerToken string
-		want        *uber.Profile
+		paymentMethodID string
 	}{
-		0: {
-			bearerToken: testToken1,
-			want:        profileFromFileByToken(testToken1),
-		},
-		1: {
-			bearerToken: invalidToken,
-			wantErr:     true,
-		},
+		0: {paymentMethodID: ""},
+		1: {paymentMethodID: "f43847de-8113-4587-c307-51c2d13a823c"},
 	}
 
 	for i, tt := range tests {
-		client.SetBearerToken(tt.bearerToken)
-		prof, err := client.RetrieveMyProfile()
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
-			}
-			continue
-		}
+		recorder := &recordingRoundTripper{base: &tRoundTripper{route: upfrontFareRoute}}
+		client.SetHTTPRoundTripper(uberOAuth2.TransportWithBase(testOAuth2Token1, recorder))
 
+		_, err := client.UpfrontFare(&uber.EstimateRequest{
+			StartPlace: uber.PlaceHome,
+			EndPlace:   uber.PlaceWork,
+
+			PaymentMethodID: tt.paymentMethodID,
+		})
 		if err != nil {
 			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
+		if len(recorder.bodies) != 1 {
+			t.Errorf("#%d: got %d requests want 1", i, len(recorder.bodies))
+			continue
+		}
 
-		gotBlob, wantBlob := jsonSerialize(prof), jsonSerialize(tt.want)
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		body := make(map[string]interface{})
+		if err := json.Unmarshal(recorder.bodies[0], &body); err != nil {
+			t.Errorf("#%d: unmarshaling body: %v", i, err)
+			continue
+		}
+		got, sent := body["payment_method_id"]
+		if tt.paymentMethodID == "" {
+			if sent {
+				t.Errorf("#%d: unexpectedly sent payment_method_id=%v", i, got)
+			}
+			continue
+		}
+		if got != tt.paymentMethodID {
+			t.Errorf("#%d: payment_method_id: got=%v want=%q", i, got, tt.paymentMethodID)
 		}
 	}
 }
 
-func TestApplyPromoCode(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
-	}
-
-	testingRoundTripper := &tRoundTripper{route: applyPromoCodeRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
+func TestUpfrontFareExpiry(t *testing.T) {
+	now := time.Now()
+	inAMinute, aMinuteAgo := now.Add(time.Minute), now.Add(-time.Minute)
 
 	tests := [...]struct {
-		wantErr   bool
-		promoCode string
-		want      *uber.PromoCode
+		expiresAt     string
+		wantExpiresAt int64
+		wantExpired   bool
+		wantErr       bool
 	}{
-		0: {
-			promoCode: promoCode1,
-			want:      promoCodeFromFileByToken(promoCode1),
-		},
-		1: {
-			// Try with a random promo code that's unauthorized.
-			promoCode: fmt.Sprintf("%v", time.Now().Unix()),
-			wantErr:   true,
-		},
+		0: {expiresAt: fmt.Sprintf("%d", inAMinute.Unix()), wantExpiresAt: inAMinute.Unix()},
+		1: {expiresAt: fmt.Sprintf("%q", inAMinute.Format(time.RFC3339)), wantExpiresAt: inAMinute.Unix()},
+		2: {expiresAt: fmt.Sprintf("%d", aMinuteAgo.Unix()), wantExpiresAt: aMinuteAgo.Unix(), wantExpired: true},
+		3: {expiresAt: fmt.Sprintf("%q", aMinuteAgo.UTC().Format(time.RFC3339)), wantExpiresAt: aMinuteAgo.Unix(), wantExpired: true},
+		4: {expiresAt: fmt.Sprintf(`"%d"`, inAMinute.Unix()), wantExpiresAt: inAMinute.Unix()},
+		5: {expiresAt: `null`},
+		6: {expiresAt: `"next tuesday"`, wantErr: true},
 	}
 
 	for i, tt := range tests {
-		appliedPromoCode, err := client.ApplyPromoCode(tt.promoCode)
+		blob := fmt.Sprintf(`{"fare":{"value":5.73,"expires_at":%s}}`, tt.expiresAt)
+		upf := new(uber.UpfrontFare)
+		err := json.Unmarshal([]byte(blob), upf)
 		if tt.wantErr {
 			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
+				t.Errorf("#%d: expected a non-nil error", i)
 			}
 			continue
 		}
-
 		if err != nil {
 			t.Errorf("#%d: err: %v", i, err)
 			continue
 		}
+		if got := upf.Fare.ExpiresAt; got != tt.wantExpiresAt {
+			t.Errorf("#%d: expiresAt: got=%d want=%d", i, got, tt.wantExpiresAt)
+		}
+		if got := upf.Expired(); got != tt.wantExpired {
+			t.Errorf("#%d: expired: got=%v want=%v", i, got, tt.wantExpired)
+		}
 
-		gotBlob, wantBlob := jsonSerialize(appliedPromoCode), jsonSerialize(tt.want)
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		untilExpiry := upf.TimeUntilExpiry()
+		switch {
+		case tt.wantExpiresAt == 0:
+			if untilExpiry != 0 {
+				t.Errorf("#%d: timeUntilExpiry: got=%v want 0 for a fare without an expiry", i, untilExpiry)
+			}
+		case tt.wantExpired:
+			if untilExpiry > -58*time.Second || untilExpiry < -62*time.Second {
+				t.Errorf("#%d: timeUntilExpiry: got=%v want about -1m", i, untilExpiry)
+			}
+		default:
+			if untilExpiry > time.Minute || untilExpiry < 58*time.Second {
+				t.Errorf("#%d: timeUntilExpiry: got=%v want about 1m", i, untilExpiry)
+			}
 		}
 	}
+
+	if fare := (*uber.UpfrontFare)(nil); fare.Expired() || fare.TimeUntilExpiry() != 0 {
+		t.Errorf("a nil fare must neither be expired nor have an expiry")
+	}
 }
 
-func TestRequestRide(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
+func TestUpfrontFareSurgeConfirmationFromEstimate(t *testing.T) {
+	// Responses that only carry the surge
+	// confirmation details in the estimate.
+	blob := []byte(`{"estimate":{"surge_confirmation_href":"https://api.uber.com/v1/surge-confirmations/7d604f5e","surge_confirmation_id":"7d604f5e","surge_multiplier":1.5}}`)
+	fare := new(uber.UpfrontFare)
+	if err := json.Unmarshal(blob, fare); err != nil {
+		t.Fatalf("unmarshaling fare: %v", err)
+	}
+	if !fare.NeedsSurgeConfirmation() {
+		t.Fatal("expecting the fare to need surge confirmation")
+	}
+	want := &uber.SurgeConfirmation{
+		URL:        "https://api.uber.com/v1/surge-confirmations/7d604f5e",
+		ID:         "7d604f5e",
+		Multiplier: 1.5,
+	}
+	if g, w := fare.SurgeConfirmation, want; !reflect.DeepEqual(g, w) {
+		t.Errorf("surgeConfirmation:\ngot:  %#v\nwant: %#v", g, w)
 	}
 
-	testingRoundTripper := &tRoundTripper{route: requestRideRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, testingRoundTripper)
-	client.SetHTTPRoundTripper(transport)
+	var nilFare *uber.UpfrontFare
+	if nilFare.NeedsSurgeConfirmation() {
+		t.Error("a nil fare cannot need surge confirmation")
+	}
+}
 
+func TestUpfrontFareComponents(t *testing.T) {
 	tests := [...]struct {
-		wantErr bool
-		req     *uber.RideRequest
+		fare          *uber.UpfrontFare
+		wantBreakdown int
+		components    map[string]*uber.FareComponent
 	}{
 		0: {
-			req:     nil,
-			wantErr: true,
+			fare:          upfrontFareFromFileByID("no-surge"),
+			wantBreakdown: 3,
+			components: map[string]*uber.FareComponent{
+				uber.FareComponentBaseFare:  {Type: "base_fare", Name: "Base Fare", Value: 6.48},
+				uber.FareComponentPromotion: {Type: "promotion", Name: "Promotion", Value: -2.00},
+				uber.FareComponentUnknown:   {Type: "unknown", Name: "Booking Fee", Value: 1.25},
+			},
 		},
 		1: {
-			req: &uber.RideRequest{
-				FareID:     "fareID-1",
-				StartPlace: uber.PlaceHome,
-				EndPlace:   uber.PlaceWork,
+			fare:          upfrontFareFromFileByID("surge"),
+			wantBreakdown: 2,
+			components: map[string]*uber.FareComponent{
+				uber.FareComponentBaseFare: {
+					Type: "base_fare", Name: "Base Fare", Value: 9.00,
+					Notice: "Fares are slightly higher due to increased demand",
+				},
+				uber.FareComponentUnknown:   {Type: "unknown", Name: "Booking Fee", Value: 1.25},
+				uber.FareComponentPromotion: nil,
 			},
 		},
 		2: {
-			req: &uber.RideRequest{
-				StartPlace: uber.PlaceHome,
-				EndPlace:   uber.PlaceWork,
-				PromptOnFare: func(fare *uber.UpfrontFare) error {
-					if fare.Fare.Value >= 0.90 {
-						return fmt.Errorf("times are hard, not paying more than 90 cents!")
-					}
-					return nil
-				},
+			fare: &uber.UpfrontFare{},
+			components: map[string]*uber.FareComponent{
+				uber.FareComponentBaseFare: nil,
 			},
-			wantErr: true,
 		},
 	}
 
-	blankRide := new(uber.Ride)
 	for i, tt := range tests {
-		ride, err := client.RequestRide(tt.req)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
+		if tt.fare == nil {
+			t.Errorf("#%d: expecting a non-nil fare", i)
 			continue
 		}
-
-		if ride == nil {
-			t.Errorf("#%d: expecting non-nil ride", i)
-			continue
+		if tt.fare.Fare != nil {
+			if g, w := len(tt.fare.Fare.Breakdown), tt.wantBreakdown; g != w {
+				t.Errorf("#%d: breakdown: got %d components want %d", i, g, w)
+			}
 		}
 
-		if reflect.DeepEqual(blankRide, ride) {
-			t.Errorf("#%d: expecting a non-blank ride response", i)
+		for componentType, want := range tt.components {
+			got, ok := tt.fare.Component(componentType)
+			if g, w := ok, want != nil; g != w {
+				t.Errorf("#%d: %q: found: got=%v want=%v", i, componentType, g, w)
+				continue
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("#%d: %q:\ngot:  %#v\nwant: %#v", i, componentType, got, want)
+			}
 		}
 	}
 }
 
-const (
-	requestID1 = "b5512127-a134-4bf4-b1ba-fe9f48f56d9d"
-)
-
-func TestPlaceRetrieval(t *testing.T) {
-	client, err := uber.NewClient(testToken1)
-	if err != nil {
-		t.Fatalf("initializing client; %v", err)
-	}
-
-	testingRoundTripper := &tRoundTripper{route: getPlacesRoute}
-	client.SetHTTPRoundTripper(testingRoundTripper)
-
+func TestUpfrontFareTripEstimates(t *testing.T) {
 	tests := [...]struct {
-		wantErr bool
-		place   uber.PlaceName
-		want    *uber.Place
+		fare         *uber.UpfrontFare
+		wantDistance float64
+		wantUnit     string
+		wantDuration time.Duration
 	}{
 		0: {
-			place: "home",
-			want:  placeFromFile("685-market"),
+			fare:         upfrontFareFromFileByID("surge"),
+			wantDistance: 1.95,
+			wantUnit:     "mile",
+			wantDuration: 8 * time.Minute,
 		},
 		1: {
-			place: "work",
-			want:  placeFromFile("wallaby-way"),
+			fare:         upfrontFareFromFileByID("no-surge"),
+			wantDistance: 2.39,
+			wantUnit:     "mile",
+			wantDuration: 9 * time.Minute,
 		},
 		2: {
-			place:   "workz",
-			wantErr: true,
-		},
-		3: {
-			place:   "",
-			wantErr: true,
+			// No trip estimates at all.
+			fare: func() *uber.UpfrontFare {
+				fare := new(uber.UpfrontFare)
+				if err := json.Unmarshal([]byte(`{"fare":{"value":5.73}}`), fare); err != nil {
+					t.Fatalf("unmarshaling fare: %v", err)
+				}
+				return fare
+			}(),
 		},
 	}
 
 	for i, tt := range tests {
-		place, err := client.Place(tt.place)
-		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
-			}
+		fare := tt.fare
+		if fare == nil {
+			t.Errorf("#%d: expecting a non-nil fare", i)
 			continue
 		}
-
-		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
-			continue
+		if g, w := fare.Distance, tt.wantDistance; g != w {
+			t.Errorf("#%d: distance: got=%v want=%v", i, g, w)
 		}
-
-		gotBlob, wantBlob := jsonSerialize(place), jsonSerialize(tt.want)
-		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		if g, w := fare.DistanceUnit, tt.wantUnit; g != w {
+			t.Errorf("#%d: distanceUnit: got=%q want=%q", i, g, w)
+		}
+		if g, w := fare.Duration, tt.wantDuration; g != w {
+			t.Errorf("#%d: duration: got=%v want=%v", i, g, w)
 		}
 	}
 }
 
-var testOAuth2Token1 = &oauth2.Token{
-	AccessToken:  testOAuth2AccessToken1,
-	TokenType:    "Bearer",
-	RefreshToken: "uber-test-refresh-token",
-}
-
-func TestUpfrontFare(t *testing.T) {
+func TestFareWithProduct(t *testing.T) {
 	client, err := uber.NewClient(testToken1)
 	if err != nil {
 		t.Fatalf("initializing client; %v", err)
 	}
-	testingRoundTripper := &tRoundTripper{route: upfrontFareRoute}
-	transport := uberOAuth2.TransportWithBase(testOAuth2Token1, testingRoundTripper)
-	client.SetHTTPRoundTripper(transport)
 
+	testingRoundTripper := &tRoundTripper{route: productByID}
+	client.SetHTTPRoundTripper(testingRoundTripper)
+
+	productID := "a1111c8c-c720-46c3-8534-2fcdd730040d"
 	tests := [...]struct {
-		wantErr bool
-		req     *uber.EstimateRequest
-		want    *uber.UpfrontFare
+		req *uber.EstimateRequest
+
+		wantFare       *uber.UpfrontFare
+		wantProduct    bool
+		wantErr        bool
+		wantProductErr bool
 	}{
 		0: {
 			req: &uber.EstimateRequest{
 				StartPlace: uber.PlaceHome,
-
-				EndLatitude:  37.7752415,
-				EndLongitude: -122.518075,
+				EndPlace:   uber.PlaceWork,
+				ProductID:  productID,
 			},
-			want: upfrontFareFromFileByID("surge"),
+			wantFare:    upfrontFareFromFileByID("no-surge"),
+			wantProduct: true,
 		},
 		1: {
+			// The product lookup fails independently of the fare.
 			req: &uber.EstimateRequest{
-				StartLatitude:  37.7752415,
-				StartLongitude: -122.518075,
-
-				EndPlace: uber.PlaceWork,
+				StartPlace: uber.PlaceHome,
+				EndPlace:   uber.PlaceWork,
+				ProductID:  "unknown-product",
 			},
-			want: upfrontFareFromFileByID("no-surge"),
+			wantFare:       upfrontFareFromFileByID("no-surge"),
+			wantProductErr: true,
 		},
 		2: {
+			// The fare's trip has no productID to lookup.
+			req: &uber.EstimateRequest{
+				StartPlace: uber.PlaceHome,
+				EndPlace:   uber.PlaceHome,
+			},
+			wantFare:       upfrontFareFromFileByID("surge"),
+			wantProductErr: true,
+		},
+		3: {
 			req:     nil,
 			wantErr: true,
 		},
 	}
 
 	for i, tt := range tests {
-		upfrontFare, err := client.UpfrontFare(tt.req)
+		fare, product, err := client.FareWithProduct(tt.req)
 		if tt.wantErr {
-			if err == nil {
-				t.Errorf("#%d expecting a non-nil error", i)
-			}
-			continue
-		}
-
-		if err != nil {
-			t.Errorf("#%d: err: %v", i, err)
+			if err == nil || fare != nil || product != nil {
+				t.Errorf("#%d: expected only a non-nil error, got fare=%#v product=%#v", i, fare, product)
+			}
 			continue
 		}
 
-		if tt.want == nil {
-			t.Errorf("#%d: inconsistency want is nil", i)
-			continue
+		if gotErr := err != nil; gotErr != tt.wantProductErr {
+			t.Errorf("#%d: gotErr=%v wantProductErr=%v err=%v", i, gotErr, tt.wantProductErr, err)
 		}
 
-		gotBlob, wantBlob := jsonSerialize(upfrontFare), jsonSerialize(tt.want)
+		gotBlob, wantBlob := jsonSerialize(fare), jsonSerialize(tt.wantFare)
 		if !bytes.Equal(gotBlob, wantBlob) {
-			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+			t.Errorf("#%d: fare:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+
+		if tt.wantProduct {
+			if product == nil || product.ID != productID {
+				t.Errorf("#%d: product: got=%#v want ID=%q", i, product, productID)
+			}
+		} else if product != nil {
+			t.Errorf("#%d: expected a nil product, got %#v", i, product)
 		}
 	}
 }
@@ -1296,7 +6927,175 @@ func TestRequestReceipt(t *testing.T) {
 	}
 }
 
+func TestReceiptCharges(t *testing.T) {
+	receipt := receiptFromFile(requestID1)
+	if receipt == nil {
+		t.Fatal("expecting a receipt")
+	}
+
+	tests := [...]struct {
+		name       string
+		wantAmount float64
+		wantFound  bool
+	}{
+		0: {name: "Base Fare", wantAmount: 2.20, wantFound: true},
+		1: {name: "base_fare", wantAmount: 2.20, wantFound: true},
+		2: {name: "TIME", wantAmount: 3.57, wantFound: true},
+		3: {name: "surge", wantAmount: 4.26, wantFound: true},
+		4: {name: "Tolls"},
+		5: {name: ""},
+	}
+
+	for i, tt := range tests {
+		charge, found := receipt.Charge(tt.name)
+		if found != tt.wantFound {
+			t.Errorf("#%d: found=%v wantFound=%v", i, found, tt.wantFound)
+			continue
+		}
+		if !found {
+			continue
+		}
+		if got := float64(charge.Amount); got != tt.wantAmount {
+			t.Errorf("#%d: amount: got %v want %v", i, got, tt.wantAmount)
+		}
+	}
+
+	if !receipt.SurgeApplied() {
+		t.Error("expecting the surge to have been applied")
+	}
+	noSurge := *receipt
+	noSurge.SurgeCharge = nil
+	if noSurge.SurgeApplied() {
+		t.Error("expecting no surge without a surge charge")
+	}
+
+	if got, want := receipt.SubtotalMoney(), (uber.Money{Amount: 12.78, CurrencyCode: "USD"}); got != want {
+		t.Errorf("subtotal: got %v want %v", got, want)
+	}
+
+	// The subtotal must add up from the charges and the surge.
+	sum := float64(receipt.SurgeCharge.Amount)
+	for _, charge := range receipt.Charges {
+		sum += float64(charge.Amount)
+	}
+	if got := fmt.Sprintf("%.2f", sum); got != "12.78" {
+		t.Errorf("charges sum: got %s want 12.78", got)
+	}
+}
+
+func TestMoney(t *testing.T) {
+	receipt := receiptFromFile(requestID1)
+	estimates := priceEstimateFromFile("./fakeserver/fixtures/estimates-price.json")
+	if receipt == nil || len(estimates) < 3 {
+		t.Fatal("expecting a receipt and at least 3 price estimates")
+	}
+	fare := upfrontFareFromFileByID("no-surge")
+
+	tests := [...]struct {
+		got        uber.Money
+		want       uber.Money
+		wantFormat string
+	}{
+		0: {
+			got:        receipt.TotalMoney(),
+			want:       uber.Money{Amount: 5.92, CurrencyCode: "USD"},
+			wantFormat: "5.92 USD",
+		},
+		1: {
+			got:        estimates[2].LowMoney(),
+			want:       uber.Money{Amount: 29, CurrencyCode: "USD"},
+			wantFormat: "29.00 USD",
+		},
+		2: {
+			got:        estimates[2].HighMoney(),
+			want:       uber.Money{Amount: 38, CurrencyCode: "USD"},
+			wantFormat: "38.00 USD",
+		},
+		3: {
+			got:        fare.Fare.Money(),
+			want:       uber.Money{Amount: 5.73, CurrencyCode: "USD"},
+			wantFormat: "5.73 USD",
+		},
+		4: {
+			// No minor units.
+			got:        uber.Money{Amount: 1200, CurrencyCode: "JPY"},
+			want:       uber.Money{Amount: 1200, CurrencyCode: "JPY"},
+			wantFormat: "1200 JPY",
+		},
+		5: {
+			got:        (*uber.Receipt)(nil).TotalMoney(),
+			want:       uber.Money{},
+			wantFormat: "0.00",
+		},
+		6: {
+			// A comma as the decimal separator.
+			got:        (&uber.Receipt{TotalCharged: "€12,78", CurrencyCode: "EUR"}).TotalMoney(),
+			want:       uber.Money{Amount: 12.78, CurrencyCode: "EUR"},
+			wantFormat: "12.78 EUR",
+		},
+		7: {
+			// Falls back to the total fare and
+			// handles thousands separators.
+			got:        (&uber.Receipt{TotalFare: "$1,250.50", CurrencyCode: "USD"}).TotalMoney(),
+			want:       uber.Money{Amount: 1250.5, CurrencyCode: "USD"},
+			wantFormat: "1250.50 USD",
+		},
+		8: {
+			// A comma followed by three digits groups thousands.
+			got:        (&uber.Receipt{TotalCharged: "$1,200", CurrencyCode: "USD"}).TotalMoney(),
+			want:       uber.Money{Amount: 1200, CurrencyCode: "USD"},
+			wantFormat: "1200.00 USD",
+		},
+		9: {
+			got:        (&uber.Receipt{TotalCharged: "¥1,200", CurrencyCode: "JPY"}).TotalMoney(),
+			want:       uber.Money{Amount: 1200, CurrencyCode: "JPY"},
+			wantFormat: "1200 JPY",
+		},
+		10: {
+			got:        (&uber.Receipt{Subtotal: "₩15,000", CurrencyCode: "KRW"}).SubtotalMoney(),
+			want:       uber.Money{Amount: 15000, CurrencyCode: "KRW"},
+			wantFormat: "15000 KRW",
+		},
+	}
+
+	for i, tt := range tests {
+		if g, w := tt.got, tt.want; g != w {
+			t.Errorf("#%d: got=%#v want=%#v", i, g, w)
+		}
+		if g, w := tt.got.Format(), tt.wantFormat; g != w {
+			t.Errorf("#%d: format: got=%q want=%q", i, g, w)
+		}
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	tests := [...]struct {
+		token   string
+		wantErr error
+	}{
+		0: {token: testToken1},
+		1: {token: "invalid-token", wantErr: uber.ErrUnauthorized},
+	}
+
+	for i, tt := range tests {
+		client, err := uber.NewClient(tt.token)
+		if err != nil {
+			t.Errorf("#%d: initializing client; %v", i, err)
+			continue
+		}
+		client.SetHTTPRoundTripper(&tRoundTripper{route: retrieveProfileRoute})
+
+		if g, w := client.VerifyToken(), tt.wantErr; g != w {
+			t.Errorf("#%d: got err=%v want=%v", i, g, w)
+		}
+	}
+}
+
 func profileTokenPath(tokenSuffix string) string {
+	if tokenSuffix == testToken1 {
+		// The profile is shared with fakeserver.
+		return "./fakeserver/fixtures/me.json"
+	}
 	return fmt.Sprintf("./testdata/profile-%s.json", tokenSuffix)
 }
 
@@ -1335,8 +7134,12 @@ func driverProfileFromFileByToken(tokenSuffix string) *uber.Profile {
 	return prof
 }
 
+// errAny is used in tests to signify
+// that any non-nil error is expected.
+var errAny = errors.New("any error")
+
 func jsonSerialize(v interface{}) []byte {
-	blob, _ := json.Marshal(v)
+	blob, _ := uber.MarshalCanonical(v)
 	return blob
 }
 
@@ -1412,6 +7215,26 @@ func (trt *tRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
 		return trt.listDriverPaymentsRoundTrip(req)
 	case listDriverTripsRoute:
 		return trt.listDriverTripsRoundTrip(req)
+	case bootstrapRoute:
+		return trt.bootstrapRoundTrip(req)
+	case listHistoryRoute:
+		return trt.listHistoryRoundTrip(req)
+	case productsWithETARoute:
+		return trt.productsWithETARoundTrip(req)
+	case loginRedirectRoute:
+		return trt.loginRedirectRoundTrip(req)
+	case updateRideDestinationRoute:
+		return trt.updateRideDestinationRoundTrip(req)
+	case baseURLRoute:
+		return trt.baseURLRoundTrip(req)
+	case driverActivityRoute:
+		return trt.driverActivityRoundTrip(req)
+	case listPlacesRoute:
+		return trt.listPlacesRoundTrip(req)
+	case deliveryTrackingRoute:
+		return trt.deliveryTrackingRoundTrip(req)
+	case driverTripReceiptsRoute:
+		return trt.driverTripReceiptsRoundTrip(req)
 	default:
 		return makeResp("Not Found", http.StatusNotFound), nil
 	}
@@ -1449,6 +7272,10 @@ func prescreenAuthAndMethod(req *http.Request, wantMethod string) (*http.Respons
 }
 
 func rideFromPath(rideID string) string {
+	if rideID == ride1 {
+		// The ride is shared with fakeserver.
+		return "./fakeserver/fixtures/ride.json"
+	}
 	return fmt.Sprintf("./testdata/ride-%s.json", rideID)
 }
 
@@ -1480,6 +7307,64 @@ func (trt *tRoundTripper) requestRideRoundTrip(req *http.Request) (*http.Respons
 	return resp, nil
 }
 
+func makeUberErrorResp(code int, signature, title string) *http.Response {
+	resp := makeResp(http.StatusText(code), code)
+	resp.Header.Set("Content-Type", "application/json")
+	blob, _ := json.Marshal(map[string]interface{}{
+		"errors": []map[string]interface{}{
+			{"status": code, "code": signature, "title": title},
+		},
+	})
+	resp.Body = ioutil.NopCloser(bytes.NewReader(blob))
+	return resp
+}
+
+func (trt *tRoundTripper) updateRideDestinationRoundTrip(req *http.Request) (*http.Response, error) {
+	badAuthResp, _, err := prescreenAuthAndMethod(req, "PATCH")
+	if badAuthResp != nil || err != nil {
+		return badAuthResp, err
+	}
+	if req.Body != nil {
+		defer req.Body.Close()
+	}
+
+	splits := strings.Split(req.URL.Path, "/")
+	// Expecting the form: /v1.2/requests/<requestID>
+	if len(splits) != 4 || splits[2] != "requests" {
+		resp := makeResp("expecting URL of form /v1.2/requests/<requestID>", http.StatusBadRequest)
+		return resp, nil
+	}
+
+	slurp, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		return makeResp(err.Error(), http.StatusInternalServerError), nil
+	}
+
+	// Ensure that the body only contains the destination fields.
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(slurp, &body); err != nil {
+		return makeResp(err.Error(), http.StatusBadRequest), nil
+	}
+	_, hasPlace := body["end_place_id"]
+	_, hasLat := body["end_latitude"]
+	_, hasLon := body["end_longitude"]
+	switch {
+	case hasPlace && len(body) == 1:
+	case hasLat && hasLon && len(body) == 2:
+	default:
+		return makeResp(fmt.Sprintf("unexpected body: %s", slurp), http.StatusBadRequest), nil
+	}
+
+	switch requestID := splits[3]; requestID {
+	case ride1:
+		return makeResp("204 No Content", http.StatusNoContent), nil
+	case outOfAreaRideID:
+		return makeUberErrorResp(http.StatusUnprocessableEntity, "outside_service_area", "The destination is not supported by the requested product."), nil
+	default:
+		return makeResp("unknown requestID", http.StatusNotFound), nil
+	}
+}
+
 func (trt *tRoundTripper) applyPromoCodeRoundTrip(req *http.Request) (*http.Response, error) {
 	badAuthResp, _, err := prescreenAuthAndMethod(req, "PATCH")
 	if badAuthResp != nil || err != nil {
@@ -1530,7 +7415,7 @@ func (trt *tRoundTripper) estimateTimeRoundTrip(req *http.Request) (*http.Respon
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
 	}
-	resp := responseFromFileContent("./testdata/time-estimate-1.json")
+	resp := responseFromFileContent("./fakeserver/fixtures/estimates-time.json")
 	return resp, nil
 }
 
@@ -1538,7 +7423,7 @@ func (trt *tRoundTripper) estimatePriceRoundTrip(req *http.Request) (*http.Respo
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
 	}
-	resp := responseFromFileContent("./testdata/price-estimate-1.json")
+	resp := responseFromFileContent("./fakeserver/fixtures/estimates-price.json")
 	return resp, nil
 }
 
@@ -1572,6 +7457,69 @@ func (trt *tRoundTripper) getPlacesRoundTrip(req *http.Request) (*http.Response,
 	return responseFromFileContent(diskPath), nil
 }
 
+func (trt *tRoundTripper) listPlacesRoundTrip(req *http.Request) (*http.Response, error) {
+	// exhaust maps the places that must fail to their status codes.
+	failures, _ := trt.exhaust.(map[uber.PlaceName]int)
+	placeName := uber.PlaceName(req.URL.Path[strings.LastIndex(req.URL.Path, "/")+1:])
+	switch code := failures[placeName]; code {
+	case 0:
+		return trt.getPlacesRoundTrip(req)
+	case http.StatusNotFound:
+		return makeUberErrorResp(code, "unknown_place_id", "Could not resolve the given place_id."), nil
+	default:
+		return makeUberErrorResp(code, "internal_server_error", "Internal server error."), nil
+	}
+}
+
+func (trt *tRoundTripper) bootstrapRoundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/me"):
+		return trt.retrieveProfileRoundTrip(req)
+	case strings.HasSuffix(path, "/payment-methods"):
+		return trt.listPaymentMethodRoundTrip(req)
+	case strings.Contains(path, "/places/"):
+		return trt.getPlacesRoundTrip(req)
+	case strings.HasSuffix(path, "/products"):
+		return trt.listProductsRoundTrip(req)
+	default:
+		return makeResp("Not Found", http.StatusNotFound), nil
+	}
+}
+
+func (trt *tRoundTripper) productsWithETARoundTrip(req *http.Request) (*http.Response, error) {
+	path := req.URL.Path
+	switch {
+	case strings.HasSuffix(path, "/products"):
+		return trt.listProductsRoundTrip(req)
+	case strings.HasSuffix(path, "/estimates/time"):
+		return trt.estimateTimeRoundTrip(req)
+	default:
+		return makeResp("Not Found", http.StatusNotFound), nil
+	}
+}
+
+const loginPageURL = "https://login.example.com/login"
+
+func (trt *tRoundTripper) loginRedirectRoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.String() == loginPageURL {
+		resp := makeResp("200 OK", http.StatusOK)
+		resp.Header.Set("Content-Type", "text/html; charset=utf-8")
+		resp.Body = ioutil.NopCloser(strings.NewReader("<html><body>Please log in</body></html>"))
+		return resp, nil
+	}
+
+	resp := makeResp("302 Found", http.StatusFound)
+	resp.Header.Set("Location", loginPageURL)
+	return resp, nil
+}
+
+func (trt *tRoundTripper) baseURLRoundTrip(req *http.Request) (*http.Response, error) {
+	// Record the URL that the client constructed.
+	trt.exhaust = req.URL.String()
+	return trt.bootstrapRoundTrip(req)
+}
+
 func (trt *tRoundTripper) sandboxTestRoundTrip(req *http.Request) (*http.Response, error) {
 	if req.Body != nil {
 		defer req.Body.Close()
@@ -1632,6 +7580,33 @@ func driverTripsListResponsePath(offset int64) string {
 	return fmt.Sprintf("./testdata/driver_trips_%d.json", offset)
 }
 
+func historyListResponsePath(offset int64) string {
+	return fmt.Sprintf("./testdata/history_%d.json", offset)
+}
+
+func (trt *tRoundTripper) listHistoryRoundTrip(req *http.Request) (*http.Response, error) {
+	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
+		return badAuthResp, err
+	}
+	got := req.URL.Path
+	wantSuffix := "/v1.2/history"
+	if !strings.HasSuffix(got, wantSuffix) {
+		resp := makeResp(fmt.Sprintf("got=%q wantSuffix=%q", got, wantSuffix), http.StatusBadRequest)
+		return resp, nil
+	}
+	query := req.URL.Query()
+	offset := int64(0)
+	if offsetStr := query.Get("offset"); offsetStr != "" {
+		var err error
+		offset, err = strconv.ParseInt(offsetStr, 10, 32)
+		if err != nil {
+			return makeResp(err.Error(), http.StatusBadRequest), nil
+		}
+	}
+	path := historyListResponsePath(offset)
+	return responseFromFileContent(path), nil
+}
+
 func (trt *tRoundTripper) listDriverTripsRoundTrip(req *http.Request) (*http.Response, error) {
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
@@ -1655,6 +7630,50 @@ func (trt *tRoundTripper) listDriverTripsRoundTrip(req *http.Request) (*http.Res
 	return responseFromFileContent(path), nil
 }
 
+// driverTripReceiptsRoundTrip serves both the driver's trips
+// and the receipts of those trips, except for driver-trip-3.
+func (trt *tRoundTripper) driverTripReceiptsRoundTrip(req *http.Request) (*http.Response, error) {
+	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
+		return badAuthResp, err
+	}
+	switch path := req.URL.Path; {
+	case strings.HasSuffix(path, "/v1/partners/trips"):
+		offset := int64(0)
+		if offsetStr := req.URL.Query().Get("offset"); offsetStr != "" {
+			var err error
+			offset, err = strconv.ParseInt(offsetStr, 10, 32)
+			if err != nil {
+				return makeResp(err.Error(), http.StatusBadRequest), nil
+			}
+		}
+		return responseFromFileContent(fmt.Sprintf("./testdata/driver_receipt_trips_%d.json", offset)), nil
+
+	case strings.HasSuffix(path, "/receipt"):
+		splits := strings.Split(path, "/")
+		diskPath := receiptPathFromRequestID(splits[len(splits)-2])
+		if _, err := os.Stat(diskPath); err != nil {
+			return makeUberErrorResp(http.StatusNotFound, "not_found", "Receipt not found."), nil
+		}
+		return responseFromFileContent(diskPath), nil
+
+	default:
+		return makeResp("unexpected path "+path, http.StatusNotFound), nil
+	}
+}
+
+func (trt *tRoundTripper) driverActivityRoundTrip(req *http.Request) (*http.Response, error) {
+	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
+		return badAuthResp, err
+	}
+	// All the trips are on the first page.
+	if offset := req.URL.Query().Get("offset"); offset != "" && offset != "0" {
+		resp := makeResp("200 OK", http.StatusOK)
+		resp.Body = ioutil.NopCloser(strings.NewReader(`{"trips":[]}`))
+		return resp, nil
+	}
+	return responseFromFileContent("./testdata/driver_activity.json"), nil
+}
+
 func (trt *tRoundTripper) listDriverPaymentsRoundTrip(req *http.Request) (*http.Response, error) {
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
@@ -1716,6 +7735,22 @@ func (trt *tRoundTripper) cancelDeliveryRoundTrip(req *http.Request) (*http.Resp
 	return makeResp("204 No content", http.StatusNoContent), nil
 }
 
+func (trt *tRoundTripper) deliveryTrackingRoundTrip(req *http.Request) (*http.Response, error) {
+	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
+		return badAuthResp, err
+	}
+	splits := strings.Split(req.URL.Path, "/")
+	if len(splits) != 4 || splits[2] != "deliveries" {
+		resp := makeResp("expecting a path of form: /v1.2/deliveries/<deliveryID>", http.StatusBadRequest)
+		return resp, nil
+	}
+	deliveryID := splits[3]
+	if !knownDeliveryID(deliveryID) {
+		return makeUberErrorResp(http.StatusNotFound, "not_found", "Delivery not found."), nil
+	}
+	return responseFromFileContent(fmt.Sprintf("./testdata/delivery-tracking-%s.json", deliveryID)), nil
+}
+
 func (trt *tRoundTripper) deliveryRoundTrip(req *http.Request) (*http.Response, error) {
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "POST"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
@@ -1766,6 +7801,10 @@ func (trt *tRoundTripper) upfrontFareRoundTrip(req *http.Request) (*http.Respons
 }
 
 func fareEstimatePath(suffix string) string {
+	if suffix == "no-surge" {
+		// The fare without surge is shared with fakeserver.
+		return "./fakeserver/fixtures/requests-estimate.json"
+	}
 	return fmt.Sprintf("./testdata/fare-estimate-%s.json", suffix)
 }
 
@@ -1817,6 +7856,131 @@ func mapPathFromRequestID(tripID string) string {
 	return fmt.Sprintf("./testdata/map-%s.json", tripID)
 }
 
+// mapNotReadyRoundTripper serves a map without an href for
+// the first notReady requests and the map from disk afterwards.
+type mapNotReadyRoundTripper struct {
+	sync.Mutex
+	notReady int
+	requests int
+}
+
+func (mrt *mapNotReadyRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	mrt.Lock()
+	mrt.requests++
+	ready := mrt.requests > mrt.notReady
+	mrt.Unlock()
+
+	if ready {
+		return (&tRoundTripper{route: getMapRoute}).RoundTrip(req)
+	}
+	resp := makeResp("OK", http.StatusOK)
+	resp.Body = ioutil.NopCloser(strings.NewReader(`{"request_id":"` + requestID1 + `"}`))
+	return resp, nil
+}
+
+func TestWaitForMap(t *testing.T) {
+	tests := [...]struct {
+		notReady     int
+		timeout      time.Duration
+		wantErr      error
+		wantRequests int
+	}{
+		0: {notReady: 0, timeout: time.Second, wantRequests: 1},
+		1: {notReady: 1, timeout: 5 * time.Second, wantRequests: 2},
+		2: {notReady: 1e3, timeout: 50 * time.Millisecond, wantErr: uber.ErrMapNotReady, wantRequests: 2},
+		3: {notReady: 1e3, timeout: 0, wantErr: uber.ErrMapNotReady, wantRequests: 1},
+	}
+
+	want := mapFromFile(requestID1)
+	for i, tt := range tests {
+		client, err := uber.NewClient(testToken1)
+		if err != nil {
+			t.Fatalf("initializing client; %v", err)
+		}
+		mrt := &mapNotReadyRoundTripper{notReady: tt.notReady}
+		client.SetHTTPRoundTripper(mrt)
+
+		mapInfo, err := client.WaitForMap(requestID1, tt.timeout)
+		if mrt.requests != tt.wantRequests {
+			t.Errorf("#%d: requests: got=%d want=%d", i, mrt.requests, tt.wantRequests)
+		}
+		if tt.wantErr != nil {
+			if err != tt.wantErr {
+				t.Errorf("#%d: got err=%v want=%v", i, err, tt.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("#%d: err: %v", i, err)
+			continue
+		}
+		gotBlob, wantBlob := jsonSerialize(mapInfo), jsonSerialize(want)
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+
+	// Errors other than the map not being ready aren't retried.
+	client, _ := uber.NewClient(testToken1)
+	client.SetHTTPRoundTripper(&tRoundTripper{route: getMapRoute})
+	if _, err := client.WaitForMap("", time.Second); err == nil || err == uber.ErrMapNotReady {
+		t.Errorf("expected an immediate non-ErrMapNotReady error, got %v", err)
+	}
+}
+
+func TestListHistoryWithMaps(t *testing.T) {
+	client, err := uber.NewClient(testToken1)
+	if err != nil {
+		t.Fatalf("initializing client; %v", err)
+	}
+
+	client.SetHTTPRoundTripper(pathSuffixRoundTripper{
+		"/history": &tRoundTripper{route: listHistoryRoute},
+		"/map":     &tRoundTripper{route: getMapRoute},
+	})
+
+	hts, err := client.ListHistoryWithMaps(&uber.Pager{LimitPerPage: 2})
+	if err != nil {
+		t.Fatalf("unexpected err: %v", err)
+	}
+
+	// The map of the last trip can't be retrieved
+	// but that mustn't fail the rest of the listing.
+	failedTripID := "57be6f97-e10f-411e-a87e-670011c46b55"
+	wantTripIDs := []string{
+		"fb0a7c1f-2cf7-4310-bd27-8ba7737362fe",
+		"d72338b0-394d-4f0e-a73c-78d469fa0c6d",
+		"2b61e340-27bd-4937-8304-122009e4a393",
+		"58cb7b3c-fe22-47b4-94c0-2cf08b34f4be",
+		failedTripID,
+	}
+	if len(hts) != len(wantTripIDs) {
+		t.Fatalf("got %d trips want %d", len(hts), len(wantTripIDs))
+	}
+
+	for i, ht := range hts {
+		tripID := ht.Trip.RequestID
+		if tripID != wantTripIDs[i] {
+			t.Errorf("#%d: tripID: got=%q want=%q", i, tripID, wantTripIDs[i])
+			continue
+		}
+		if tripID == failedTripID {
+			if ht.MapErr == nil || ht.Map != nil {
+				t.Errorf("#%d: expected only a map error, got map=%+v err=%v", i, ht.Map, ht.MapErr)
+			}
+			continue
+		}
+		if ht.MapErr != nil {
+			t.Errorf("#%d: mapErr: %v", i, ht.MapErr)
+			continue
+		}
+		gotBlob, wantBlob := jsonSerialize(ht.Map), jsonSerialize(mapFromFile(tripID))
+		if !bytes.Equal(gotBlob, wantBlob) {
+			t.Errorf("#%d:\ngot:  %s\nwant: %s", i, gotBlob, wantBlob)
+		}
+	}
+}
+
 func (trt *tRoundTripper) requestMapRoundTrip(req *http.Request) (*http.Response, error) {
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
@@ -1857,7 +8021,7 @@ func (trt *tRoundTripper) listProductsRoundTrip(req *http.Request) (*http.Respon
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
 	}
-	resp := responseFromFileContent("./testdata/listProducts.json")
+	resp := responseFromFileContent("./fakeserver/fixtures/products.json")
 	return resp, nil
 }
 
@@ -1875,6 +8039,10 @@ func (trt *tRoundTripper) productByIDRoundTrip(req *http.Request) (*http.Respons
 	productID := splits[len(splits)-1]
 
 	diskPath := fmt.Sprintf("./testdata/product-%s.json", productID)
+	if productID == "a1111c8c-c720-46c3-8534-2fcdd730040d" {
+		// The product is shared with fakeserver.
+		diskPath = "./fakeserver/fixtures/product.json"
+	}
 	resp := responseFromFileContent(diskPath)
 	return resp, nil
 }
@@ -1883,7 +8051,7 @@ func (trt *tRoundTripper) listPaymentMethodRoundTrip(req *http.Request) (*http.R
 	if badAuthResp, _, err := prescreenAuthAndMethod(req, "GET"); badAuthResp != nil || err != nil {
 		return badAuthResp, err
 	}
-	resp := responseFromFileContent("./testdata/list-payments-1.json")
+	resp := responseFromFileContent("./fakeserver/fixtures/payment-methods.json")
 	return resp, nil
 }
 
@@ -1896,7 +8064,7 @@ func (trt *tRoundTripper) currentTripRoundTrip(req *http.Request) (*http.Respons
 		resp := makeResp(fmt.Sprintf("req.URL.Path: got = %q want = %q", g, w), http.StatusBadRequest)
 		return resp, nil
 	}
-	diskPath := "./testdata/trip-current.json"
+	diskPath := "./fakeserver/fixtures/requests-current.json"
 	resp := responseFromFileContent(diskPath)
 	return resp, nil
 }
@@ -1919,6 +8087,10 @@ func (trt *tRoundTripper) tripByIDRoundTrip(req *http.Request) (*http.Response,
 		return resp, nil
 	}
 	diskPath := fmt.Sprintf("./testdata/trip-%s.json", tripID)
+	if tripID == "a1111c8c-c720-46c3-8534-2fcdd730040d" {
+		// The trip is shared with fakeserver.
+		diskPath = "./fakeserver/fixtures/trip.json"
+	}
 	resp := responseFromFileContent(diskPath)
 	return resp, nil
 }
@@ -1954,6 +8126,18 @@ func timeEstimateFromFile(path string) []*uber.TimeEstimate {
 	return save.Estimates
 }
 
+func timeEstimatesForProducts(estimates []*uber.TimeEstimate, productIDs ...string) []*uber.TimeEstimate {
+	var filtered []*uber.TimeEstimate
+	for _, estimate := range estimates {
+		for _, productID := range productIDs {
+			if estimate.ProductID == productID {
+				filtered = append(filtered, estimate)
+			}
+		}
+	}
+	return filtered
+}
+
 func priceEstimateFromFile(path string) []*uber.PriceEstimate {
 	save := new(uber.PriceEstimatesPage)
 	if err := readFromFileAndDeserialize(path, save); err != nil {
@@ -2044,10 +8228,20 @@ const (
 	upfrontFareRoute           = "upfront-fare"
 	deliveryRoute              = "delivery"
 	sandboxTesterRoute         = "sandbox-test"
+	baseURLRoute               = "base-url"
+	driverActivityRoute        = "driver-activity"
+	listPlacesRoute            = "list-places"
+	deliveryTrackingRoute      = "delivery-tracking"
+	driverTripReceiptsRoute    = "driver-trip-receipts"
 	cancelDeliveryRoute        = "cancel-delivery"
 	listDeliveriesRoute        = "list-deliveries"
 	listDriverPaymentsRoute    = "list-driver-payments"
 	listDriverTripsRoute       = "list-driver-trips"
 	currentTripRoute           = "current-trip"
 	tripByIDRoute              = "trip-by-id"
+	bootstrapRoute             = "bootstrap"
+	listHistoryRoute           = "list-history"
+	productsWithETARoute       = "products-with-eta"
+	loginRedirectRoute         = "login-redirect"
+	updateRideDestinationRoute = "update-ride-destination"
 )